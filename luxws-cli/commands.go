@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hansmi/wp2reg-luxws/luxwsclient"
+)
+
+func (s *session) runGet(ctx context.Context, w io.Writer, path, format string) error {
+	sel, err := s.lookup(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	return writeSelection(w, sel, s.terms, format)
+}
+
+// runWatch retrieves the page containing the path at the given interval and
+// prints all values once, then only changes. It returns when the context is
+// cancelled.
+func (s *session) runWatch(ctx context.Context, w io.Writer, path string, interval time.Duration) error {
+	previous := map[string]string{}
+
+	for first := true; ; first = false {
+		sel, err := s.lookup(ctx, path)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			return err
+		}
+
+		now := time.Now().Format(time.TimeOnly)
+
+		for _, v := range sel.values() {
+			text := valueText(v.item)
+
+			if old, ok := previous[v.path]; first || !ok {
+				fmt.Fprintf(w, "%s %s: %s\n", now, v.path, text)
+			} else if old != text {
+				fmt.Fprintf(w, "%s %s: %s -> %s\n", now, v.path, old, text)
+			}
+
+			previous[v.path] = text
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// resolveSetValue determines the value to send for an item. Option names are
+// translated to their value and numbers are checked against the permitted
+// range, if any.
+func resolveSetValue(item *luxwsclient.ContentItem, value string) (string, error) {
+	if len(item.Options) > 0 {
+		var names []string
+
+		for _, opt := range item.Options {
+			if value == opt.Value || strings.EqualFold(value, strings.TrimSpace(opt.Name)) {
+				return opt.Value, nil
+			}
+
+			names = append(names, opt.Name)
+		}
+
+		return "", fmt.Errorf("%q is not an option for %q (one of %q)", value, item.Name, names)
+	}
+
+	if item.Min == nil || item.Max == nil {
+		return value, nil
+	}
+
+	parse := func(text string) (float64, error) {
+		return strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(text), ",", "."), 64)
+	}
+
+	num, err := parse(value)
+	if err != nil {
+		return "", fmt.Errorf("value for %q must be a number: %w", item.Name, err)
+	}
+
+	if min, err := parse(*item.Min); err == nil && num < min {
+		return "", fmt.Errorf("value %v for %q is below minimum %s", num, item.Name, *item.Min)
+	}
+
+	if max, err := parse(*item.Max); err == nil && num > max {
+		return "", fmt.Errorf("value %v for %q is above maximum %s", num, item.Name, *item.Max)
+	}
+
+	return value, nil
+}
+
+// runSet changes the value of an item and prints the value reported
+// afterwards.
+func (s *session) runSet(ctx context.Context, w io.Writer, path, value string) error {
+	sel, err := s.lookup(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	if sel.item == nil {
+		return fmt.Errorf("%q is a page, not an item", sel.path())
+	}
+
+	if value, err = resolveSetValue(sel.item, value); err != nil {
+		return err
+	}
+
+	setCtx, cancel := context.WithTimeout(ctx, s.opts.timeout)
+	defer cancel()
+
+	if err := s.client.Set(setCtx, sel.item.ID, value); err != nil {
+		return fmt.Errorf("setting %q: %w", sel.path(), err)
+	}
+
+	if sel, err = s.lookup(ctx, path); err != nil {
+		return fmt.Errorf("verifying new value: %w", err)
+	}
+
+	_, err = fmt.Fprintf(w, "%s: %s\n", sel.path(), valueText(sel.item))
+
+	return err
+}
+
+type dumpPage struct {
+	ID   string `json:"id"`
+	Path string `json:"path"`
+
+	// Raw response.
+	Content string `json:"content,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+type dumpResult struct {
+	Address    string     `json:"address"`
+	Time       time.Time  `json:"time"`
+	Language   string     `json:"language,omitempty"`
+	Navigation string     `json:"navigation"`
+	Pages      []dumpPage `json:"pages"`
+}
+
+func (s *session) dumpPages(ctx context.Context, prefix string, items []luxwsclient.NavItem) ([]dumpPage, error) {
+	var result []dumpPage
+
+	for _, item := range items {
+		page := dumpPage{
+			ID:   item.ID,
+			Path: joinPath(prefix, item.Name),
+		}
+
+		// Not every navigation item refers to a page
+		if _, payload, err := s.get(ctx, item.ID); err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+
+			page.Error = err.Error()
+		} else {
+			page.Content = string(payload)
+		}
+
+		children, err := s.dumpPages(ctx, page.Path, item.Items)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(append(result, page), children...)
+	}
+
+	return result, nil
+}
+
+// dump retrieves all pages reachable via the navigation.
+func (s *session) dump(ctx context.Context) (*dumpResult, error) {
+	result := &dumpResult{
+		Address:    s.opts.address,
+		Time:       time.Now().UTC(),
+		Navigation: string(s.navPayload),
+	}
+
+	if s.terms != nil {
+		result.Language = s.terms.ID
+	}
+
+	var err error
+
+	if result.Pages, err = s.dumpPages(ctx, "", s.nav.Items); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *session) runDump(ctx context.Context, path string) error {
+	result, err := s.dump(ctx)
+	if err != nil {
+		return err
+	}
+
+	if path == "" || path == "-" {
+		return writeJSON(os.Stdout, result)
+	}
+
+	content, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(content, '\n'), 0o644)
+}
@@ -0,0 +1,127 @@
+// Command luxws explores and controls heat pump controllers speaking the
+// Lux_WS protocol.
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/hansmi/wp2reg-luxws/luxws"
+	"github.com/hansmi/wp2reg-luxws/luxwslang"
+	"go.uber.org/multierr"
+)
+
+var address = kingpin.Flag("address",
+	`host:port for controller Websocket service (e.g. "192.0.2.1:8214")`).Envar("LUXWS_ADDRESS").Required().PlaceHolder("HOST:PORT").String()
+var password = kingpin.Flag("password", "Password for logging in; required for changing settings").Envar("LUXWS_PASSWORD").String()
+var lang = kingpin.Flag("language",
+	fmt.Sprintf("Controller interface language (one of %q; default: detected from navigation)", supportedLanguages())).PlaceHolder("NAME").String()
+var timeout = kingpin.Flag("timeout", "Maximum duration for each request").Default("30s").Duration()
+var verbose = kingpin.Flag("verbose", "Log sent and received messages").Bool()
+var record = kingpin.Flag("record",
+	"Record all sent and received messages to the given file, e.g. for replaying in tests; passwords are redacted").PlaceHolder("PATH").String()
+
+var treeCmd = kingpin.Command("tree", "Print the navigation tree with item IDs")
+
+var getCmd = kingpin.Command("get", "Print a page or an item on a page")
+var getPath = getCmd.Arg("path", `Slash-separated names or IDs (e.g. "Informationen/Temperaturen")`).Required().String()
+var getFormat = getCmd.Flag("format", fmt.Sprintf("Output format (one of %q)", outputFormats())).Default(formatTable).Enum(outputFormats()...)
+
+var watchCmd = kingpin.Command("watch", "Retrieve a page or item repeatedly and print changed values")
+var watchPath = watchCmd.Arg("path", "Slash-separated names or IDs").Required().String()
+var watchInterval = watchCmd.Flag("interval", "Time between retrievals").Default("10s").Duration()
+
+var setCmd = kingpin.Command("set", "Change the value of an item")
+var setPath = setCmd.Arg("path", "Slash-separated names or IDs of the item").Required().String()
+var setValue = setCmd.Arg("value", "New value; for items with options either the option name or its value").Required().String()
+
+var dumpCmd = kingpin.Command("dump", "Retrieve all pages and write the raw responses as JSON")
+var dumpOutput = dumpCmd.Flag("output", `Output file ("-" = standard output)`).Short('o').Default("-").PlaceHolder("PATH").String()
+
+func supportedLanguages() []string {
+	result := []string{}
+
+	for _, terms := range luxwslang.All() {
+		result = append(result, terms.ID)
+	}
+
+	return result
+}
+
+// run executes the selected command. Errors are returned instead of exiting
+// so that deferred cleanups run.
+func run(cmd string) (err error) {
+	opts := sessionOpts{
+		address:  *address,
+		password: *password,
+		timeout:  *timeout,
+	}
+
+	if *lang != "" {
+		terms, err := luxwslang.LookupByID(*lang)
+		if err != nil {
+			return fmt.Errorf("unknown controller language: %w", err)
+		}
+
+		opts.terms = terms
+	}
+
+	if *verbose {
+		opts.logf = log.Printf
+	}
+
+	if *record != "" {
+		fh, err := os.Create(*record)
+		if err != nil {
+			return err
+		}
+
+		defer func() {
+			err = multierr.Append(err, fh.Close())
+		}()
+
+		opts.recorder = luxws.NewRecorder(fh)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	s, err := connect(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	defer s.Close()
+
+	switch cmd {
+	case treeCmd.FullCommand():
+		return writeTree(os.Stdout, s.nav)
+
+	case getCmd.FullCommand():
+		return s.runGet(ctx, os.Stdout, *getPath, *getFormat)
+
+	case watchCmd.FullCommand():
+		return s.runWatch(ctx, os.Stdout, *watchPath, *watchInterval)
+
+	case setCmd.FullCommand():
+		return s.runSet(ctx, os.Stdout, *setPath, *setValue)
+
+	case dumpCmd.FullCommand():
+		return s.runDump(ctx, *dumpOutput)
+	}
+
+	return nil
+}
+
+func main() {
+	log.SetFlags(0)
+
+	if err := run(kingpin.Parse()); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hansmi/wp2reg-luxws/luxwsclient"
+	"github.com/hansmi/wp2reg-luxws/luxwslang"
+)
+
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatXML   = "xml"
+)
+
+func outputFormats() []string {
+	return []string{formatTable, formatJSON, formatXML}
+}
+
+func writeNavItems(w io.Writer, items []luxwsclient.NavItem, depth int) error {
+	for _, item := range items {
+		if _, err := fmt.Fprintf(w, "%s\t%s%s\n", item.ID, strings.Repeat("  ", depth), item.Name); err != nil {
+			return err
+		}
+
+		if err := writeNavItems(w, item.Items, depth+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeTree prints the navigation structure with one item per line.
+func writeTree(w io.Writer, nav *luxwsclient.NavRoot) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	if err := writeNavItems(tw, nav.Items, 0); err != nil {
+		return err
+	}
+
+	return tw.Flush()
+}
+
+func valueText(item *luxwsclient.ContentItem) string {
+	if item.Value == nil {
+		return ""
+	}
+
+	return *item.Value
+}
+
+// writeTable prints all items with a value.
+func writeTable(w io.Writer, values []flatValue) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "ID\tPATH\tVALUE")
+
+	for _, v := range values {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", v.item.ID, v.path, valueText(v.item))
+	}
+
+	return tw.Flush()
+}
+
+type jsonOption struct {
+	Value string `json:"value"`
+	Name  string `json:"name"`
+}
+
+type jsonItem struct {
+	ID   string  `json:"id"`
+	Name string  `json:"name"`
+	Text *string `json:"text,omitempty"`
+
+	// Parsed value for booleans and measurements.
+	Value *float64 `json:"value,omitempty"`
+	Unit  string   `json:"unit,omitempty"`
+
+	Min     *string      `json:"min,omitempty"`
+	Max     *string      `json:"max,omitempty"`
+	Step    *string      `json:"step,omitempty"`
+	Options []jsonOption `json:"options,omitempty"`
+	Items   []jsonItem   `json:"items,omitempty"`
+}
+
+// parseValue converts the text of an item into a number using the given
+// terminology. The second return value is false if the text couldn't be
+// parsed.
+func parseValue(terms *luxwslang.Terminology, text string) (float64, string, bool) {
+	if terms == nil {
+		return 0, "", false
+	}
+
+	switch text {
+	case terms.BoolFalse:
+		return 0, "", true
+	case terms.BoolTrue:
+		return 1, "", true
+	}
+
+	value, unit, err := terms.ParseMeasurement(text)
+
+	return value, unit, err == nil
+}
+
+func convertItems(terms *luxwslang.Terminology, items []luxwsclient.ContentItem) []jsonItem {
+	var result []jsonItem
+
+	for _, item := range items {
+		ji := jsonItem{
+			ID:    item.ID,
+			Name:  item.Name,
+			Text:  item.Value,
+			Min:   item.Min,
+			Max:   item.Max,
+			Step:  item.Step,
+			Items: convertItems(terms, item.Items),
+		}
+
+		if item.Value != nil {
+			if value, unit, ok := parseValue(terms, *item.Value); ok {
+				ji.Value = &value
+				ji.Unit = unit
+			}
+		}
+
+		for _, opt := range item.Options {
+			ji.Options = append(ji.Options, jsonOption{opt.Value, opt.Name})
+		}
+
+		result = append(result, ji)
+	}
+
+	return result
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(v)
+}
+
+// writeSelection prints a page or item in the given format.
+func writeSelection(w io.Writer, sel *selection, terms *luxwslang.Terminology, format string) error {
+	switch format {
+	case formatTable:
+		return writeTable(w, sel.values())
+
+	case formatJSON:
+		return writeJSON(w, struct {
+			Path  string     `json:"path"`
+			Items []jsonItem `json:"items"`
+		}{sel.path(), convertItems(terms, sel.items())})
+
+	case formatXML:
+		var v any = sel.content
+
+		if sel.item != nil {
+			v = struct {
+				XMLName xml.Name `xml:"item"`
+				*luxwsclient.ContentItem
+			}{ContentItem: sel.item}
+		}
+
+		content, err := xml.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintf(w, "%s\n", content)
+
+		return err
+	}
+
+	return fmt.Errorf("unknown format %q", format)
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hansmi/wp2reg-luxws/luxwsclient"
+)
+
+// splitPath splits a slash-separated path into its non-empty segments.
+func splitPath(path string) []string {
+	var result []string
+
+	for _, seg := range strings.Split(path, "/") {
+		if seg = strings.TrimSpace(seg); seg != "" {
+			result = append(result, seg)
+		}
+	}
+
+	return result
+}
+
+func joinPath(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+
+	return prefix + "/" + name
+}
+
+// matchSegment reports whether a path segment refers to an item. Names are
+// compared case-insensitively.
+func matchSegment(seg, id, name string) bool {
+	return seg == id || strings.EqualFold(seg, strings.TrimSpace(name))
+}
+
+type navPage struct {
+	item *luxwsclient.NavItem
+	path string
+}
+
+// resolveNav follows the path through the navigation structure as far as
+// possible. The remaining segments refer to items on the page.
+func resolveNav(nav *luxwsclient.NavRoot, segments []string) (navPage, []string, error) {
+	var page navPage
+
+	items := nav.Items
+
+	for len(segments) > 0 {
+		var found *luxwsclient.NavItem
+
+		for i := range items {
+			if matchSegment(segments[0], items[i].ID, items[i].Name) {
+				found = &items[i]
+				break
+			}
+		}
+
+		if found == nil {
+			break
+		}
+
+		page = navPage{found, joinPath(page.path, found.Name)}
+		items = found.Items
+		segments = segments[1:]
+	}
+
+	if page.item == nil {
+		if len(segments) == 0 {
+			return page, nil, fmt.Errorf("empty path")
+		}
+
+		return page, nil, fmt.Errorf("navigation item %q not found", segments[0])
+	}
+
+	return page, segments, nil
+}
+
+// selection is a page or an item on a page.
+type selection struct {
+	page    navPage
+	content *luxwsclient.ContentRoot
+
+	// Selected item; nil for the whole page.
+	item *luxwsclient.ContentItem
+
+	// Path of the parent of the selected item.
+	prefix string
+}
+
+func selectContent(page navPage, content *luxwsclient.ContentRoot, segments []string) (*selection, error) {
+	sel := &selection{
+		page:    page,
+		content: content,
+		prefix:  page.path,
+	}
+
+	items := content.Items
+
+	for _, seg := range segments {
+		var found *luxwsclient.ContentItem
+
+		for i := range items {
+			if matchSegment(seg, items[i].ID, items[i].Name) {
+				found = &items[i]
+				break
+			}
+		}
+
+		if found == nil {
+			return nil, fmt.Errorf("item %q not found on page %q", seg, page.path)
+		}
+
+		if sel.item != nil {
+			sel.prefix = joinPath(sel.prefix, sel.item.Name)
+		}
+
+		sel.item = found
+		items = found.Items
+	}
+
+	return sel, nil
+}
+
+// items returns the selected items.
+func (s *selection) items() []luxwsclient.ContentItem {
+	if s.item == nil {
+		return s.content.Items
+	}
+
+	return []luxwsclient.ContentItem{*s.item}
+}
+
+// path returns the path of the selection.
+func (s *selection) path() string {
+	if s.item == nil {
+		return s.page.path
+	}
+
+	return joinPath(s.prefix, s.item.Name)
+}
+
+// flatValue is an item with a value and its full path.
+type flatValue struct {
+	path string
+	item *luxwsclient.ContentItem
+}
+
+func flattenItems(prefix string, items []luxwsclient.ContentItem) []flatValue {
+	var result []flatValue
+
+	for i := range items {
+		item := &items[i]
+		path := joinPath(prefix, item.Name)
+
+		if item.Value != nil {
+			result = append(result, flatValue{path, item})
+		}
+
+		result = append(result, flattenItems(path, item.Items)...)
+	}
+
+	return result
+}
+
+func (s *selection) values() []flatValue {
+	return flattenItems(s.prefix, s.items())
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hansmi/wp2reg-luxws/luxws"
+	"github.com/hansmi/wp2reg-luxws/luxwsclient"
+	"github.com/hansmi/wp2reg-luxws/luxwslang"
+)
+
+type sessionOpts struct {
+	address  string
+	password string
+	timeout  time.Duration
+	logf     luxwsclient.LogFunc
+	recorder *luxws.Recorder
+
+	// Terminology for parsing values; detected from the navigation if nil.
+	terms *luxwslang.Terminology
+}
+
+// session is a logged-in connection to a controller. IDs are only valid for
+// the connection they were retrieved on.
+type session struct {
+	opts   sessionOpts
+	client *luxwsclient.Client
+	nav    *luxwsclient.NavRoot
+	terms  *luxwslang.Terminology
+
+	// Raw navigation document received on login.
+	navPayload []byte
+
+	// Most recently received document.
+	lastPayload []byte
+}
+
+// detectTerminology returns the terminology whose name for the information
+// page is used in the navigation or nil.
+func detectTerminology(nav *luxwsclient.NavRoot) *luxwslang.Terminology {
+	for _, terms := range luxwslang.All() {
+		if nav.FindByName(terms.NavInformation) != nil {
+			return terms
+		}
+	}
+
+	return nil
+}
+
+func connect(ctx context.Context, opts sessionOpts) (*session, error) {
+	s := &session{
+		opts:  opts,
+		terms: opts.terms,
+	}
+
+	clientOpts := []luxwsclient.Option{
+		luxwsclient.WithResponseHook(func(_ string, payload []byte) {
+			s.lastPayload = append([]byte(nil), payload...)
+		}),
+	}
+
+	if opts.logf != nil {
+		clientOpts = append(clientOpts, luxwsclient.WithLogFunc(opts.logf))
+	}
+
+	if opts.recorder != nil {
+		clientOpts = append(clientOpts, luxwsclient.WithTransportOptions(luxws.WithRecorder(opts.recorder)))
+	}
+
+	dialCtx, cancel := context.WithTimeout(ctx, opts.timeout)
+	defer cancel()
+
+	var err error
+
+	if s.client, err = luxwsclient.Dial(dialCtx, opts.address, clientOpts...); err != nil {
+		return nil, fmt.Errorf("connecting to %s: %w", opts.address, err)
+	}
+
+	if s.nav, err = s.client.Login(dialCtx, opts.password); err != nil {
+		s.client.Close()
+		return nil, fmt.Errorf("login failed: %w", err)
+	}
+
+	s.navPayload = s.lastPayload
+
+	if s.terms == nil {
+		s.terms = detectTerminology(s.nav)
+	}
+
+	return s, nil
+}
+
+func (s *session) Close() error {
+	return s.client.Close()
+}
+
+// get retrieves a page. The raw response is returned as well.
+func (s *session) get(ctx context.Context, id string) (*luxwsclient.ContentRoot, []byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.opts.timeout)
+	defer cancel()
+
+	content, err := s.client.Get(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return content, s.lastPayload, nil
+}
+
+// lookup resolves a path and retrieves the page containing it.
+func (s *session) lookup(ctx context.Context, path string) (*selection, error) {
+	page, rest, err := resolveNav(s.nav, splitPath(path))
+	if err != nil {
+		return nil, err
+	}
+
+	content, _, err := s.get(ctx, page.item.ID)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving page %q: %w", page.path, err)
+	}
+
+	return selectContent(page, content, rest)
+}
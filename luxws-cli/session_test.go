@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gorilla/websocket"
+	"github.com/hansmi/wp2reg-luxws/luxwslang"
+)
+
+const testNavigation = `<Navigation id="0x1">` +
+	`<item id="0x10"><name>Informationen</name>` +
+	`<item id="0x11"><name>Temperaturen</name></item>` +
+	`</item>` +
+	`<item id="0x20"><name>Einstellungen</name></item>` +
+	`<item id="0x30"><name>Service</name></item>` +
+	`</Navigation>`
+
+// testController answers LOGIN and GET requests and records SET requests.
+type testController struct {
+	mu    sync.Mutex
+	pages map[string]string
+	sets  []string
+}
+
+func newTestSession(t *testing.T, tc *testController) *session {
+	t.Helper()
+
+	var upgrader websocket.Upgrader
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("Connection upgrade failed: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		for {
+			mt, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+
+			req := string(message)
+
+			tc.mu.Lock()
+
+			var response string
+
+			switch {
+			case strings.HasPrefix(req, "LOGIN;"):
+				response = testNavigation
+			case strings.HasPrefix(req, "GET;"):
+				response = tc.pages[strings.TrimPrefix(req, "GET;")]
+			case strings.HasPrefix(req, "SET;"):
+				tc.sets = append(tc.sets, req)
+			}
+
+			tc.mu.Unlock()
+
+			if response == "" {
+				continue
+			}
+
+			if err := conn.WriteMessage(mt, []byte(response)); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := connect(context.Background(), sessionOpts{
+		address: serverURL.Host,
+		timeout: time.Second,
+		logf:    t.Logf,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		s.Close()
+	})
+
+	return s
+}
+
+func newTestPages() *testController {
+	return &testController{
+		pages: map[string]string{
+			"0x10": `<Content>` +
+				`<item id="0x100"><name>Temperaturen</name>` +
+				`<item id="0x101"><name>Vorlauf</name><value>28.4°C</value></item>` +
+				`<item id="0x102"><name>Rücklauf</name><value>25.1°C</value></item>` +
+				`</item>` +
+				`<item id="0x110"><name>Eingänge</name>` +
+				`<item id="0x111"><name>ASD</name><value>Ein</value></item>` +
+				`</item>` +
+				`</Content>`,
+			"0x11": `<Content>` +
+				`<item id="0x101"><name>Vorlauf</name><value>28.4°C</value></item>` +
+				`</Content>`,
+			"0x20": `<Content>` +
+				`<item id="0x200"><name>Betriebsart</name><value>Automatik</value>` +
+				`<option value="0">Automatik</option><option value="4">Aus</option></item>` +
+				`<item id="0x201"><name>Temperatur +-</name><value>0.0°C</value><min>-50</min><max>50</max></item>` +
+				`</Content>`,
+		},
+	}
+}
+
+func TestDetectTerminology(t *testing.T) {
+	s := newTestSession(t, newTestPages())
+
+	if s.terms != luxwslang.German {
+		t.Errorf("Detected terminology %v, want German", s.terms)
+	}
+}
+
+func TestTree(t *testing.T) {
+	s := newTestSession(t, newTestPages())
+
+	var buf bytes.Buffer
+
+	if err := writeTree(&buf, s.nav); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "0x10  Informationen\n" +
+		"0x11    Temperaturen\n" +
+		"0x20  Einstellungen\n" +
+		"0x30  Service\n"
+
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("Tree difference (-want +got):\n%s", diff)
+	}
+}
+
+func TestGet(t *testing.T) {
+	s := newTestSession(t, newTestPages())
+
+	for _, tc := range []struct {
+		path   string
+		format string
+		want   string
+	}{
+		{
+			path:   "informationen",
+			format: formatTable,
+			want: "ID     PATH                                 VALUE\n" +
+				"0x101  Informationen/Temperaturen/Vorlauf   28.4°C\n" +
+				"0x102  Informationen/Temperaturen/Rücklauf  25.1°C\n" +
+				"0x111  Informationen/Eingänge/ASD           Ein\n",
+		},
+		{
+			path:   "Informationen/Eingänge/0x111",
+			format: formatJSON,
+			want: `{
+  "path": "Informationen/Eingänge/ASD",
+  "items": [
+    {
+      "id": "0x111",
+      "name": "ASD",
+      "text": "Ein",
+      "value": 1
+    }
+  ]
+}
+`,
+		},
+		{
+			path:   "Informationen/Temperaturen/Vorlauf",
+			format: formatXML,
+			want:   "<item id=\"0x101\">\n  <name>Vorlauf</name>\n  <value>28.4°C</value>\n</item>\n",
+		},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			if err := s.runGet(context.Background(), &buf, tc.path, tc.format); err != nil {
+				t.Fatalf("runGet() failed: %v", err)
+			}
+
+			if diff := cmp.Diff(tc.want, buf.String()); diff != "" {
+				t.Errorf("Output difference (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	for _, path := range []string{"", "Unknown", "Informationen/Unknown"} {
+		if err := s.runGet(context.Background(), &bytes.Buffer{}, path, formatTable); err == nil {
+			t.Errorf("runGet(%q) succeeded", path)
+		}
+	}
+}
+
+func TestSet(t *testing.T) {
+	tc := newTestPages()
+	s := newTestSession(t, tc)
+
+	for _, args := range [][2]string{
+		{"Einstellungen/Betriebsart", "aus"},
+		{"Einstellungen/Betriebsart", "0"},
+		{"Einstellungen/Temperatur +-", "-1.5"},
+	} {
+		if err := s.runSet(context.Background(), &bytes.Buffer{}, args[0], args[1]); err != nil {
+			t.Errorf("runSet(%q, %q) failed: %v", args[0], args[1], err)
+		}
+	}
+
+	for _, args := range [][2]string{
+		{"Einstellungen", "1"},
+		{"Einstellungen/Betriebsart", "Party"},
+		{"Einstellungen/Temperatur +-", "51"},
+		{"Einstellungen/Temperatur +-", "warm"},
+	} {
+		if err := s.runSet(context.Background(), &bytes.Buffer{}, args[0], args[1]); err == nil {
+			t.Errorf("runSet(%q, %q) succeeded", args[0], args[1])
+		}
+	}
+
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	if diff := cmp.Diff([]string{
+		"SET;set_0x200;4",
+		"SET;set_0x200;0",
+		"SET;set_0x201;-1.5",
+	}, tc.sets); diff != "" {
+		t.Errorf("SET requests difference (-want +got):\n%s", diff)
+	}
+}
+
+func TestDump(t *testing.T) {
+	tc := newTestPages()
+	s := newTestSession(t, tc)
+
+	got, err := s.dump(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Navigation != testNavigation || got.Language != "de" {
+		t.Errorf("Unexpected navigation %q in language %q", got.Navigation, got.Language)
+	}
+
+	var pages []dumpPage
+
+	for _, p := range got.Pages {
+		if p.Error != "" {
+			p.Error = "failed"
+		}
+
+		pages = append(pages, p)
+	}
+
+	if diff := cmp.Diff([]dumpPage{
+		{ID: "0x10", Path: "Informationen", Content: tc.pages["0x10"]},
+		{ID: "0x11", Path: "Informationen/Temperaturen", Content: tc.pages["0x11"]},
+		{ID: "0x20", Path: "Einstellungen", Content: tc.pages["0x20"]},
+		{ID: "0x30", Path: "Service", Error: "failed"},
+	}, pages); diff != "" {
+		t.Errorf("Pages difference (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/hansmi/wp2reg-luxws/luxwsclient"
+)
+
+// apiValue is a single item of the information page.
+type apiValue struct {
+	// Lower-case, language-dependent key derived from the path (e.g.
+	// "temperatures.flow").
+	Key string `json:"key"`
+
+	// Normalized item names separated by slashes.
+	Path string `json:"path"`
+
+	Name string `json:"name"`
+
+	// Value as displayed by the controller.
+	Text string `json:"text"`
+
+	// Parsed value for booleans, measurements and enumerations.
+	Value *float64 `json:"value,omitempty"`
+	Unit  string   `json:"unit,omitempty"`
+
+	// Parsed value for dates in the controller's timezone.
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+}
+
+// apiNode is an item of the information page including its children.
+type apiNode struct {
+	apiValue
+	Items []apiNode `json:"items,omitempty"`
+}
+
+type apiValuesResponse struct {
+	Controller string     `json:"controller"`
+	Time       time.Time  `json:"time"`
+	Values     []apiValue `json:"values"`
+}
+
+type apiTreeResponse struct {
+	Controller string    `json:"controller"`
+	Time       time.Time `json:"time"`
+	Items      []apiNode `json:"items"`
+}
+
+type apiErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// canonicalKey converts an item path to a key consisting of lower-case
+// letters, digits and underscores with path elements separated by dots.
+func canonicalKey(path string) string {
+	parts := strings.Split(path, "/")
+
+	for idx, part := range parts {
+		parts[idx] = strings.Join(strings.FieldsFunc(strings.ToLower(part), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}), "_")
+	}
+
+	return strings.Join(parts, ".")
+}
+
+// apiNodes converts content items. Paths are built like in generic mode.
+func (c *collector) apiNodes(prefix string, items []luxwsclient.ContentItem) []apiNode {
+	result := []apiNode{}
+	seen := map[string]int{}
+
+	for idx := range items {
+		item := &items[idx]
+		name := normalizeSpace(item.Name)
+		pathName := name
+
+		seen[name]++
+
+		if count := seen[name]; count > 1 {
+			pathName = fmt.Sprintf("%s[%d]", name, count)
+		}
+
+		path := pathName
+
+		if prefix != "" {
+			path = prefix + "/" + pathName
+		}
+
+		node := apiNode{
+			apiValue: apiValue{
+				Key:  canonicalKey(path),
+				Path: path,
+				Name: name,
+			},
+		}
+
+		if item.Value != nil {
+			node.Text = normalizeSpace(*item.Value)
+
+			if value, unit, ok := c.genericValue(item); ok {
+				node.Value = &value
+				node.Unit = unit
+			} else if ts, err := c.terms.ParseTimestamp(node.Text, c.loc); err == nil {
+				node.Timestamp = &ts
+			}
+		}
+
+		if len(item.Items) > 0 {
+			node.Items = c.apiNodes(path, item.Items)
+		}
+
+		result = append(result, node)
+	}
+
+	return result
+}
+
+// flattenAPINodes returns all nodes with a value.
+func flattenAPINodes(nodes []apiNode) []apiValue {
+	result := []apiValue{}
+
+	for _, n := range nodes {
+		if n.Text != "" || n.Value != nil || n.Timestamp != nil {
+			result = append(result, n.apiValue)
+		}
+
+		result = append(result, flattenAPINodes(n.Items)...)
+	}
+
+	return result
+}
+
+// fetchInformation retrieves the information page from the controller.
+func (c *collector) fetchInformation(ctx context.Context) (*luxwsclient.ContentRoot, error) {
+	if err := c.sem.Acquire(ctx, 1); err != nil {
+		return nil, err
+	}
+
+	defer c.sem.Release(1)
+
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	cl, nav, err := c.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer cl.Close()
+
+	info := nav.FindByName(c.terms.NavInformation)
+	if info == nil {
+		return nil, errors.New("information ID not found in response")
+	}
+
+	content, err := cl.Get(ctx, info.ID)
+	if err != nil {
+		return nil, fmt.Errorf("fetching ID %q failed: %w", info.ID, err)
+	}
+
+	return content, nil
+}
+
+// selectTarget returns the controller named in the request. The name may be
+// omitted if only one controller is configured.
+func selectTarget(r *http.Request, targets map[string]*collector) (string, *collector, error) {
+	name := r.URL.Query().Get(controllerLabel)
+
+	if name == "" && len(targets) == 1 {
+		for name = range targets {
+		}
+	}
+
+	if c, ok := targets[name]; ok {
+		return name, c, nil
+	}
+
+	names := make([]string, 0, len(targets))
+
+	for i := range targets {
+		names = append(names, i)
+	}
+
+	sort.Strings(names)
+
+	return "", nil, fmt.Errorf("unknown controller %q (one of %q)", name, names)
+}
+
+// apiHandler serves the current values of the information page as JSON.
+type apiHandler struct {
+	targets func() map[string]*collector
+
+	// Snapshots of polled controllers are served without contacting the
+	// controller if available.
+	latest *snapshotStore
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func (h *apiHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeJSON(w, http.StatusMethodNotAllowed, apiErrorResponse{"method not allowed"})
+		return
+	}
+
+	endpoint := strings.TrimPrefix(r.URL.Path, "/api/v1/")
+
+	if endpoint != "values" && endpoint != "tree" {
+		writeJSON(w, http.StatusNotFound, apiErrorResponse{fmt.Sprintf("unknown endpoint %q", r.URL.Path)})
+		return
+	}
+
+	name, c, err := selectTarget(r, h.targets())
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, apiErrorResponse{err.Error()})
+		return
+	}
+
+	if snap := h.latest.get(name); snap != nil && endpoint == "values" {
+		writeJSON(w, http.StatusOK, snap.valuesResponse())
+		return
+	}
+
+	content, err := c.fetchInformation(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, apiErrorResponse{err.Error()})
+		return
+	}
+
+	now := c.now().UTC()
+	nodes := c.apiNodes("", content.Items)
+
+	if endpoint == "tree" {
+		writeJSON(w, http.StatusOK, apiTreeResponse{
+			Controller: name,
+			Time:       now,
+			Items:      nodes,
+		})
+	} else {
+		writeJSON(w, http.StatusOK, apiValuesResponse{
+			Controller: name,
+			Time:       now,
+			Values:     flattenAPINodes(nodes),
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hansmi/wp2reg-luxws/luxwslang"
+)
+
+func TestCanonicalKey(t *testing.T) {
+	for _, tc := range []struct {
+		path string
+		want string
+	}{
+		{"", ""},
+		{"Temperatures/Flow", "temperatures.flow"},
+		{"Temperaturen/Rückl.-Soll", "temperaturen.rückl_soll"},
+		{"Inputs/ASD [2]", "inputs.asd_2"},
+		{"Last error/Error[2]", "last_error.error_2"},
+	} {
+		if got := canonicalKey(tc.path); got != tc.want {
+			t.Errorf("canonicalKey(%q) = %q, want %q", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestAPIHandler(t *testing.T) {
+	discardAllLogs(t)
+
+	now := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	c := newCollector(collectorOpts{
+		terms:   luxwslang.English,
+		loc:     time.UTC,
+		timeout: time.Minute,
+		address: newTestController(t, `<Content id="0x2">`+
+			`<item id="0x10"><name>temperatures</name>`+
+			`<item id="0x11"><name>Flow</name><value>25.0°C</value></item>`+
+			`<item id="0x12"><name>Flow</name><value>26.5°C</value></item>`+
+			`</item>`+
+			`<item id="0x20"><name>Last error</name>`+
+			`<item id="0x21"><name>Error 1</name><value>02.01.20 13:14:15</value></item>`+
+			`<item id="0x22"><name>Status</name><value>running</value></item>`+
+			`</item>`+
+			`</Content>`),
+	})
+	c.now = func() time.Time {
+		return now
+	}
+
+	h := &apiHandler{
+		targets: func() map[string]*collector {
+			return map[string]*collector{"a": c}
+		},
+	}
+
+	ptr := func(v float64) *float64 {
+		return &v
+	}
+
+	errorTime := time.Date(2020, time.January, 2, 13, 14, 15, 0, time.UTC)
+
+	values := []apiValue{
+		{Key: "temperatures.flow", Path: "temperatures/Flow", Name: "Flow", Text: "25.0°C", Value: ptr(25), Unit: "degC"},
+		{Key: "temperatures.flow_2", Path: "temperatures/Flow[2]", Name: "Flow", Text: "26.5°C", Value: ptr(26.5), Unit: "degC"},
+		{Key: "last_error.error_1", Path: "Last error/Error 1", Name: "Error 1", Text: "02.01.20 13:14:15", Timestamp: &errorTime},
+		{Key: "last_error.status", Path: "Last error/Status", Name: "Status", Text: "running"},
+	}
+
+	for _, tc := range []struct {
+		name     string
+		method   string
+		target   string
+		wantCode int
+		want     any
+	}{
+		{
+			name:     "values",
+			target:   "/api/v1/values",
+			wantCode: http.StatusOK,
+			want: &apiValuesResponse{
+				Controller: "a",
+				Time:       now,
+				Values:     values,
+			},
+		},
+		{
+			name:     "tree",
+			target:   "/api/v1/tree?controller=a",
+			wantCode: http.StatusOK,
+			want: &apiTreeResponse{
+				Controller: "a",
+				Time:       now,
+				Items: []apiNode{
+					{
+						apiValue: apiValue{Key: "temperatures", Path: "temperatures", Name: "temperatures"},
+						Items:    []apiNode{{apiValue: values[0]}, {apiValue: values[1]}},
+					},
+					{
+						apiValue: apiValue{Key: "last_error", Path: "Last error", Name: "Last error"},
+						Items:    []apiNode{{apiValue: values[2]}, {apiValue: values[3]}},
+					},
+				},
+			},
+		},
+		{
+			name:     "unknown controller",
+			target:   "/api/v1/values?controller=b",
+			wantCode: http.StatusNotFound,
+			want:     &apiErrorResponse{`unknown controller "b" (one of ["a"])`},
+		},
+		{
+			name:     "unknown endpoint",
+			target:   "/api/v1/other",
+			wantCode: http.StatusNotFound,
+			want:     &apiErrorResponse{`unknown endpoint "/api/v1/other"`},
+		},
+		{
+			name:     "method",
+			method:   http.MethodPost,
+			target:   "/api/v1/values",
+			wantCode: http.StatusMethodNotAllowed,
+			want:     &apiErrorResponse{"method not allowed"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			method := tc.method
+
+			if method == "" {
+				method = http.MethodGet
+			}
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(method, tc.target, nil))
+
+			if rec.Code != tc.wantCode {
+				t.Errorf("Got status %d, want %d", rec.Code, tc.wantCode)
+			}
+
+			if got := rec.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("Got content type %q", got)
+			}
+
+			got := reflect.New(reflect.TypeOf(tc.want).Elem()).Interface()
+
+			if err := json.Unmarshal(rec.Body.Bytes(), got); err != nil {
+				t.Fatalf("Decoding response failed: %v\n%s", err, rec.Body.String())
+			}
+
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(apiNode{})); diff != "" {
+				t.Errorf("Response difference (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
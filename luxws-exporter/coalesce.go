@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/singleflight"
+)
+
+// coalescer deduplicates concurrent collections from the same controller.
+// Scrapes arriving while a collection is in flight share its result instead
+// of opening another connection.
+type coalescer struct {
+	group singleflight.Group
+
+	scrapes   *prometheus.CounterVec
+	coalesced *prometheus.CounterVec
+}
+
+func newCoalescer() *coalescer {
+	return &coalescer{
+		scrapes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "luxws_exporter_scrapes_total",
+			Help: "Number of scrapes eligible for coalescing",
+		}, []string{"target"}),
+		coalesced: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "luxws_exporter_scrapes_coalesced_total",
+			Help: "Number of scrapes served by sharing the result of a concurrent collection",
+		}, []string{"target"}),
+	}
+}
+
+// Metrics returns the collectors for metrics about coalesced scrapes.
+func (co *coalescer) Metrics() []prometheus.Collector {
+	return []prometheus.Collector{co.scrapes, co.coalesced}
+}
+
+// do invokes fn unless a call with the same key is already in flight, in
+// which case the result of the latter is returned. The context given to fn is
+// not bound to any particular caller.
+func (co *coalescer) do(key, target string, timeout time.Duration, fn func(context.Context) *snapshot) *snapshot {
+	var leader bool
+
+	co.scrapes.WithLabelValues(target).Inc()
+
+	result, _, _ := co.group.Do(key, func() (any, error) {
+		leader = true
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		return fn(ctx), nil
+	})
+
+	if !leader {
+		co.coalesced.WithLabelValues(target).Inc()
+	}
+
+	return result.(*snapshot)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hansmi/wp2reg-luxws/luxwslang"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCoalescer(t *testing.T) {
+	const callers = 5
+
+	co := newCoalescer()
+
+	desc := newReadingDesc("test", "", nil, readingGauge)
+	release := make(chan struct{})
+
+	var calls atomic.Int32
+
+	fn := func(ctx context.Context) *snapshot {
+		calls.Add(1)
+
+		select {
+		case <-release:
+		case <-ctx.Done():
+			return &snapshot{err: ctx.Err()}
+		}
+
+		return &snapshot{
+			readings: []reading{newReading(desc, 1)},
+		}
+	}
+
+	var wg sync.WaitGroup
+
+	for range callers {
+		wg.Go(func() {
+			snap := co.do("key", "target", time.Minute, fn)
+			if snap.err != nil {
+				t.Errorf("do() failed: %v", snap.err)
+			}
+
+			if len(snap.readings) != 1 {
+				t.Errorf("do() returned %d readings, want 1", len(snap.readings))
+			}
+		})
+	}
+
+	// Give all callers a chance to wait for the result
+	for testutil.ToFloat64(co.scrapes.WithLabelValues("target")) < callers {
+		time.Sleep(time.Millisecond)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	close(release)
+	wg.Wait()
+
+	if got := calls.Load(); got < 1 || got >= callers {
+		t.Errorf("Function called %d times, want less than %d", got, callers)
+	}
+
+	// Every caller not invoking the function is coalesced
+	wantCoalesced := callers - int(calls.Load())
+
+	if err := testutil.CollectAndCompare(co.coalesced, strings.NewReader(fmt.Sprintf(`
+# HELP luxws_exporter_scrapes_coalesced_total Number of scrapes served by sharing the result of a concurrent collection
+# TYPE luxws_exporter_scrapes_coalesced_total counter
+luxws_exporter_scrapes_coalesced_total{target="target"} %d
+`, wantCoalesced))); err != nil {
+		t.Error(err)
+	}
+
+	// Calls after completion are not coalesced
+	errTest := errors.New("test")
+
+	if err := co.do("key", "target", time.Minute, func(context.Context) *snapshot {
+		return &snapshot{err: errTest}
+	}).err; !errors.Is(err, errTest) {
+		t.Errorf("do() returned %v, want %v", err, errTest)
+	}
+
+	if got := testutil.ToFloat64(co.coalesced.WithLabelValues("target")); got != float64(wantCoalesced) {
+		t.Errorf("Coalesced count is %v, want %d", got, wantCoalesced)
+	}
+}
+
+func TestCoalesceKey(t *testing.T) {
+	base := collectorOpts{
+		address: "192.0.2.1:8214",
+		terms:   luxwslang.German,
+		loc:     time.UTC,
+	}
+
+	other := base
+	other.terms = luxwslang.English
+
+	sections := base
+	sections.sections = []string{"inputs", "info"}
+
+	sectionsReordered := base
+	sectionsReordered.sections = []string{"info", "inputs"}
+
+	if newCollector(base).coalesceKey() != newCollector(base).coalesceKey() {
+		t.Errorf("Identical options produce different keys")
+	}
+
+	if newCollector(base).coalesceKey() == newCollector(other).coalesceKey() {
+		t.Errorf("Different languages produce identical keys")
+	}
+
+	if newCollector(sections).coalesceKey() != newCollector(sectionsReordered).coalesceKey() {
+		t.Errorf("Section order affects key")
+	}
+
+	named := base
+	named.name = "second"
+
+	if newCollector(base).coalesceKey() == newCollector(named).coalesceKey() {
+		t.Errorf("Different names produce identical keys")
+	}
+
+	labeled := base
+	labeled.labels = map[string]string{"site": "a"}
+
+	otherLabels := base
+	otherLabels.labels = map[string]string{"site": "b"}
+
+	if newCollector(labeled).coalesceKey() == newCollector(otherLabels).coalesceKey() {
+		t.Errorf("Different labels produce identical keys")
+	}
+
+	debug := base
+	debug.debug = true
+
+	if newCollector(base).coalesceKey() == newCollector(debug).coalesceKey() {
+		t.Errorf("Debug state does not affect key")
+	}
+}
@@ -9,7 +9,9 @@ import (
 	"net/url"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/hansmi/wp2reg-luxws/luxwsclient"
@@ -29,39 +31,151 @@ func findContentItem(r *luxwsclient.ContentRoot, name string) (*luxwsclient.Cont
 	return found, nil
 }
 
-type contentCollectFunc func(chan<- prometheus.Metric, *luxwsclient.ContentRoot, *quirks) error
+type contentCollectFunc func(chan<- reading, *luxwsclient.ContentRoot, *quirks) error
+
+// contentSection is a named part of the information page.
+type contentSection struct {
+	name string
+	fn   contentCollectFunc
+}
+
+// sectionNames returns the names of all known content sections in collection
+// order.
+func sectionNames() []string {
+	var names []string
+
+	for _, s := range (&collector{}).contentSections() {
+		names = append(names, s.name)
+	}
+
+	return names
+}
 
 type collector struct {
+	verbose               bool
 	sem                   *semaphore.Weighted
 	timeout               time.Duration
 	address               string
+	password              string
+	sections              map[string]bool
+	coalescer             *coalescer
+	generic               genericOpts
 	clientOpts            []luxwsclient.Option
+	transportMetrics      *transportMetrics
 	httpAddress           string
 	loc                   *time.Location
 	terms                 *luxwslang.Terminology
-	upDesc                *prometheus.Desc
-	infoDesc              *prometheus.Desc
-	temperatureDesc       *prometheus.Desc
-	operatingDurationDesc *prometheus.Desc
-	elapsedDurationDesc   *prometheus.Desc
-	inputDesc             *prometheus.Desc
-	outputDesc            *prometheus.Desc
-	opModeDesc            *prometheus.Desc
-	heatQuantityDesc      *prometheus.Desc
-	suppliedHeatDesc      *prometheus.Desc
-	latestErrorDesc       *prometheus.Desc
-	switchOffDesc         *prometheus.Desc
-	nodeTimeDesc          *prometheus.Desc
+	upDesc                *readingDesc
+	infoDesc              *readingDesc
+	temperatureDesc       *readingDesc
+	operatingDurationDesc *readingDesc
+	elapsedDurationDesc   *readingDesc
+	inputDesc             *readingDesc
+	outputDesc            *readingDesc
+	opModeDesc            *readingDesc
+	heatQuantityDesc      *readingDesc
+	latestErrorDesc       *readingDesc
+	switchOffDesc         *readingDesc
+	nodeTimeDesc          *readingDesc
+	genericValueDesc      *readingDesc
+	genericTextDesc       *readingDesc
+
+	suppliedHeatDesc         *readingDesc
+	suppliedHeatTotalDesc    *readingDesc
+	counterResetsDesc        *readingDesc
+	compressorStartsDesc     *readingDesc
+	compressorAvgRuntimeDesc *readingDesc
+	compressorStartRateDesc  *readingDesc
+	opModeStateDesc          *readingDesc
+	opModeCodeDesc           *readingDesc
+	tempSpreadDesc           *readingDesc
+	thermalPowerDesc         *readingDesc
+	powerInputDesc           *readingDesc
+	copDesc                  *readingDesc
+	defrostCyclesDesc        *readingDesc
+	defrostLastDurationDesc  *readingDesc
+	defrostDurationDesc      *readingDesc
+	defrostActiveDesc        *readingDesc
+	sectionUpDesc            *readingDesc
+	parseErrorsDesc          *readingDesc
+
+	layout            metricLayout
+	temperatureUnits  unitFamilies
+	inputUnits        unitFamilies
+	outputUnits       unitFamilies
+	heatOutputUnits   unitFamilies
+	suppliedHeatUnits unitFamilies
+
+	now func() time.Time
+
+	// State kept between collections
+	mu       sync.Mutex
+	impulses map[string]impulseSample
+
+	countersMu sync.Mutex
+	counters   map[counterKey]*counterState
+
+	parseErrorsMu sync.Mutex
+	parseErrors   map[parseErrorKey]float64
+
+	contactMu sync.Mutex
+	contact   contactStatus
+
+	// Details of the most recent collection; only set if enabled.
+	debug *debugRecorder
+
+	// Only set when polling.
+	defrost *defrostDetector
+
+	// Receive the snapshots of scrapes and polls.
+	sinks *sinkSet
+
+	name   string
+	labels map[string]string
 }
 
 type collectorOpts struct {
 	verbose       bool
 	maxConcurrent int64
-	timeout       time.Duration
-	address       string
-	httpAddress   string
-	loc           *time.Location
-	terms         *luxwslang.Terminology
+
+	// Semaphore shared with other collectors. If not set, a new semaphore
+	// allowing maxConcurrent collections is created.
+	sem *semaphore.Weighted
+
+	timeout     time.Duration
+	address     string
+	password    string
+	httpAddress string
+	loc         *time.Location
+	terms       *luxwslang.Terminology
+
+	// Names of content sections to collect; all sections are collected if
+	// empty.
+	sections []string
+
+	// Concurrent scrapes are coalesced if set.
+	coalescer *coalescer
+
+	generic genericOpts
+
+	layout metricLayout
+
+	defrost defrostOpts
+
+	// Communication with the controller is measured if set.
+	transportMetrics *transportMetrics
+
+	// Keep details of the most recent collection for debugging.
+	debug bool
+
+	// Name of the controller in snapshots.
+	name string
+
+	// Labels identifying the controller in snapshots.
+	labels map[string]string
+
+	// Snapshots of every collection are passed to the sinks.
+	sinks *sinkSet
 }
 
 func newCollector(opts collectorOpts) *collector {
@@ -71,48 +185,157 @@ func newCollector(opts collectorOpts) *collector {
 		clientOpts = append(clientOpts, luxwsclient.WithLogFunc(log.Printf))
 	}
 
-	if opts.maxConcurrent < 1 {
-		opts.maxConcurrent = 1
+	if opts.transportMetrics != nil {
+		clientOpts = append(clientOpts, opts.transportMetrics.clientOptions(opts.address)...)
+	}
+
+	if opts.sem == nil {
+		if opts.maxConcurrent < 1 {
+			opts.maxConcurrent = 1
+		}
+
+		opts.sem = semaphore.NewWeighted(opts.maxConcurrent)
+	}
+
+	var sections map[string]bool
+
+	if len(opts.sections) > 0 {
+		sections = map[string]bool{}
+
+		for _, name := range opts.sections {
+			sections[name] = true
+		}
 	}
 
 	return &collector{
-		sem:                   semaphore.NewWeighted(opts.maxConcurrent),
+		verbose:               opts.verbose,
+		sem:                   opts.sem,
 		timeout:               opts.timeout,
 		address:               opts.address,
+		password:              opts.password,
+		sections:              sections,
+		coalescer:             opts.coalescer,
+		generic:               opts.generic,
 		clientOpts:            clientOpts,
+		transportMetrics:      opts.transportMetrics,
 		httpAddress:           opts.httpAddress,
 		loc:                   opts.loc,
 		terms:                 opts.terms,
-		upDesc:                prometheus.NewDesc("luxws_up", "Whether scrape was successful", []string{"status"}, nil),
-		temperatureDesc:       prometheus.NewDesc("luxws_temperature", "Sensor temperature", []string{"name", "unit"}, nil),
-		operatingDurationDesc: prometheus.NewDesc("luxws_operating_duration_seconds", "Operating time", []string{"name"}, nil),
-		elapsedDurationDesc:   prometheus.NewDesc("luxws_elapsed_duration_seconds", "Elapsed time", []string{"name"}, nil),
-		inputDesc:             prometheus.NewDesc("luxws_input", "Input values", []string{"name", "unit"}, nil),
-		outputDesc:            prometheus.NewDesc("luxws_output", "Output values", []string{"name", "unit"}, nil),
-		infoDesc:              prometheus.NewDesc("luxws_info", "Controller information", []string{"swversion", "hptype"}, nil),
-		opModeDesc:            prometheus.NewDesc("luxws_operational_mode", "Operational mode", []string{"mode"}, nil),
-		heatQuantityDesc:      prometheus.NewDesc("luxws_heat_quantity", "Heat quantity", []string{"unit"}, nil),
-		suppliedHeatDesc:      prometheus.NewDesc("luxws_supplied_heat", "Supplied heat", []string{"name", "unit"}, nil),
-		latestErrorDesc:       prometheus.NewDesc("luxws_latest_error", "Latest error", []string{"reason"}, nil),
-		switchOffDesc:         prometheus.NewDesc("luxws_latest_switchoff", "Latest switch-off", []string{"reason"}, nil),
-		nodeTimeDesc:          prometheus.NewDesc("luxws_node_time_seconds", "System time in seconds since epoch (1970)", nil, nil),
+		upDesc:                newReadingDesc("luxws_up", "Whether scrape was successful", []string{"status"}, readingGauge),
+		temperatureDesc:       newReadingDesc("luxws_temperature", "Sensor temperature", []string{"name", "unit"}, readingGauge),
+		operatingDurationDesc: newReadingDesc("luxws_operating_duration_seconds", "Operating time", []string{"name"}, readingGauge),
+		elapsedDurationDesc:   newReadingDesc("luxws_elapsed_duration_seconds", "Elapsed time", []string{"name"}, readingGauge),
+		inputDesc:             newReadingDesc("luxws_input", "Input values", []string{"name", "unit"}, readingGauge),
+		outputDesc:            newReadingDesc("luxws_output", "Output values", []string{"name", "unit"}, readingGauge),
+		infoDesc:              newReadingDesc("luxws_info", "Controller information", []string{"swversion", "hptype"}, readingGauge),
+		opModeDesc:            newReadingDesc("luxws_operational_mode", "Operational mode", []string{"mode"}, readingGauge),
+		heatQuantityDesc:      newReadingDesc("luxws_heat_quantity", "Heat quantity", []string{"unit"}, readingGauge),
+		latestErrorDesc:       newReadingDesc("luxws_latest_error", "Latest error", []string{"reason"}, readingGauge),
+		switchOffDesc:         newReadingDesc("luxws_latest_switchoff", "Latest switch-off", []string{"reason"}, readingGauge),
+		nodeTimeDesc:          newReadingDesc("luxws_node_time_seconds", "System time in seconds since epoch (1970)", nil, readingGauge),
+		genericValueDesc:      newReadingDesc("luxws_value", "Numeric value of an item", []string{"path", "unit"}, readingGauge),
+		genericTextDesc:       newReadingDesc("luxws_text_info", "Textual value of an item", []string{"path", "value"}, readingGauge),
+		suppliedHeatDesc:      newReadingDesc("luxws_supplied_heat", "Supplied heat", []string{"name", "unit"}, readingGauge),
+		suppliedHeatTotalDesc: newReadingDesc(suppliedHeatTotalName,
+			"Cumulative supplied heat", []string{"name", "unit"}, readingCounter),
+		counterResetsDesc: newReadingDesc("luxws_counter_resets_total",
+			"Number of detected resets of cumulative values reported by the controller", []string{"metric", "name"}, readingCounter),
+		compressorStartsDesc: newReadingDesc(compressorStartsName,
+			"Number of compressor starts", []string{"name"}, readingCounter),
+		compressorAvgRuntimeDesc: newReadingDesc("luxws_compressor_average_runtime_seconds",
+			"Average compressor runtime per start, computed from operating hours and starts", []string{"name"}, readingGauge),
+		compressorStartRateDesc: newReadingDesc("luxws_compressor_starts_per_hour",
+			"Compressor starts per hour since the previous collection", []string{"name"}, readingGauge),
+		opModeStateDesc: newReadingDesc(opModeStateName,
+			"Operational mode as a state set (1 for the current mode)", []string{opModeStateName}, readingGauge),
+		opModeCodeDesc: newReadingDesc("luxws_operational_mode_code",
+			"Language-independent operational mode code (-1 if unknown)", nil, readingGauge),
+		tempSpreadDesc: newReadingDesc("luxws_temperature_spread_kelvins",
+			"Difference between flow and return temperature", nil, readingGauge),
+		thermalPowerDesc: newReadingDesc("luxws_thermal_power_watts",
+			"Thermal power computed from flow rate and temperature spread", nil, readingGauge),
+		powerInputDesc: newReadingDesc("luxws_power_input_watts",
+			"Electrical power input", nil, readingGauge),
+		copDesc: newReadingDesc("luxws_coefficient_of_performance",
+			"Instantaneous coefficient of performance (heat output divided by electrical power input)", nil, readingGauge),
+		defrostCyclesDesc: newReadingDesc("luxws_defrost_cycles_total",
+			"Number of completed defrost cycles", nil, readingCounter),
+		defrostLastDurationDesc: newReadingDesc("luxws_defrost_last_duration_seconds",
+			"Duration of the most recent completed defrost cycle", nil, readingGauge),
+		defrostDurationDesc: newReadingDesc("luxws_defrost_duration_seconds",
+			"Duration of completed defrost cycles", nil, readingHistogram),
+		defrostActiveDesc: newReadingDesc("luxws_defrost_active",
+			"Whether a defrost cycle is in progress", nil, readingGauge),
+		sectionUpDesc: newReadingDesc("luxws_section_up",
+			"Whether a section of the information page was collected", []string{"section"}, readingGauge),
+		parseErrorsDesc: newReadingDesc("luxws_parse_errors_total",
+			"Number of items skipped due to unparseable values", []string{"section", "reason"}, readingCounter),
+		layout:            opts.layout,
+		temperatureUnits:  newUnitFamilies("luxws_temperature", "Sensor temperature", false, readingGauge, []string{"name"}),
+		inputUnits:        newUnitFamilies("luxws_input", "Input value", true, readingGauge, []string{"name"}),
+		outputUnits:       newUnitFamilies("luxws_output", "Output value", true, readingGauge, []string{"name"}),
+		heatOutputUnits:   newUnitFamilies("luxws_heat_output", "Current heat output", false, readingGauge, nil),
+		suppliedHeatUnits: newUnitFamilies("luxws_supplied_heat", "Supplied heat", false, readingCounter, []string{"name"}),
+		now:               time.Now,
+		impulses:          map[string]impulseSample{},
+		counters:          map[counterKey]*counterState{},
+		parseErrors:       map[parseErrorKey]float64{},
+		debug:             newDebugRecorder(opts.debug),
+		defrost:           newDefrostDetectorOrLog(opts.defrost),
+		name:              opts.name,
+		labels:            opts.labels,
+		sinks:             opts.sinks,
 	}
 }
 
 func (c *collector) Describe(ch chan<- *prometheus.Desc) {
-	ch <- c.upDesc
-	ch <- c.infoDesc
-	ch <- c.temperatureDesc
-	ch <- c.operatingDurationDesc
-	ch <- c.elapsedDurationDesc
-	ch <- c.inputDesc
-	ch <- c.outputDesc
-	ch <- c.opModeDesc
-	ch <- c.heatQuantityDesc
-	ch <- c.suppliedHeatDesc
-	ch <- c.latestErrorDesc
-	ch <- c.switchOffDesc
-	ch <- c.nodeTimeDesc
+	ch <- c.upDesc.prom
+	ch <- c.infoDesc.prom
+	ch <- c.operatingDurationDesc.prom
+	ch <- c.elapsedDurationDesc.prom
+	ch <- c.opModeDesc.prom
+	ch <- c.opModeStateDesc.prom
+	ch <- c.opModeCodeDesc.prom
+	ch <- c.tempSpreadDesc.prom
+	ch <- c.thermalPowerDesc.prom
+	ch <- c.powerInputDesc.prom
+	ch <- c.copDesc.prom
+
+	if c.defrost != nil {
+		ch <- c.defrostCyclesDesc.prom
+		ch <- c.defrostLastDurationDesc.prom
+		ch <- c.defrostDurationDesc.prom
+		ch <- c.defrostActiveDesc.prom
+	}
+	ch <- c.counterResetsDesc.prom
+	ch <- c.sectionUpDesc.prom
+	ch <- c.parseErrorsDesc.prom
+	ch <- c.compressorStartsDesc.prom
+	ch <- c.compressorAvgRuntimeDesc.prom
+	ch <- c.compressorStartRateDesc.prom
+
+	switch c.layout {
+	case layoutUnits:
+		c.temperatureUnits.describe(ch)
+		c.inputUnits.describe(ch)
+		c.outputUnits.describe(ch)
+		c.heatOutputUnits.describe(ch)
+		c.suppliedHeatUnits.describe(ch)
+
+	default:
+		ch <- c.temperatureDesc.prom
+		ch <- c.inputDesc.prom
+		ch <- c.outputDesc.prom
+		ch <- c.heatQuantityDesc.prom
+		ch <- c.suppliedHeatDesc.prom
+		ch <- c.suppliedHeatTotalDesc.prom
+	}
+
+	ch <- c.latestErrorDesc.prom
+	ch <- c.switchOffDesc.prom
+	ch <- c.nodeTimeDesc.prom
+	ch <- c.genericValueDesc.prom
+	ch <- c.genericTextDesc.prom
 }
 
 func (c *collector) parseValue(text string) (float64, string, error) {
@@ -129,10 +352,12 @@ func (c *collector) parseValue(text string) (float64, string, error) {
 	return c.terms.ParseMeasurement(text)
 }
 
-func (c *collector) collectInfo(ch chan<- prometheus.Metric, content *luxwsclient.ContentRoot, q *quirks) error {
+func (c *collector) collectInfo(ch chan<- reading, content *luxwsclient.ContentRoot, q *quirks) error {
 	var swVersion, opMode, heatOutputUnit string
 	var heatOutputValue float64
+	var heatOutputFound bool
 	var hpType []string
+	var itemErr error
 
 	group, err := findContentItem(content, c.terms.NavSystemStatus)
 	if err != nil {
@@ -148,9 +373,7 @@ func (c *collector) collectInfo(ch chan<- prometheus.Metric, content *luxwsclien
 		case c.terms.StatusType:
 			name := normalizeSpace(*item.Value)
 
-			if strings.EqualFold(name, "L2A") {
-				q.missingSuppliedHeat = true
-			}
+			q.observeType(name)
 
 			hpType = append(hpType, name)
 		case c.terms.StatusSoftwareVersion:
@@ -159,32 +382,50 @@ func (c *collector) collectInfo(ch chan<- prometheus.Metric, content *luxwsclien
 			opMode = normalizeSpace(*item.Value)
 		case c.terms.StatusPowerOutput:
 			if heatOutputValue, heatOutputUnit, err = c.parseValue(*item.Value); err != nil {
-				return fmt.Errorf("parsing heat output failed: %w", err)
+				multierr.AppendInto(&itemErr, newMeasurementError(item.Name,
+					fmt.Errorf("parsing heat output failed: %w", err)))
+				heatOutputValue, heatOutputUnit = 0, ""
+				continue
 			}
+
+			heatOutputFound = true
 		}
 	}
 
 	sort.Strings(hpType)
 
-	ch <- prometheus.MustNewConstMetric(c.infoDesc, prometheus.GaugeValue,
-		1, swVersion, strings.Join(hpType, ", "))
+	ch <- newReading(c.infoDesc, 1, swVersion, strings.Join(hpType, ", "))
 
-	ch <- prometheus.MustNewConstMetric(c.opModeDesc, prometheus.GaugeValue,
-		1, opMode)
+	ch <- newReading(c.opModeDesc, 1, opMode)
 
-	ch <- prometheus.MustNewConstMetric(c.heatQuantityDesc, prometheus.GaugeValue,
-		heatOutputValue, heatOutputUnit)
+	c.collectOperationMode(ch, c.terms.ParseOperationMode(opMode))
 
-	return nil
+	if c.layout == layoutUnits {
+		if heatOutputFound {
+			if r, err := c.heatOutputUnits.newReading(heatOutputValue, heatOutputUnit); err != nil {
+				multierr.AppendInto(&itemErr, newItemError(reasonUnsupportedUnit, c.terms.StatusPowerOutput,
+					fmt.Errorf("heat output: %w", err)))
+			} else {
+				ch <- r
+			}
+		}
+	} else {
+		ch <- newReading(c.heatQuantityDesc, heatOutputValue, heatOutputUnit)
+	}
+
+	return itemErr
 }
 
-func (c *collector) collectMeasurements(ch chan<- prometheus.Metric, desc *prometheus.Desc, content *luxwsclient.ContentRoot, groupName string) error {
+// collectMeasurements exports all values of a group. The legacy descriptor is
+// used unless the units layout is selected.
+func (c *collector) collectMeasurements(ch chan<- reading, desc *readingDesc, families unitFamilies, content *luxwsclient.ContentRoot, groupName string) error {
 	group, err := findContentItem(content, groupName)
 	if err != nil {
 		return err
 	}
 
 	var found bool
+	var itemErr error
 
 	for _, item := range group.Items {
 		if item.Value == nil {
@@ -193,30 +434,41 @@ func (c *collector) collectMeasurements(ch chan<- prometheus.Metric, desc *prome
 
 		value, unit, err := c.parseValue(*item.Value)
 		if err != nil {
-			return err
+			multierr.AppendInto(&itemErr, newMeasurementError(item.Name, err))
+			continue
 		}
 
-		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue,
-			value, normalizeSpace(item.Name), unit)
+		if c.layout == layoutUnits {
+			r, err := families.newReading(value, unit, normalizeSpace(item.Name))
+			if err != nil {
+				multierr.AppendInto(&itemErr, newItemError(reasonUnsupportedUnit, item.Name, err))
+				continue
+			}
+
+			ch <- r
+		} else {
+			ch <- newReading(desc, value, normalizeSpace(item.Name), unit)
+		}
 
 		found = true
 	}
 
-	if !found {
-		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue,
-			0, "", "")
+	// Placeholder for consistent output in the legacy layout
+	if !found && c.layout != layoutUnits {
+		ch <- newReading(desc, 0, "", "")
 	}
 
-	return nil
+	return itemErr
 }
 
-func (c *collector) collectDurations(ch chan<- prometheus.Metric, desc *prometheus.Desc, content *luxwsclient.ContentRoot, groupName string, ignoreRe *regexp.Regexp) error {
+func (c *collector) collectDurations(ch chan<- reading, desc *readingDesc, content *luxwsclient.ContentRoot, groupName string, ignoreRe *regexp.Regexp) error {
 	group, err := findContentItem(content, groupName)
 	if err != nil {
 		return err
 	}
 
 	var found bool
+	var itemErr error
 
 	for _, item := range group.Items {
 		if item.Value == nil || (ignoreRe != nil && ignoreRe.MatchString(item.Name)) {
@@ -225,24 +477,23 @@ func (c *collector) collectDurations(ch chan<- prometheus.Metric, desc *promethe
 
 		duration, err := c.terms.ParseDuration(*item.Value)
 		if err != nil {
-			return err
+			multierr.AppendInto(&itemErr, newItemError(reasonInvalidDuration, item.Name, err))
+			continue
 		}
 
-		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue,
-			duration.Seconds(), normalizeSpace(item.Name))
+		ch <- newReading(desc, duration.Seconds(), normalizeSpace(item.Name))
 
 		found = true
 	}
 
 	if !found {
-		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue,
-			0, "")
+		ch <- newReading(desc, 0, "")
 	}
 
-	return nil
+	return itemErr
 }
 
-func (c *collector) collectTimetable(ch chan<- prometheus.Metric, desc *prometheus.Desc, content *luxwsclient.ContentRoot, groupName string) error {
+func (c *collector) collectTimetable(ch chan<- reading, desc *readingDesc, content *luxwsclient.ContentRoot, groupName string) error {
 	group, err := findContentItem(content, groupName)
 	if err != nil {
 		return err
@@ -250,6 +501,8 @@ func (c *collector) collectTimetable(ch chan<- prometheus.Metric, desc *promethe
 
 	latest := map[string]time.Time{}
 
+	var itemErr error
+
 	for _, item := range group.Items {
 		tsRaw := normalizeSpace(item.Name)
 
@@ -259,7 +512,8 @@ func (c *collector) collectTimetable(ch chan<- prometheus.Metric, desc *promethe
 
 		ts, err := c.terms.ParseTimestamp(tsRaw, c.loc)
 		if err != nil {
-			return err
+			multierr.AppendInto(&itemErr, newItemError(reasonInvalidTimestamp, tsRaw, err))
+			continue
 		}
 
 		reason := normalizeSpace(*item.Value)
@@ -271,100 +525,181 @@ func (c *collector) collectTimetable(ch chan<- prometheus.Metric, desc *promethe
 	}
 
 	if len(latest) == 0 {
-		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 0, "")
+		ch <- newReading(desc, 0, "")
 	} else {
 		for reason, ts := range latest {
-			ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(ts.Unix()), reason)
+			ch <- newReading(desc, float64(ts.Unix()), reason)
 		}
 	}
 
-	return nil
+	return itemErr
 }
 
-func (c *collector) collectTemperatures(ch chan<- prometheus.Metric, content *luxwsclient.ContentRoot, _ *quirks) error {
-	return c.collectMeasurements(ch, c.temperatureDesc, content, c.terms.NavTemperatures)
+func (c *collector) collectTemperatures(ch chan<- reading, content *luxwsclient.ContentRoot, _ *quirks) error {
+	return c.collectMeasurements(ch, c.temperatureDesc, c.temperatureUnits, content, c.terms.NavTemperatures)
 }
 
-func (c *collector) collectOperatingDuration(ch chan<- prometheus.Metric, content *luxwsclient.ContentRoot, _ *quirks) error {
-	return c.collectDurations(ch, c.operatingDurationDesc, content, c.terms.NavOpHours, c.terms.HoursImpulsesRe)
+func (c *collector) collectOperatingDuration(ch chan<- reading, content *luxwsclient.ContentRoot, _ *quirks) error {
+	return multierr.Append(
+		c.collectDurations(ch, c.operatingDurationDesc, content, c.terms.NavOpHours, c.terms.HoursImpulsesRe),
+		c.collectImpulses(ch, content))
 }
 
-func (c *collector) collectElapsedTime(ch chan<- prometheus.Metric, content *luxwsclient.ContentRoot, _ *quirks) error {
+func (c *collector) collectElapsedTime(ch chan<- reading, content *luxwsclient.ContentRoot, _ *quirks) error {
 	return c.collectDurations(ch, c.elapsedDurationDesc, content, c.terms.NavElapsedTimes, nil)
 }
 
-func (c *collector) collectInputs(ch chan<- prometheus.Metric, content *luxwsclient.ContentRoot, _ *quirks) error {
-	return c.collectMeasurements(ch, c.inputDesc, content, c.terms.NavInputs)
+func (c *collector) collectInputs(ch chan<- reading, content *luxwsclient.ContentRoot, _ *quirks) error {
+	return c.collectMeasurements(ch, c.inputDesc, c.inputUnits, content, c.terms.NavInputs)
 }
 
-func (c *collector) collectOutputs(ch chan<- prometheus.Metric, content *luxwsclient.ContentRoot, _ *quirks) error {
-	return c.collectMeasurements(ch, c.outputDesc, content, c.terms.NavOutputs)
+func (c *collector) collectOutputs(ch chan<- reading, content *luxwsclient.ContentRoot, _ *quirks) error {
+	return c.collectMeasurements(ch, c.outputDesc, c.outputUnits, content, c.terms.NavOutputs)
 }
 
-func (c *collector) collectSuppliedHeat(ch chan<- prometheus.Metric, content *luxwsclient.ContentRoot, q *quirks) error {
+func (c *collector) collectSuppliedHeat(ch chan<- reading, content *luxwsclient.ContentRoot, q *quirks) error {
 	if q.missingSuppliedHeat {
 		return nil
 	}
 
-	return c.collectMeasurements(ch, c.suppliedHeatDesc, content, c.terms.NavHeatQuantity)
+	if c.layout != layoutLegacy {
+		return c.collectHeatCounters(ch, content)
+	}
+
+	// The gauges are kept in the legacy layout for existing dashboards
+	err := c.collectMeasurements(ch, c.suppliedHeatDesc, c.suppliedHeatUnits, content, c.terms.NavHeatQuantity)
+
+	// Unparseable items were already reported for the gauges
+	_, counterErr := splitItemErrors(c.collectHeatCounters(ch, content))
+
+	return multierr.Append(err, counterErr)
 }
 
-func (c *collector) collectLatestError(ch chan<- prometheus.Metric, content *luxwsclient.ContentRoot, _ *quirks) error {
+func (c *collector) collectLatestError(ch chan<- reading, content *luxwsclient.ContentRoot, _ *quirks) error {
 	return c.collectTimetable(ch, c.latestErrorDesc, content, c.terms.NavErrorMemory)
 }
 
-func (c *collector) collectLatestSwitchOff(ch chan<- prometheus.Metric, content *luxwsclient.ContentRoot, _ *quirks) error {
+func (c *collector) collectLatestSwitchOff(ch chan<- reading, content *luxwsclient.ContentRoot, _ *quirks) error {
 	return c.collectTimetable(ch, c.switchOffDesc, content, c.terms.NavSwitchOffs)
 }
 
-func (c *collector) collectAll(ch chan<- prometheus.Metric, content *luxwsclient.ContentRoot) error {
-	var err error
-	var q quirks
+func (c *collector) contentSections() []contentSection {
+	return []contentSection{
+		// Must be first as it detects quirks.
+		{"info", c.collectInfo},
+		{"temperatures", c.collectTemperatures},
+		{"operating_duration", c.collectOperatingDuration},
+		{"elapsed_time", c.collectElapsedTime},
+		{"inputs", c.collectInputs},
+		{"outputs", c.collectOutputs},
+		{"supplied_heat", c.collectSuppliedHeat},
+		{"latest_error", c.collectLatestError},
+		{"latest_switchoff", c.collectLatestSwitchOff},
+		{"derived", c.collectDerived},
+	}
+}
 
-	for _, fn := range []contentCollectFunc{
-		c.collectInfo,
-		c.collectTemperatures,
-		c.collectOperatingDuration,
-		c.collectElapsedTime,
-		c.collectInputs,
-		c.collectOutputs,
-		c.collectSuppliedHeat,
-		c.collectLatestError,
-		c.collectLatestSwitchOff,
-	} {
-		multierr.AppendInto(&err, fn(ch, content, &q))
+// detectQuirks determines quirks without collecting any metrics. Used when
+// the info section is disabled.
+func (c *collector) detectQuirks(content *luxwsclient.ContentRoot, q *quirks) {
+	if group := content.FindByName(c.terms.NavSystemStatus); group != nil {
+		for _, item := range group.Items {
+			if item.Value != nil && item.Name == c.terms.StatusType {
+				q.observeType(normalizeSpace(*item.Value))
+			}
+		}
 	}
+}
+
+func (c *collector) collectAll(ch chan<- reading, content *luxwsclient.ContentRoot) error {
+	_, err := c.collectContent(ch, content)
 
 	return err
 }
 
-func (c *collector) collectWebSocket(ctx context.Context, ch chan<- prometheus.Metric) error {
-	cl, err := luxwsclient.Dial(ctx, c.address, c.clientOpts...)
-	if err != nil {
-		return err
+// collectContent collects all enabled sections. Skipped items are returned
+// in addition to errors affecting whole sections.
+func (c *collector) collectContent(ch chan<- reading, content *luxwsclient.ContentRoot) ([]skippedItem, error) {
+	var err error
+	var q quirks
+	var skipped []skippedItem
+
+	if c.sections != nil && !c.sections["info"] {
+		c.detectQuirks(content, &q)
 	}
 
-	defer cl.Close()
+	for _, s := range c.contentSections() {
+		if c.sections != nil && !c.sections[s.name] {
+			continue
+		}
+
+		items, sectionErr := c.collectSection(ch, s, content, &q)
+
+		skipped = append(skipped, items...)
+		multierr.AppendInto(&err, withStatus(statusParse, sectionErr))
+	}
+
+	c.collectCounterResets(ch)
+	c.collectParseErrors(ch)
 
-	nav, err := cl.Login(ctx, "")
+	if c.defrost != nil {
+		multierr.AppendInto(&err, withStatus(statusState, c.collectDefrost(ch, content)))
+	}
+
+	return skipped, err
+}
+
+// collectWebSocket collects all metrics available via the LuxWS protocol.
+// The values of the information page are stored in the snapshot if given.
+func (c *collector) collectWebSocket(ctx context.Context, ch chan<- reading, snap *snapshot) (err error) {
+	var snapshot *debugSnapshot
+	var clientOpts []luxwsclient.Option
+
+	if c.debug != nil {
+		snapshot = newDebugSnapshot(c.now())
+		clientOpts = append(clientOpts, luxwsclient.WithResponseHook(snapshot.addResponse))
+
+		defer func() {
+			snapshot.err = err
+			c.debug.set(snapshot)
+		}()
+	}
+
+	cl, nav, err := c.connect(ctx, clientOpts...)
 	if err != nil {
 		return err
 	}
 
+	defer cl.Close()
+
 	info := nav.FindByName(c.terms.NavInformation)
 	if info == nil {
-		return errors.New("information ID not found in response")
+		return withStatus(statusFetch, errors.New("information ID not found in response"))
 	}
 
 	content, err := cl.Get(ctx, info.ID)
 	if err != nil {
-		return fmt.Errorf("fetching ID %q failed: %w", info.ID, err)
+		return withStatus(statusFetch, fmt.Errorf("fetching ID %q failed: %w", info.ID, err))
+	}
+
+	skipped, err := c.collectContent(ch, content)
+
+	snapshot.setContent(content, skipped)
+
+	if snap != nil {
+		snap.values = flattenAPINodes(c.apiNodes("", content.Items))
+	}
+
+	if c.generic.enabled {
+		multierr.AppendInto(&err, withStatus(statusFetch, c.collectGeneric(ctx, ch, cl, nav, map[string]*luxwsclient.ContentRoot{
+			info.ID: content,
+		})))
 	}
 
-	return c.collectAll(ch, content)
+	return err
 }
 
-func (c *collector) collectHTTP(ctx context.Context, ch chan<- prometheus.Metric) error {
+func (c *collector) collectHTTP(ctx context.Context, ch chan<- reading) error {
 	url := url.URL{
 		Scheme: "http",
 		Host:   c.httpAddress,
@@ -373,31 +708,37 @@ func (c *collector) collectHTTP(ctx context.Context, ch chan<- prometheus.Metric
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url.String(), nil)
 	if err != nil {
-		return err
+		return withStatus(statusHTTP, err)
 	}
 
+	start := time.Now()
 	resp, err := http.DefaultClient.Do(req)
 
+	if c.transportMetrics != nil {
+		c.transportMetrics.observeHTTP(c.address, time.Since(start), err)
+	}
+
 	if err != nil {
-		return err
+		return withStatus(statusHTTP, err)
 	}
 
+	defer resp.Body.Close()
+
 	if dateHeader := resp.Header.Get("Date"); dateHeader != "" {
 		ts, err := http.ParseTime(dateHeader)
 		if err != nil {
-			return err
+			return withStatus(statusHTTP, err)
 		}
 
-		ch <- prometheus.MustNewConstMetric(c.nodeTimeDesc, prometheus.GaugeValue,
-			float64(ts.Unix()))
+		ch <- newReading(c.nodeTimeDesc, float64(ts.Unix()))
 	} else {
-		return errors.New("HTTP header missing server time")
+		return withStatus(statusHTTP, errors.New("HTTP header missing server time"))
 	}
 
 	return nil
 }
 
-func (c *collector) collect(ctx context.Context, ch chan<- prometheus.Metric) error {
+func (c *collector) collect(ctx context.Context, ch chan<- reading, snap *snapshot) error {
 	// Limit concurrent collections
 	if err := c.sem.Acquire(ctx, 1); err != nil {
 		return err
@@ -408,7 +749,7 @@ func (c *collector) collect(ctx context.Context, ch chan<- prometheus.Metric) er
 	g, ctx := errgroup.WithContext(ctx)
 
 	g.Go(func() error {
-		if err := c.collectWebSocket(ctx, ch); err != nil {
+		if err := c.collectWebSocket(ctx, ch, snap); err != nil {
 			return fmt.Errorf("collection via LuxWS protocol failed: %w", err)
 		}
 
@@ -428,14 +769,96 @@ func (c *collector) collect(ctx context.Context, ch chan<- prometheus.Metric) er
 	return g.Wait()
 }
 
+// snapshot performs a collection and returns its result.
+func (c *collector) snapshot(ctx context.Context) *snapshot {
+	snap := &snapshot{
+		controller: c.name,
+		labels:     c.labels,
+		time:       c.now(),
+	}
+
+	snap.readings, snap.err = collectReadings(func(ch chan<- reading) error {
+		return c.collect(ctx, ch, snap)
+	})
+	snap.up = c.upReading(snap.err)
+
+	return snap
+}
+
+// upReading returns the scrape status for the outcome of a collection.
+func (c *collector) upReading(err error) reading {
+	if err == nil {
+		return newReading(c.upDesc, 1, statusOK)
+	}
+
+	return newReading(c.upDesc, 0, errorStatus(err))
+}
+
+// publish passes a snapshot to all sinks. Failing sinks are only logged.
+func (c *collector) publish(ctx context.Context, snap *snapshot) {
+	if err := c.sinks.Consume(ctx, snap); err != nil {
+		log.Printf("Output failed: %v", err)
+	}
+}
+
+// scrape performs a collection on behalf of a scrape and publishes the
+// result.
+func (c *collector) scrape(ctx context.Context) *snapshot {
+	snap := c.snapshot(ctx)
+
+	c.publish(ctx, snap)
+
+	return snap
+}
+
+// coalesceKey returns a string identifying all parameters affecting the
+// result of a collection.
+func (c *collector) coalesceKey() string {
+	var sections []string
+
+	for name := range c.sections {
+		sections = append(sections, name)
+	}
+
+	sort.Strings(sections)
+
+	// The name and labels are stamped into the snapshot and debug details are
+	// only recorded when enabled, so they must not be shared across collectors
+	// differing in them.
+	var labels []string
+
+	for name, value := range c.labels {
+		labels = append(labels, name+"="+value)
+	}
+
+	sort.Strings(labels)
+
+	return strings.Join([]string{
+		c.address, c.httpAddress, c.password, c.terms.ID, c.loc.String(),
+		strings.Join(sections, ","),
+		c.name, strings.Join(labels, ","), strconv.FormatBool(c.debug != nil),
+	}, "\x00")
+}
+
 func (c *collector) Collect(ch chan<- prometheus.Metric) {
-	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
-	defer cancel()
+	var snap *snapshot
 
-	if err := c.collect(ctx, ch); err == nil {
-		ch <- prometheus.MustNewConstMetric(c.upDesc, prometheus.GaugeValue, 1, "")
+	if c.coalescer != nil {
+		snap = c.coalescer.do(c.coalesceKey(), c.address, c.timeout, c.scrape)
 	} else {
-		log.Printf("Scrape failed: %v", err)
-		ch <- prometheus.MustNewConstMetric(c.upDesc, prometheus.GaugeValue, 0, err.Error())
+		ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+		defer cancel()
+
+		snap = c.scrape(ctx)
+	}
+
+	for _, r := range snap.readings {
+		ch <- r.metric()
+	}
+
+	if snap.err != nil {
+		log.Printf("Scrape failed: %v", snap.err)
 	}
+
+	ch <- snap.up.metric()
 }
@@ -36,7 +36,7 @@ type adapter struct {
 
 	metricNames []string
 
-	collect    func(ch chan<- prometheus.Metric) error
+	collect    func(ch chan<- reading) error
 	collectErr error
 }
 
@@ -45,7 +45,13 @@ func (a *adapter) Describe(ch chan<- *prometheus.Desc) {
 }
 
 func (a *adapter) Collect(ch chan<- prometheus.Metric) {
-	a.collectErr = a.collect(ch)
+	var readings []reading
+
+	readings, a.collectErr = collectReadings(a.collect)
+
+	for _, r := range readings {
+		ch <- r.metric()
+	}
 }
 
 func (a *adapter) collectAndCompare(t *testing.T, want string, wantErr error) {
@@ -92,6 +98,19 @@ luxws_info{hptype="",swversion=""} 1
 # HELP luxws_operational_mode Operational mode
 # TYPE luxws_operational_mode gauge
 luxws_operational_mode{mode=""} 1
+# HELP luxws_operational_mode_code Language-independent operational mode code (-1 if unknown)
+# TYPE luxws_operational_mode_code gauge
+luxws_operational_mode_code -1
+# HELP luxws_operational_mode_state Operational mode as a state set (1 for the current mode)
+# TYPE luxws_operational_mode_state gauge
+luxws_operational_mode_state{luxws_operational_mode_state="cooling"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="defrost"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="evu_block"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="heating"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="heating_external"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="hot_water"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="off"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="swimming_pool"} 0
 # HELP luxws_heat_quantity Heat quantity
 # TYPE luxws_heat_quantity gauge
 luxws_heat_quantity{unit=""} 0
@@ -121,9 +140,60 @@ luxws_info{hptype="typeA, typeB",swversion="v1.2.3"} 1
 # HELP luxws_operational_mode Operational mode
 # TYPE luxws_operational_mode gauge
 luxws_operational_mode{mode="running"} 1
+# HELP luxws_operational_mode_code Language-independent operational mode code (-1 if unknown)
+# TYPE luxws_operational_mode_code gauge
+luxws_operational_mode_code -1
+# HELP luxws_operational_mode_state Operational mode as a state set (1 for the current mode)
+# TYPE luxws_operational_mode_state gauge
+luxws_operational_mode_state{luxws_operational_mode_state="cooling"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="defrost"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="evu_block"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="heating"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="heating_external"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="hot_water"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="off"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="swimming_pool"} 0
 # HELP luxws_heat_quantity Heat quantity
 # TYPE luxws_heat_quantity gauge
 luxws_heat_quantity{unit="kWh"} 999
+`,
+		},
+		{
+			name: "info known operation mode",
+			fn:   c.collectInfo,
+			input: &luxwsclient.ContentRoot{
+				Items: []luxwsclient.ContentItem{
+					{
+						Name: "Anlagenstatus",
+						Items: []luxwsclient.ContentItem{
+							{Name: "Betriebszustand", Value: luxwsclient.String("Warmwasser")},
+						},
+					},
+				},
+			},
+			want: `
+# HELP luxws_info Controller information
+# TYPE luxws_info gauge
+luxws_info{hptype="",swversion=""} 1
+# HELP luxws_operational_mode Operational mode
+# TYPE luxws_operational_mode gauge
+luxws_operational_mode{mode="Warmwasser"} 1
+# HELP luxws_operational_mode_code Language-independent operational mode code (-1 if unknown)
+# TYPE luxws_operational_mode_code gauge
+luxws_operational_mode_code 1
+# HELP luxws_operational_mode_state Operational mode as a state set (1 for the current mode)
+# TYPE luxws_operational_mode_state gauge
+luxws_operational_mode_state{luxws_operational_mode_state="cooling"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="defrost"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="evu_block"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="heating"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="heating_external"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="hot_water"} 1
+luxws_operational_mode_state{luxws_operational_mode_state="off"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="swimming_pool"} 0
+# HELP luxws_heat_quantity Heat quantity
+# TYPE luxws_heat_quantity gauge
+luxws_heat_quantity{unit=""} 0
 `,
 		},
 		{
@@ -149,6 +219,19 @@ luxws_info{hptype="l2a",swversion="v1.86.2"} 1
 # HELP luxws_operational_mode Operational mode
 # TYPE luxws_operational_mode gauge
 luxws_operational_mode{mode="----"} 1
+# HELP luxws_operational_mode_code Language-independent operational mode code (-1 if unknown)
+# TYPE luxws_operational_mode_code gauge
+luxws_operational_mode_code -1
+# HELP luxws_operational_mode_state Operational mode as a state set (1 for the current mode)
+# TYPE luxws_operational_mode_state gauge
+luxws_operational_mode_state{luxws_operational_mode_state="cooling"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="defrost"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="evu_block"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="heating"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="heating_external"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="hot_water"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="off"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="swimming_pool"} 0
 # HELP luxws_heat_quantity Heat quantity
 # TYPE luxws_heat_quantity gauge
 luxws_heat_quantity{unit=""} 0
@@ -380,6 +463,10 @@ luxws_supplied_heat{name="",unit=""} 0
 # TYPE luxws_supplied_heat gauge
 luxws_supplied_heat{name="ice",unit="kW"} 100
 luxws_supplied_heat{name="water",unit="kW"} 200
+# HELP luxws_supplied_heat_total Cumulative supplied heat
+# TYPE luxws_supplied_heat_total counter
+luxws_supplied_heat_total{name="ice",unit="kW"} 100
+luxws_supplied_heat_total{name="water",unit="kW"} 200
 `,
 		},
 		{
@@ -483,7 +570,7 @@ luxws_latest_switchoff{reason="bbb"} 1585954800
 		t.Run(tc.name, func(t *testing.T) {
 			a := &adapter{
 				c: c,
-				collect: func(ch chan<- prometheus.Metric) error {
+				collect: func(ch chan<- reading) error {
 					return tc.fn(ch, tc.input, &tc.quirks)
 				},
 			}
@@ -498,15 +585,29 @@ luxws_latest_switchoff{reason="bbb"} 1585954800
 
 func TestCollectAll(t *testing.T) {
 	for _, tc := range []struct {
-		name    string
-		input   *luxwsclient.ContentRoot
-		want    string
-		wantErr error
+		name     string
+		sections []string
+		input    *luxwsclient.ContentRoot
+		want     string
+		wantErr  error
 	}{
 		{
-			name:    "empty",
-			input:   &luxwsclient.ContentRoot{},
-			want:    "",
+			name:  "empty",
+			input: &luxwsclient.ContentRoot{},
+			want: `
+# HELP luxws_section_up Whether a section of the information page was collected
+# TYPE luxws_section_up gauge
+luxws_section_up{section="derived"} 1
+luxws_section_up{section="elapsed_time"} 0
+luxws_section_up{section="info"} 0
+luxws_section_up{section="inputs"} 0
+luxws_section_up{section="latest_error"} 0
+luxws_section_up{section="latest_switchoff"} 0
+luxws_section_up{section="operating_duration"} 0
+luxws_section_up{section="outputs"} 0
+luxws_section_up{section="supplied_heat"} 0
+luxws_section_up{section="temperatures"} 0
+`,
 			wantErr: cmpopts.AnyError,
 		},
 		{
@@ -550,6 +651,19 @@ luxws_operating_duration_seconds{name=""} 0
 # HELP luxws_operational_mode Operational mode
 # TYPE luxws_operational_mode gauge
 luxws_operational_mode{mode=""} 1
+# HELP luxws_operational_mode_code Language-independent operational mode code (-1 if unknown)
+# TYPE luxws_operational_mode_code gauge
+luxws_operational_mode_code -1
+# HELP luxws_operational_mode_state Operational mode as a state set (1 for the current mode)
+# TYPE luxws_operational_mode_state gauge
+luxws_operational_mode_state{luxws_operational_mode_state="cooling"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="defrost"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="evu_block"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="heating"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="heating_external"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="hot_water"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="off"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="swimming_pool"} 0
 # HELP luxws_output Output values
 # TYPE luxws_output gauge
 luxws_output{name="",unit=""} 0
@@ -559,6 +673,18 @@ luxws_supplied_heat{name="",unit=""} 0
 # HELP luxws_temperature Sensor temperature
 # TYPE luxws_temperature gauge
 luxws_temperature{name="",unit=""} 0
+# HELP luxws_section_up Whether a section of the information page was collected
+# TYPE luxws_section_up gauge
+luxws_section_up{section="derived"} 1
+luxws_section_up{section="elapsed_time"} 1
+luxws_section_up{section="info"} 1
+luxws_section_up{section="inputs"} 1
+luxws_section_up{section="latest_error"} 1
+luxws_section_up{section="latest_switchoff"} 1
+luxws_section_up{section="operating_duration"} 1
+luxws_section_up{section="outputs"} 1
+luxws_section_up{section="supplied_heat"} 1
+luxws_section_up{section="temperatures"} 1
 `,
 		},
 		{
@@ -612,24 +738,120 @@ luxws_operating_duration_seconds{name=""} 0
 # HELP luxws_operational_mode Operational mode
 # TYPE luxws_operational_mode gauge
 luxws_operational_mode{mode=""} 1
+# HELP luxws_operational_mode_code Language-independent operational mode code (-1 if unknown)
+# TYPE luxws_operational_mode_code gauge
+luxws_operational_mode_code -1
+# HELP luxws_operational_mode_state Operational mode as a state set (1 for the current mode)
+# TYPE luxws_operational_mode_state gauge
+luxws_operational_mode_state{luxws_operational_mode_state="cooling"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="defrost"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="evu_block"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="heating"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="heating_external"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="hot_water"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="off"} 0
+luxws_operational_mode_state{luxws_operational_mode_state="swimming_pool"} 0
 # HELP luxws_output Output values
 # TYPE luxws_output gauge
 luxws_output{name="",unit=""} 0
 # HELP luxws_temperature Sensor temperature
 # TYPE luxws_temperature gauge
 luxws_temperature{name="",unit=""} 0
+# HELP luxws_section_up Whether a section of the information page was collected
+# TYPE luxws_section_up gauge
+luxws_section_up{section="derived"} 1
+luxws_section_up{section="elapsed_time"} 1
+luxws_section_up{section="info"} 1
+luxws_section_up{section="inputs"} 1
+luxws_section_up{section="latest_error"} 1
+luxws_section_up{section="latest_switchoff"} 1
+luxws_section_up{section="operating_duration"} 1
+luxws_section_up{section="outputs"} 1
+luxws_section_up{section="supplied_heat"} 1
+luxws_section_up{section="temperatures"} 1
+`,
+		},
+		{
+			name:     "selected sections",
+			sections: []string{"temperatures", "supplied_heat"},
+			input: &luxwsclient.ContentRoot{
+				Items: []luxwsclient.ContentItem{
+					{
+						Name: "system status",
+						Items: []luxwsclient.ContentItem{
+							{Name: "type of heat pump", Value: luxwsclient.String("L2A")},
+						},
+					},
+					{
+						Name: "temperatures",
+						Items: []luxwsclient.ContentItem{
+							{Name: "outside", Value: luxwsclient.String("3 °C")},
+						},
+					},
+				},
+			},
+			want: `
+# HELP luxws_temperature Sensor temperature
+# TYPE luxws_temperature gauge
+luxws_temperature{name="outside",unit="degC"} 3
+# HELP luxws_section_up Whether a section of the information page was collected
+# TYPE luxws_section_up gauge
+luxws_section_up{section="supplied_heat"} 1
+luxws_section_up{section="temperatures"} 1
+`,
+		},
+		{
+			name:     "unparseable items",
+			sections: []string{"temperatures", "elapsed_time"},
+			input: &luxwsclient.ContentRoot{
+				Items: []luxwsclient.ContentItem{
+					{
+						Name: "temperatures",
+						Items: []luxwsclient.ContentItem{
+							{Name: "outside", Value: luxwsclient.String("3 °C")},
+							{Name: "inside", Value: luxwsclient.String("20 °F")},
+							{Name: "flow", Value: luxwsclient.String("garbage")},
+							{Name: "return", Value: luxwsclient.String("30 °C")},
+						},
+					},
+					{
+						Name: "elapsed times",
+						Items: []luxwsclient.ContentItem{
+							{Name: "defrost", Value: luxwsclient.String("a while")},
+						},
+					},
+				},
+			},
+			want: `
+# HELP luxws_elapsed_duration_seconds Elapsed time
+# TYPE luxws_elapsed_duration_seconds gauge
+luxws_elapsed_duration_seconds{name=""} 0
+# HELP luxws_parse_errors_total Number of items skipped due to unparseable values
+# TYPE luxws_parse_errors_total counter
+luxws_parse_errors_total{reason="invalid_duration",section="elapsed_time"} 1
+luxws_parse_errors_total{reason="invalid_measurement",section="temperatures"} 1
+luxws_parse_errors_total{reason="unknown_unit",section="temperatures"} 1
+# HELP luxws_section_up Whether a section of the information page was collected
+# TYPE luxws_section_up gauge
+luxws_section_up{section="elapsed_time"} 1
+luxws_section_up{section="temperatures"} 1
+# HELP luxws_temperature Sensor temperature
+# TYPE luxws_temperature gauge
+luxws_temperature{name="outside",unit="degC"} 3
+luxws_temperature{name="return",unit="degC"} 30
 `,
 		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			c := newCollector(collectorOpts{
-				terms: luxwslang.English,
-				loc:   time.UTC,
+				terms:    luxwslang.English,
+				loc:      time.UTC,
+				sections: tc.sections,
 			})
 
 			a := &adapter{
 				c: c,
-				collect: func(ch chan<- prometheus.Metric) error {
+				collect: func(ch chan<- reading) error {
 					return c.collectAll(ch, tc.input)
 				},
 			}
@@ -666,7 +888,7 @@ luxws_node_time_seconds 1136214245
 
 	a := &adapter{
 		c: c,
-		collect: func(ch chan<- prometheus.Metric) error {
+		collect: func(ch chan<- reading) error {
 			return c.collectHTTP(ctx, ch)
 		},
 	}
@@ -695,20 +917,12 @@ func TestCollect(t *testing.T) {
 	want := `
 # HELP luxws_up Whether scrape was successful
 # TYPE luxws_up gauge
-luxws_up{status="collection via LuxWS protocol failed: websocket: bad handshake"} 0
+luxws_up{status="dial"} 0
 `
 
 	discardAllLogs(t)
 
-	a := &adapter{
-		c: c,
-		metricNames: []string{
-			"luxws_up",
-		},
-		collect: func(ch chan<- prometheus.Metric) error {
-			c.Collect(ch)
-			return nil
-		},
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want), "luxws_up"); err != nil {
+		t.Error(err)
 	}
-	a.collectAndCompare(t, want, nil)
 }
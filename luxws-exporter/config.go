@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hansmi/wp2reg-luxws/luxwslang"
+	"github.com/prometheus/common/model"
+	"go.yaml.in/yaml/v2"
+)
+
+// controllerConfig describes a single named controller in the configuration
+// file.
+type controllerConfig struct {
+	Address      string            `yaml:"address"`
+	HTTPAddress  string            `yaml:"http_address"`
+	Language     string            `yaml:"language"`
+	Timezone     string            `yaml:"timezone"`
+	PasswordFile string            `yaml:"password_file"`
+	Sections     []string          `yaml:"sections"`
+	Labels       map[string]string `yaml:"labels"`
+}
+
+// config is the top-level structure of the configuration file.
+type config struct {
+	Controllers map[string]*controllerConfig `yaml:"controllers"`
+}
+
+// controllerLabel is the name of the label identifying controllers configured
+// in the configuration file.
+const controllerLabel = "controller"
+
+func (cc *controllerConfig) validate() error {
+	if cc == nil {
+		return errors.New("empty definition")
+	}
+
+	if cc.Address == "" {
+		return errors.New("address is required")
+	}
+
+	if _, err := luxwslang.LookupByID(cc.Language); err != nil {
+		return err
+	}
+
+	if cc.Timezone != "" {
+		if _, err := time.LoadLocation(cc.Timezone); err != nil {
+			return fmt.Errorf("loading timezone %q failed: %w", cc.Timezone, err)
+		}
+	}
+
+	known := sectionNames()
+
+	for _, name := range cc.Sections {
+		if !slices.Contains(known, name) {
+			return fmt.Errorf("unknown section %q (one of %q)", name, known)
+		}
+	}
+
+	for name := range cc.Labels {
+		if !model.LabelName(name).IsValidLegacy() || strings.HasPrefix(name, "__") {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+
+		if name == controllerLabel {
+			return fmt.Errorf("label name %q is reserved", name)
+		}
+	}
+
+	return nil
+}
+
+// collectorOpts builds the options for a controller collector. Values not
+// specified in the configuration are taken from the defaults.
+func (cc *controllerConfig) collectorOpts(defaults collectorOpts) (collectorOpts, error) {
+	opts := defaults
+	opts.address = cc.Address
+	opts.httpAddress = cc.HTTPAddress
+	opts.sections = cc.Sections
+
+	var err error
+
+	if opts.terms, err = luxwslang.LookupByID(cc.Language); err != nil {
+		return opts, err
+	}
+
+	if cc.Timezone != "" {
+		if opts.loc, err = time.LoadLocation(cc.Timezone); err != nil {
+			return opts, err
+		}
+	}
+
+	if cc.PasswordFile != "" {
+		content, err := os.ReadFile(cc.PasswordFile)
+		if err != nil {
+			return opts, fmt.Errorf("reading password file: %w", err)
+		}
+
+		opts.password = strings.TrimRight(string(content), "\r\n")
+	}
+
+	return opts, nil
+}
+
+func parseConfig(data []byte) (*config, error) {
+	var cfg config
+
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return nil, err
+	}
+
+	if len(cfg.Controllers) == 0 {
+		return nil, errors.New("no controllers defined")
+	}
+
+	for name, cc := range cfg.Controllers {
+		if name == "" {
+			return nil, errors.New("controller name must not be empty")
+		}
+
+		if err := cc.validate(); err != nil {
+			return nil, fmt.Errorf("controller %q: %w", name, err)
+		}
+	}
+
+	return &cfg, nil
+}
+
+func loadConfigFile(path string) (*config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := parseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// controllerNames returns the sorted names of all controllers.
+func (cfg *config) controllerNames() []string {
+	var names []string
+
+	for name := range cfg.Controllers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// labelNames returns the sorted union of all extra label names across all
+// controllers. Metrics within a family must have consistent label names, so
+// controllers not defining a particular label use an empty value.
+func (cfg *config) labelNames() []string {
+	var names []string
+
+	for _, cc := range cfg.Controllers {
+		for name := range cc.Labels {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+
+	sort.Strings(names)
+
+	return names
+}
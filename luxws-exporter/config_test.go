@@ -0,0 +1,366 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestParseConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{
+			name:    "empty",
+			wantErr: "no controllers defined",
+		},
+		{
+			name:    "unknown field",
+			input:   "controllers:\n  a:\n    address: x:1\n    language: en\n    foo: bar\n",
+			wantErr: "field foo not found",
+		},
+		{
+			name:    "missing address",
+			input:   "controllers:\n  a:\n    language: en\n",
+			wantErr: `controller "a": address is required`,
+		},
+		{
+			name:    "empty definition",
+			input:   "controllers:\n  a:\n",
+			wantErr: `controller "a": empty definition`,
+		},
+		{
+			name:    "unknown language",
+			input:   "controllers:\n  a:\n    address: x:1\n    language: xx\n",
+			wantErr: `language "xx" not found`,
+		},
+		{
+			name:    "bad timezone",
+			input:   "controllers:\n  a:\n    address: x:1\n    language: en\n    timezone: Nowhere/Nothing\n",
+			wantErr: `loading timezone "Nowhere/Nothing" failed`,
+		},
+		{
+			name:    "unknown section",
+			input:   "controllers:\n  a:\n    address: x:1\n    language: en\n    sections: [foo]\n",
+			wantErr: `unknown section "foo"`,
+		},
+		{
+			name:    "reserved label",
+			input:   "controllers:\n  a:\n    address: x:1\n    language: en\n    labels:\n      controller: x\n",
+			wantErr: `label name "controller" is reserved`,
+		},
+		{
+			name:    "invalid label",
+			input:   "controllers:\n  a:\n    address: x:1\n    language: en\n    labels:\n      a-b: x\n",
+			wantErr: `invalid label name "a-b"`,
+		},
+		{
+			name: "valid",
+			input: `
+controllers:
+  basement:
+    address: 192.0.2.1:8214
+    http_address: 192.0.2.1:80
+    language: de
+    timezone: Europe/Berlin
+    sections: [info, temperatures]
+    labels:
+      site: home
+  garage:
+    address: 192.0.2.2:8214
+    language: en
+`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseConfig([]byte(tc.input))
+
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Errorf("parseConfig() failed: %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("parseConfig() error %v doesn't contain %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestControllerConfigCollectorOpts(t *testing.T) {
+	passwordFile := filepath.Join(t.TempDir(), "password")
+
+	if err := os.WriteFile(passwordFile, []byte("secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cc := &controllerConfig{
+		Address:      "192.0.2.1:8214",
+		Language:     "de",
+		PasswordFile: passwordFile,
+		Sections:     []string{"inputs"},
+	}
+
+	opts, err := cc.collectorOpts(collectorOpts{
+		loc:     time.UTC,
+		timeout: time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("collectorOpts() failed: %v", err)
+	}
+
+	if diff := cmp.Diff("secret", opts.password); diff != "" {
+		t.Errorf("Password diff (-want +got):\n%s", diff)
+	}
+
+	if opts.terms.ID != "de" || opts.loc != time.UTC || opts.timeout != time.Minute {
+		t.Errorf("Unexpected options: %+v", opts)
+	}
+
+	cc.PasswordFile = filepath.Join(t.TempDir(), "missing")
+
+	if _, err := cc.collectorOpts(collectorOpts{}); err == nil {
+		t.Errorf("collectorOpts() with missing password file didn't fail")
+	}
+}
+
+func TestControllerSetReload(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "", http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	discardAllLogs(t)
+
+	path := filepath.Join(t.TempDir(), "config.yml")
+
+	writeConfig := func(content string) {
+		t.Helper()
+
+		content = strings.ReplaceAll(content, "ADDRESS", serverURL.Host)
+
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	set := newControllerSet(path, collectorOpts{
+		loc:     time.UTC,
+		timeout: time.Minute,
+	}, pollOpts{})
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(set)
+	reg.MustRegister(set.Metrics()...)
+
+	writeConfig(`
+controllers:
+  first:
+    address: ADDRESS
+    language: en
+    labels:
+      site: home
+  second:
+    address: ADDRESS
+    language: de
+`)
+
+	if err := set.Reload(); err != nil {
+		t.Fatalf("Reload() failed: %v", err)
+	}
+
+	const wantUp = `
+# HELP luxws_up Whether scrape was successful
+# TYPE luxws_up gauge
+luxws_up{controller="first",site="home",status="dial"} 0
+luxws_up{controller="second",site="",status="dial"} 0
+# HELP luxws_exporter_config_last_reload_successful Whether the last configuration reload attempt was successful
+# TYPE luxws_exporter_config_last_reload_successful gauge
+luxws_exporter_config_last_reload_successful 1
+`
+
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(wantUp),
+		"luxws_up", "luxws_exporter_config_last_reload_successful"); err != nil {
+		t.Error(err)
+	}
+
+	// Snapshots carry the labels for push outputs
+	if diff := cmp.Diff(map[string]string{"controller": "first", "site": "home"},
+		set.Targets()["first"].labels); diff != "" {
+		t.Errorf("Labels difference (-want +got):\n%s", diff)
+	}
+
+	// Invalid configuration must not replace the running one
+	writeConfig("controllers:\n  broken:\n    language: en\n")
+
+	if err := set.Reload(); err == nil {
+		t.Errorf("Reload() with invalid configuration didn't fail")
+	}
+
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(strings.Replace(wantUp,
+		"luxws_exporter_config_last_reload_successful 1", "luxws_exporter_config_last_reload_successful 0", 1)),
+		"luxws_up", "luxws_exporter_config_last_reload_successful"); err != nil {
+		t.Error(err)
+	}
+
+	writeConfig("controllers:\n  third:\n    address: ADDRESS\n    language: en\n")
+
+	handler := set.ReloadHandler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/-/reload", nil))
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET request returned status %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/-/reload", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("POST request returned status %d", rec.Code)
+	}
+
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP luxws_up Whether scrape was successful
+# TYPE luxws_up gauge
+luxws_up{controller="third",status="dial"} 0
+`), "luxws_up"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestControllerSetReloadKeepsUnchanged(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "", http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	discardAllLogs(t)
+
+	path := filepath.Join(t.TempDir(), "config.yml")
+
+	writeConfig := func(secondLanguage string) {
+		t.Helper()
+
+		content := strings.NewReplacer("ADDRESS", serverURL.Host, "LANGUAGE", secondLanguage).Replace(`
+controllers:
+  first:
+    address: ADDRESS
+    language: en
+  second:
+    address: ADDRESS
+    language: LANGUAGE
+`)
+
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	latest := newSnapshotStore()
+	sinks := &sinkSet{}
+	sinks.Subscribe("api", latest)
+
+	set := newControllerSet(path, collectorOpts{
+		loc:     time.UTC,
+		timeout: time.Minute,
+		sinks:   sinks,
+	}, pollOpts{
+		interval: time.Hour,
+	})
+
+	writeConfig("de")
+
+	if err := set.Reload(); err != nil {
+		t.Fatalf("Reload() failed: %v", err)
+	}
+
+	before := set.current
+
+	for _, name := range []string{"first", "second"} {
+		latest.Consume(context.Background(), &snapshot{
+			controller: name,
+			values:     []apiValue{{Key: "a", Text: "1"}},
+		})
+	}
+
+	writeConfig("nl")
+
+	if err := set.Reload(); err != nil {
+		t.Fatalf("Reload() failed: %v", err)
+	}
+
+	after := set.current
+
+	if got, want := after.targets["first"], before.targets["first"]; got != want {
+		t.Errorf("Collector of unchanged controller was replaced")
+	}
+
+	if got, want := after.targets["second"], before.targets["second"]; got == want {
+		t.Errorf("Collector of changed controller was kept")
+	}
+
+	select {
+	case <-before.entries["second"].done:
+	default:
+		t.Errorf("Poller of changed controller still running")
+	}
+
+	if got, want := after.targets["second"].defrost, before.targets["second"].defrost; got != want {
+		t.Errorf("Defrost state of changed controller not taken over")
+	}
+
+	if latest.get("first") == nil {
+		t.Errorf("Snapshot of unchanged controller was discarded")
+	}
+
+	if latest.get("second") != nil {
+		t.Errorf("Snapshot of changed controller was kept")
+	}
+
+	if err := os.WriteFile(path, []byte("controllers:\n  third:\n    address: "+serverURL.Host+"\n    language: en\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := set.Reload(); err != nil {
+		t.Fatalf("Reload() failed: %v", err)
+	}
+
+	for _, name := range []string{"first", "second"} {
+		select {
+		case <-after.entries[name].done:
+		default:
+			t.Errorf("Poller of removed controller %q still running", name)
+		}
+
+		if latest.get(name) != nil {
+			t.Errorf("Snapshot of removed controller %q was kept", name)
+		}
+	}
+
+	for _, e := range set.current.entries {
+		e.shutdown()
+	}
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// controllerSet collects metrics from all controllers defined in
+// a configuration file. The configuration can be reloaded at runtime. An
+// invalid configuration is rejected and the previous controllers are kept.
+type controllerSet struct {
+	path     string
+	defaults collectorOpts
+	poll     pollOpts
+
+	// Serializes reloads.
+	reloadMu sync.Mutex
+
+	mu      sync.RWMutex
+	current *controllers
+
+	reloadSuccess   prometheus.Gauge
+	reloadTimestamp prometheus.Gauge
+}
+
+func newControllerSet(path string, defaults collectorOpts, poll pollOpts) *controllerSet {
+	return &controllerSet{
+		path:     path,
+		defaults: defaults,
+		poll:     poll,
+		reloadSuccess: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "luxws_exporter_config_last_reload_successful",
+			Help: "Whether the last configuration reload attempt was successful",
+		}),
+		reloadTimestamp: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "luxws_exporter_config_last_reload_success_timestamp_seconds",
+			Help: "Timestamp of the last successful configuration reload",
+		}),
+	}
+}
+
+// controllerEntry is a single configured controller.
+type controllerEntry struct {
+	// Identifies the configuration the collector was built from.
+	key string
+
+	opts   collectorOpts
+	labels prometheus.Labels
+
+	target *collector
+
+	// Collector wrapped with the controller labels.
+	collector prometheus.Collector
+
+	// Only set if polling is enabled.
+	poller *poller
+	stop   context.CancelFunc
+	done   chan struct{}
+}
+
+// start runs the poller, if any, in the background.
+func (e *controllerEntry) start() {
+	if e.poller == nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	e.stop = cancel
+	e.done = make(chan struct{})
+
+	go func() {
+		defer close(e.done)
+
+		e.poller.Run(ctx)
+	}()
+}
+
+// shutdown stops the poller and waits for it to finish.
+func (e *controllerEntry) shutdown() {
+	if e.stop == nil {
+		return
+	}
+
+	e.stop()
+	<-e.done
+}
+
+// controllers contains the collectors built from a configuration.
+type controllers struct {
+	// Entries by controller name.
+	entries map[string]*controllerEntry
+
+	// Collectors wrapped with the controller labels.
+	collectors []prometheus.Collector
+
+	// Unwrapped collectors by controller name.
+	targets map[string]*collector
+}
+
+// planEntries determines the options for every configured controller without
+// constructing any collectors.
+func (s *controllerSet) planEntries(cfg *config) (map[string]*controllerEntry, error) {
+	result := map[string]*controllerEntry{}
+
+	labelNames := cfg.labelNames()
+
+	for _, name := range cfg.controllerNames() {
+		cc := cfg.Controllers[name]
+
+		opts, err := cc.collectorOpts(s.defaults)
+		if err != nil {
+			return nil, fmt.Errorf("controller %q: %w", name, err)
+		}
+
+		opts.defrost = s.poll.defrostOpts(name)
+		opts.name = name
+
+		labels := prometheus.Labels{
+			controllerLabel: name,
+		}
+
+		for _, labelName := range labelNames {
+			labels[labelName] = cc.Labels[labelName]
+		}
+
+		opts.labels = labels
+
+		key, err := json.Marshal(struct {
+			Config   *controllerConfig
+			Password string
+			Labels   prometheus.Labels
+		}{cc, opts.password, labels})
+		if err != nil {
+			return nil, fmt.Errorf("controller %q: %w", name, err)
+		}
+
+		result[name] = &controllerEntry{
+			key:    string(key),
+			opts:   opts,
+			labels: labels,
+		}
+	}
+
+	return result, nil
+}
+
+// build constructs the collector of an entry.
+func (s *controllerSet) build(e *controllerEntry) {
+	e.target = newCollector(e.opts)
+
+	var c prometheus.Collector = e.target
+
+	if s.poll.enabled() {
+		e.poller = newPoller(e.target, s.poll)
+		c = e.poller
+	}
+
+	e.collector = prometheus.WrapCollectorWith(e.labels, c)
+}
+
+// Reload reads the configuration file and applies it. Collectors of
+// controllers with an unchanged configuration are kept together with their
+// state. Pollers of changed and removed controllers are stopped before their
+// replacements start and their state in outputs is discarded.
+func (s *controllerSet) Reload() error {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	entries, err := func() (map[string]*controllerEntry, error) {
+		cfg, err := loadConfigFile(s.path)
+		if err != nil {
+			return nil, err
+		}
+
+		return s.planEntries(cfg)
+	}()
+
+	if err != nil {
+		s.reloadSuccess.Set(0)
+		return fmt.Errorf("loading configuration failed: %w", err)
+	}
+
+	s.mu.RLock()
+	previous := s.current
+	s.mu.RUnlock()
+
+	var started []*controllerEntry
+
+	next := &controllers{
+		entries: entries,
+		targets: map[string]*collector{},
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(entries)) {
+		e := entries[name]
+
+		var prev *controllerEntry
+
+		if previous != nil {
+			prev = previous.entries[name]
+		}
+
+		if prev != nil && prev.key == e.key {
+			entries[name] = prev
+			e = prev
+		} else {
+			if prev != nil {
+				// Only one poller may use the defrost state at any time.
+				prev.shutdown()
+
+				if prev.target.defrost != nil {
+					e.opts.defrost.detector = prev.target.defrost
+				}
+
+				// Outputs must not serve values from the previous
+				// configuration.
+				s.defaults.sinks.Forget(name)
+			}
+
+			s.build(e)
+			started = append(started, e)
+		}
+
+		next.targets[name] = e.target
+		next.collectors = append(next.collectors, e.collector)
+	}
+
+	if previous != nil {
+		for name, prev := range previous.entries {
+			if _, ok := entries[name]; !ok {
+				prev.shutdown()
+				s.defaults.sinks.Forget(name)
+			}
+		}
+	}
+
+	s.mu.Lock()
+	s.current = next
+	s.mu.Unlock()
+
+	for _, e := range started {
+		e.start()
+	}
+
+	s.reloadSuccess.Set(1)
+	s.reloadTimestamp.SetToCurrentTime()
+
+	return nil
+}
+
+// Targets returns the collectors of all configured controllers by name.
+func (s *controllerSet) Targets() map[string]*collector {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.current == nil {
+		return nil
+	}
+
+	return s.current.targets
+}
+
+// Metrics returns the collectors for metrics about configuration reloads.
+func (s *controllerSet) Metrics() []prometheus.Collector {
+	return []prometheus.Collector{s.reloadSuccess, s.reloadTimestamp}
+}
+
+// Describe implements prometheus.Collector. No descriptors are returned as
+// the set of controllers can change at runtime, thus making the collector
+// unchecked.
+func (s *controllerSet) Describe(chan<- *prometheus.Desc) {
+}
+
+// Collect implements prometheus.Collector. All controllers are collected
+// concurrently.
+func (s *controllerSet) Collect(ch chan<- prometheus.Metric) {
+	s.mu.RLock()
+	current := s.current
+	s.mu.RUnlock()
+
+	if current == nil {
+		return
+	}
+
+	var wg sync.WaitGroup
+
+	for _, c := range current.collectors {
+		wg.Go(func() {
+			c.Collect(ch)
+		})
+	}
+
+	wg.Wait()
+}
+
+// ReloadHandler returns an HTTP handler triggering a configuration reload.
+func (s *controllerSet) ReloadHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost, http.MethodPut:
+		default:
+			w.Header().Set("Allow", "POST, PUT")
+			http.Error(w, "Only POST or PUT requests allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if err := s.Reload(); err != nil {
+			log.Print(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		log.Print("Configuration reloaded")
+	})
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/hansmi/wp2reg-luxws/luxwsclient"
+	"go.uber.org/multierr"
+)
+
+const (
+	suppliedHeatTotalName = "luxws_supplied_heat_total"
+	compressorStartsName  = "luxws_compressor_starts_total"
+)
+
+type counterKey struct {
+	metric string
+	name   string
+}
+
+// counterState tracks a cumulative value reported by the controller.
+type counterState struct {
+	last   float64
+	resets float64
+}
+
+// observeCounter records the current value of a cumulative counter. A value
+// lower than the previously observed one is counted as a reset (e.g. after
+// a firmware update or a manual clear on the controller). Exported values are
+// not adjusted; queries are expected to use rate() or increase().
+func (c *collector) observeCounter(metric, name string, value float64) {
+	key := counterKey{metric, name}
+
+	c.countersMu.Lock()
+	defer c.countersMu.Unlock()
+
+	if state, ok := c.counters[key]; !ok {
+		c.counters[key] = &counterState{last: value}
+	} else {
+		if value < state.last {
+			state.resets++
+		}
+
+		state.last = value
+	}
+}
+
+// collectCounterResets exports the number of detected resets for all
+// observed counters.
+func (c *collector) collectCounterResets(ch chan<- reading) {
+	c.countersMu.Lock()
+	defer c.countersMu.Unlock()
+
+	keys := make([]counterKey, 0, len(c.counters))
+
+	for key := range c.counters {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].metric != keys[j].metric {
+			return keys[i].metric < keys[j].metric
+		}
+
+		return keys[i].name < keys[j].name
+	})
+
+	for _, key := range keys {
+		ch <- newReading(c.counterResetsDesc, c.counters[key].resets, key.metric, key.name)
+	}
+}
+
+// collectHeatCounters exports the cumulative heat quantities as counters.
+func (c *collector) collectHeatCounters(ch chan<- reading, content *luxwsclient.ContentRoot) error {
+	group, err := findContentItem(content, c.terms.NavHeatQuantity)
+	if err != nil {
+		return err
+	}
+
+	var itemErr error
+
+	for _, item := range group.Items {
+		if item.Value == nil {
+			continue
+		}
+
+		value, unit, err := c.parseValue(*item.Value)
+		if err != nil {
+			multierr.AppendInto(&itemErr, newMeasurementError(item.Name, err))
+			continue
+		}
+
+		name := normalizeSpace(item.Name)
+
+		var r reading
+		var metricName string
+
+		if c.layout == layoutUnits {
+			if r, err = c.suppliedHeatUnits.newReading(value, unit, name); err != nil {
+				multierr.AppendInto(&itemErr, newItemError(reasonUnsupportedUnit, item.Name, err))
+				continue
+			}
+
+			metricName = c.suppliedHeatUnits.names[unit]
+		} else {
+			r = newReading(c.suppliedHeatTotalDesc, value, name, unit)
+			metricName = suppliedHeatTotalName
+		}
+
+		c.observeCounter(metricName, name, value)
+
+		ch <- r
+	}
+
+	return itemErr
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hansmi/wp2reg-luxws/luxwsclient"
+	"github.com/hansmi/wp2reg-luxws/luxwslang"
+)
+
+func TestCollectHeatCounters(t *testing.T) {
+	heat := func(heating, water string) *luxwsclient.ContentRoot {
+		return &luxwsclient.ContentRoot{
+			Items: []luxwsclient.ContentItem{
+				{
+					Name: "Wärmemenge",
+					Items: []luxwsclient.ContentItem{
+						{Name: "Heizung", Value: luxwsclient.String(heating)},
+						{Name: "Warmwasser", Value: luxwsclient.String(water)},
+					},
+				},
+			},
+		}
+	}
+
+	for _, tc := range []struct {
+		name   string
+		layout metricLayout
+		inputs []*luxwsclient.ContentRoot
+		want   string
+	}{
+		{
+			name:   "legacy",
+			layout: layoutLegacy,
+			inputs: []*luxwsclient.ContentRoot{
+				heat("100 kWh", "50 kWh"),
+				heat("101 kWh", "50 kWh"),
+				heat("2 kWh", "51 kWh"),
+			},
+			want: `
+# HELP luxws_counter_resets_total Number of detected resets of cumulative values reported by the controller
+# TYPE luxws_counter_resets_total counter
+luxws_counter_resets_total{metric="luxws_supplied_heat_total",name="Heizung"} 1
+luxws_counter_resets_total{metric="luxws_supplied_heat_total",name="Warmwasser"} 0
+# HELP luxws_supplied_heat_total Cumulative supplied heat
+# TYPE luxws_supplied_heat_total counter
+luxws_supplied_heat_total{name="Heizung",unit="kWh"} 2
+luxws_supplied_heat_total{name="Warmwasser",unit="kWh"} 51
+`,
+		},
+		{
+			name:   "units",
+			layout: layoutUnits,
+			inputs: []*luxwsclient.ContentRoot{
+				heat("100 kWh", "50 kWh"),
+				heat("0 kWh", "0 kWh"),
+				heat("1 kWh", "0 kWh"),
+				heat("0 kWh", "0 kWh"),
+			},
+			want: `
+# HELP luxws_counter_resets_total Number of detected resets of cumulative values reported by the controller
+# TYPE luxws_counter_resets_total counter
+luxws_counter_resets_total{metric="luxws_supplied_heat_kilowatt_hours_total",name="Heizung"} 2
+luxws_counter_resets_total{metric="luxws_supplied_heat_kilowatt_hours_total",name="Warmwasser"} 1
+# HELP luxws_supplied_heat_kilowatt_hours_total Supplied heat (kilowatt hours)
+# TYPE luxws_supplied_heat_kilowatt_hours_total counter
+luxws_supplied_heat_kilowatt_hours_total{name="Heizung"} 0
+luxws_supplied_heat_kilowatt_hours_total{name="Warmwasser"} 0
+`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newCollector(collectorOpts{
+				terms:  luxwslang.German,
+				loc:    time.UTC,
+				layout: tc.layout,
+			})
+
+			for _, input := range tc.inputs[:len(tc.inputs)-1] {
+				if err := c.collectHeatCounters(make(chan reading, 10), input); err != nil {
+					t.Fatalf("collectHeatCounters() failed: %v", err)
+				}
+			}
+
+			a := &adapter{
+				c: c,
+				collect: func(ch chan<- reading) error {
+					if err := c.collectHeatCounters(ch, tc.inputs[len(tc.inputs)-1]); err != nil {
+						return err
+					}
+
+					c.collectCounterResets(ch)
+
+					return nil
+				},
+			}
+			a.collectAndCompare(t, tc.want, nil)
+		})
+	}
+}
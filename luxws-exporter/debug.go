@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hansmi/wp2reg-luxws/luxwsclient"
+)
+
+// debugResponse is a raw document received from the controller.
+type debugResponse struct {
+	kind    string
+	payload []byte
+}
+
+// debugSnapshot holds the details of a single collection. Snapshots are only
+// read after the collection has finished.
+type debugSnapshot struct {
+	time      time.Time
+	responses []debugResponse
+	content   *luxwsclient.ContentRoot
+	skipped   []skippedItem
+	err       error
+}
+
+func newDebugSnapshot(ts time.Time) *debugSnapshot {
+	return &debugSnapshot{time: ts}
+}
+
+func (s *debugSnapshot) addResponse(kind string, payload []byte) {
+	s.responses = append(s.responses, debugResponse{
+		kind:    kind,
+		payload: append([]byte(nil), payload...),
+	})
+}
+
+func (s *debugSnapshot) setContent(content *luxwsclient.ContentRoot, skipped []skippedItem) {
+	if s != nil {
+		s.content = content
+		s.skipped = skipped
+	}
+}
+
+func writeDebugItems(w io.Writer, items []luxwsclient.ContentItem, depth int) {
+	for _, item := range items {
+		fmt.Fprintf(w, "%s%s", strings.Repeat("  ", depth), item.Name)
+
+		if item.Value != nil {
+			fmt.Fprintf(w, " = %q", *item.Value)
+		}
+
+		fmt.Fprintln(w)
+
+		writeDebugItems(w, item.Items, depth+1)
+	}
+}
+
+func (s *debugSnapshot) writeTo(w io.Writer) {
+	fmt.Fprintf(w, "Time: %s\n", s.time.Format(time.RFC3339))
+
+	if s.err != nil {
+		fmt.Fprintf(w, "Error: %v\n", s.err)
+	}
+
+	fmt.Fprintf(w, "\n# Skipped items (%d)\n", len(s.skipped))
+
+	for idx, i := range s.skipped {
+		if idx == 0 {
+			fmt.Fprintln(w)
+		}
+
+		fmt.Fprintf(w, "%s: %q: %s: %v\n", i.section, i.item, i.reason, i.err)
+	}
+
+	if s.content != nil {
+		fmt.Fprint(w, "\n# Parsed content\n\n")
+		writeDebugItems(w, s.content.Items, 0)
+	}
+
+	for idx, r := range s.responses {
+		fmt.Fprintf(w, "\n# Response %d (%s)\n\n", idx+1, r.kind)
+		w.Write(r.payload)
+		fmt.Fprintln(w)
+	}
+}
+
+// debugRecorder keeps the most recent snapshot.
+type debugRecorder struct {
+	mu   sync.Mutex
+	last *debugSnapshot
+}
+
+// newDebugRecorder returns nil unless recording is enabled.
+func newDebugRecorder(enabled bool) *debugRecorder {
+	if !enabled {
+		return nil
+	}
+
+	return &debugRecorder{}
+}
+
+func (r *debugRecorder) set(s *debugSnapshot) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.last = s
+}
+
+func (r *debugRecorder) get() *debugSnapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.last
+}
+
+// debugHandler serves the raw responses, the parsed content and the skipped
+// items of the most recent collection of a controller. The web configuration
+// file is re-read on every request, the same as the web server does, and
+// requests are refused once it no longer requires authentication.
+type debugHandler struct {
+	webConfigFile string
+	targets       func() map[string]*collector
+}
+
+func (h *debugHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := checkDebugAuth(h.webConfigFile); err != nil {
+		log.Printf("Refusing debug request: %v", err)
+		http.Error(w, "Debug endpoint requires basic authentication", http.StatusForbidden)
+		return
+	}
+
+	_, c, err := selectTarget(r, h.targets())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	var snapshot *debugSnapshot
+
+	if c.debug != nil {
+		snapshot = c.debug.get()
+	}
+
+	if snapshot == nil {
+		http.Error(w, "No collection recorded yet", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+
+	snapshot.writeTo(w)
+}
+
+// checkDebugAuth verifies that the web configuration file requires
+// authentication. The debug endpoint exposes raw controller data and must not
+// be served publicly.
+func checkDebugAuth(webConfigFile string) error {
+	if webConfigFile == "" {
+		return errors.New("debug endpoint requires a web configuration file with basic_auth_users")
+	}
+
+	cfg, err := readWebConfig(webConfigFile)
+	if err != nil {
+		return err
+	}
+
+	if !cfg.basicAuth() {
+		return fmt.Errorf("debug endpoint requires basic_auth_users in %s", webConfigFile)
+	}
+
+	return nil
+}
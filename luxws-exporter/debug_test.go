@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/hansmi/wp2reg-luxws/luxwslang"
+)
+
+const debugTestContent = `<Content id="0x2">` +
+	`<item id="0x10"><name>temperatures</name>` +
+	`<item id="0x11"><name>Flow</name><value>25.0°C</value></item>` +
+	`<item id="0x12"><name>Return</name><value>12.3 furlongs</value></item>` +
+	`</item>` +
+	`</Content>`
+
+// newTestController starts a LuxWS server responding to logins and returning
+// the given content for the information page.
+func newTestController(t *testing.T, content string) string {
+	t.Helper()
+
+	var upgrader websocket.Upgrader
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("Connection upgrade failed: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		for {
+			mt, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+
+			var response string
+
+			switch {
+			case strings.HasPrefix(string(message), "LOGIN;"):
+				response = `<Navigation id="0x1"><item id="0x2"><name>information</name></item></Navigation>`
+			case string(message) == "GET;0x2":
+				response = content
+			default:
+				response = "<unknown></unknown>"
+			}
+
+			if err := conn.WriteMessage(mt, []byte(response)); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return serverURL.Host
+}
+
+func TestDebugHandler(t *testing.T) {
+	discardAllLogs(t)
+
+	c := newCollector(collectorOpts{
+		terms:   luxwslang.English,
+		loc:     time.UTC,
+		timeout: time.Minute,
+		address: newTestController(t, debugTestContent),
+		debug:   true,
+	})
+	c.now = func() time.Time {
+		return time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	webConfigFile := filepath.Join(t.TempDir(), "web.yml")
+
+	writeWebConfig := func(content string) {
+		if err := os.WriteFile(webConfigFile, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeWebConfig("basic_auth_users:\n  admin: $2y$10$abcdefghijklmnopqrstuv\n")
+
+	h := &debugHandler{
+		webConfigFile: webConfigFile,
+		targets: func() map[string]*collector {
+			return map[string]*collector{"a": c}
+		},
+	}
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	if rec := get("/debug/last"); rec.Code != http.StatusNotFound {
+		t.Errorf("Got status %d before collection, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	ch := make(chan reading)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for range ch {
+		}
+	}()
+
+	c.collectWebSocket(context.Background(), ch, nil)
+	close(ch)
+	<-done
+
+	if rec := get("/debug/last?controller=b"); rec.Code != http.StatusNotFound {
+		t.Errorf("Got status %d for unknown controller, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	rec := get("/debug/last?controller=a")
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Got status %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	body := rec.Body.String()
+
+	for _, want := range []string{
+		"Time: 2020-01-01T00:00:00Z\n",
+		"# Skipped items (1)\n\ntemperatures: \"Return\": unknown_unit: ",
+		"# Parsed content\n\ntemperatures\n  Flow = \"25.0°C\"\n  Return = \"12.3 furlongs\"\n",
+		"# Response 1 (navigation)\n\n<Navigation id=\"0x1\">",
+		"# Response 2 (content)\n\n" + debugTestContent + "\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Body does not contain %q:\n%s", want, body)
+		}
+	}
+
+	// Authentication removed from the web configuration at runtime
+	writeWebConfig("tls_server_config: {}\n")
+
+	if rec := get("/debug/last?controller=a"); rec.Code != http.StatusForbidden {
+		t.Errorf("Got status %d without authentication, want %d", rec.Code, http.StatusForbidden)
+	}
+}
+
+func TestCheckDebugAuth(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		return path
+	}
+
+	for _, tc := range []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{name: "no file", wantErr: true},
+		{name: "missing", path: filepath.Join(dir, "missing.yml"), wantErr: true},
+		{name: "no users", path: write("tls.yml", "tls_server_config: {}\n"), wantErr: true},
+		{name: "invalid", path: write("invalid.yml", "basic_auth_users: [\n"), wantErr: true},
+		{
+			name: "users",
+			path: write("users.yml", "basic_auth_users:\n  admin: $2y$10$abcdefghijklmnopqrstuv\n"),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkDebugAuth(tc.path)
+
+			if (err != nil) != tc.wantErr {
+				t.Errorf("checkDebugAuth() returned %v, want error %t", err, tc.wantErr)
+			}
+		})
+	}
+}
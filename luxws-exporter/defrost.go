@@ -0,0 +1,267 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hansmi/wp2reg-luxws/luxwsclient"
+	"github.com/hansmi/wp2reg-luxws/luxwslang"
+)
+
+type defrostOpts struct {
+	enabled bool
+
+	// File storing the detector state across restarts. State is only kept
+	// in memory if empty.
+	stateFile string
+
+	// Existing detector to continue with instead of loading the state file,
+	// e.g. after the controller configuration changed.
+	detector *defrostDetector
+}
+
+// defrostDurationBuckets are the upper bounds of the cycle duration
+// histogram in seconds.
+var defrostDurationBuckets = []float64{60, 120, 180, 240, 300, 450, 600, 900, 1200}
+
+// defrostState is the persisted state of a defrost detector.
+type defrostState struct {
+	Cycles      int64            `json:"cycles"`
+	LastSeconds float64          `json:"last_seconds"`
+	Durations   defrostDurations `json:"durations"`
+	ActiveSince *time.Time       `json:"active_since,omitempty"`
+}
+
+// defrostDurations is the histogram of completed cycle durations.
+type defrostDurations struct {
+	Bounds []float64 `json:"bounds"`
+
+	// Cumulative counts for every bound.
+	Buckets []uint64 `json:"buckets"`
+
+	Count uint64  `json:"count"`
+	Sum   float64 `json:"sum"`
+}
+
+func newDefrostDurations() defrostDurations {
+	return defrostDurations{
+		Bounds:  slices.Clone(defrostDurationBuckets),
+		Buckets: make([]uint64, len(defrostDurationBuckets)),
+	}
+}
+
+// valid reports whether the histogram uses the current buckets.
+func (h defrostDurations) valid() bool {
+	return slices.Equal(h.Bounds, defrostDurationBuckets) && len(h.Buckets) == len(h.Bounds)
+}
+
+func (h *defrostDurations) observe(seconds float64) {
+	h.Count++
+	h.Sum += seconds
+
+	for idx, bound := range h.Bounds {
+		if seconds <= bound {
+			h.Buckets[idx]++
+		}
+	}
+}
+
+func (h defrostDurations) clone() defrostDurations {
+	h.Bounds = slices.Clone(h.Bounds)
+	h.Buckets = slices.Clone(h.Buckets)
+
+	return h
+}
+
+func (h defrostDurations) value() histogramValue {
+	return histogramValue{
+		count:   h.Count,
+		sum:     h.Sum,
+		bounds:  h.Bounds,
+		buckets: h.Buckets,
+	}
+}
+
+// defrostDetector counts defrost cycles by observing the controller state on
+// every poll. A cycle begins when the operation mode changes to defrost or
+// the defrost valve is opened and ends when neither applies anymore. The
+// resolution of the measured durations is the poll interval.
+type defrostDetector struct {
+	stateFile string
+
+	mu    sync.Mutex
+	state defrostState
+}
+
+func newDefrostDetector(stateFile string) (*defrostDetector, error) {
+	d := &defrostDetector{
+		stateFile: stateFile,
+		state: defrostState{
+			Durations: newDefrostDurations(),
+		},
+	}
+
+	if stateFile == "" {
+		return d, nil
+	}
+
+	data, err := os.ReadFile(stateFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return d, nil
+	} else if err != nil {
+		return d, err
+	}
+
+	var state defrostState
+
+	if err := json.Unmarshal(data, &state); err != nil {
+		return d, fmt.Errorf("parsing defrost state %s: %w", stateFile, err)
+	}
+
+	d.state = state
+
+	if !d.state.Durations.valid() {
+		if d.state.Durations.Count > 0 {
+			log.Printf("Discarding defrost durations with different buckets from %s", stateFile)
+		}
+
+		d.state.Durations = newDefrostDurations()
+	}
+
+	// The controller wasn't observed while the exporter was down. A cycle in
+	// progress at the time of the last update may have ended at any point
+	// since and its duration is unknown.
+	if d.state.ActiveSince != nil {
+		log.Printf("Discarding defrost cycle in progress since %v from %s",
+			d.state.ActiveSince.Format(time.RFC3339), stateFile)
+
+		d.state.ActiveSince = nil
+	}
+
+	return d, nil
+}
+
+// save writes the state to the state file. The file is replaced atomically.
+func (d *defrostDetector) save() error {
+	if d.stateFile == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(d.state)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(d.stateFile, data, 0o600)
+}
+
+// observe updates the detector with the defrost state seen at the given time.
+func (d *defrostDetector) observe(active bool, ts time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch {
+	case active && d.state.ActiveSince == nil:
+		d.state.ActiveSince = &ts
+
+	case !active && d.state.ActiveSince != nil:
+		duration := ts.Sub(*d.state.ActiveSince).Seconds()
+		if duration < 0 {
+			duration = 0
+		}
+
+		d.state.Cycles++
+		d.state.LastSeconds = duration
+		d.state.Durations.observe(duration)
+		d.state.ActiveSince = nil
+
+	default:
+		return nil
+	}
+
+	if err := d.save(); err != nil {
+		return fmt.Errorf("saving defrost state: %w", err)
+	}
+
+	return nil
+}
+
+func (d *defrostDetector) snapshot() defrostState {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	result := d.state
+	result.Durations = result.Durations.clone()
+
+	return result
+}
+
+// defrostActive determines whether the controller is defrosting.
+func (c *collector) defrostActive(content *luxwsclient.ContentRoot) bool {
+	if group := content.FindByName(c.terms.NavSystemStatus); group != nil {
+		for _, item := range group.Items {
+			if item.Value != nil && item.Name == c.terms.StatusOperationMode &&
+				c.terms.ParseOperationMode(*item.Value) == luxwslang.OperationModeDefrost {
+				return true
+			}
+		}
+	}
+
+	if group := content.FindByName(c.terms.NavOutputs); group != nil {
+		for _, item := range group.Items {
+			if item.Value != nil && normalizeSpace(item.Name) == c.terms.OutputDefrostValve &&
+				strings.TrimSpace(*item.Value) == c.terms.BoolTrue {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (c *collector) collectDefrost(ch chan<- reading, content *luxwsclient.ContentRoot) error {
+	active := c.defrostActive(content)
+	err := c.defrost.observe(active, c.now())
+
+	state := c.defrost.snapshot()
+
+	var activeValue float64
+
+	if state.ActiveSince != nil {
+		activeValue = 1
+	}
+
+	ch <- newReading(c.defrostCyclesDesc, float64(state.Cycles))
+	ch <- newReading(c.defrostLastDurationDesc, state.LastSeconds)
+	ch <- newHistogramReading(c.defrostDurationDesc, state.Durations.value())
+	ch <- newReading(c.defrostActiveDesc, activeValue)
+
+	return err
+}
+
+// newDefrostDetectorOrLog creates a detector and logs errors from loading the
+// persisted state. Counting restarts from zero in that case.
+func newDefrostDetectorOrLog(opts defrostOpts) *defrostDetector {
+	if !opts.enabled {
+		return nil
+	}
+
+	if opts.detector != nil {
+		return opts.detector
+	}
+
+	d, err := newDefrostDetector(opts.stateFile)
+	if err != nil {
+		log.Printf("Loading defrost state failed: %v", err)
+	}
+
+	return d
+}
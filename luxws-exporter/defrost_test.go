@@ -0,0 +1,280 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hansmi/wp2reg-luxws/luxwsclient"
+	"github.com/hansmi/wp2reg-luxws/luxwslang"
+)
+
+func TestDefrostDetectorPersistence(t *testing.T) {
+	discardAllLogs(t)
+
+	stateFile := filepath.Join(t.TempDir(), "defrost.json")
+	start := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	d, err := newDefrostDetector(stateFile)
+	if err != nil {
+		t.Fatalf("newDefrostDetector() failed: %v", err)
+	}
+
+	for _, step := range []struct {
+		offset time.Duration
+		active bool
+	}{
+		{0, false},
+		{time.Minute, true},
+		{2 * time.Minute, true},
+		{4 * time.Minute, false},
+		{10 * time.Minute, true},
+	} {
+		if err := d.observe(step.active, start.Add(step.offset)); err != nil {
+			t.Fatalf("observe() failed: %v", err)
+		}
+	}
+
+	// Simulate a restart; the cycle in progress is discarded
+	d, err = newDefrostDetector(stateFile)
+	if err != nil {
+		t.Fatalf("newDefrostDetector() failed: %v", err)
+	}
+
+	for _, step := range []struct {
+		offset time.Duration
+		active bool
+	}{
+		{time.Hour, false},
+		{time.Hour + time.Minute, true},
+		{time.Hour + 2*time.Minute, false},
+	} {
+		if err := d.observe(step.active, start.Add(step.offset)); err != nil {
+			t.Fatalf("observe() failed: %v", err)
+		}
+	}
+
+	want := defrostState{
+		Cycles:      2,
+		LastSeconds: 60,
+		Durations: defrostDurations{
+			Bounds:  defrostDurationBuckets,
+			Buckets: []uint64{1, 1, 2, 2, 2, 2, 2, 2, 2},
+			Count:   2,
+			Sum:     4 * 60,
+		},
+	}
+
+	if diff := cmp.Diff(want, d.snapshot()); diff != "" {
+		t.Errorf("State difference (-want +got):\n%s", diff)
+	}
+}
+
+func TestDefrostDetectorInvalidState(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "defrost.json")
+
+	if err := os.WriteFile(stateFile, []byte("garbage"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := newDefrostDetector(stateFile)
+	if err == nil {
+		t.Errorf("newDefrostDetector() didn't fail")
+	}
+
+	if d == nil {
+		t.Fatalf("newDefrostDetector() returned nil detector")
+	}
+
+	if err := d.observe(true, time.Now()); err != nil {
+		t.Errorf("observe() failed: %v", err)
+	}
+}
+
+func TestCollectDefrost(t *testing.T) {
+	c := newCollector(collectorOpts{
+		terms: luxwslang.German,
+		loc:   time.UTC,
+		defrost: defrostOpts{
+			enabled: true,
+		},
+	})
+
+	now := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	c.now = func() time.Time {
+		return now
+	}
+
+	content := func(mode, valve string) *luxwsclient.ContentRoot {
+		return &luxwsclient.ContentRoot{
+			Items: []luxwsclient.ContentItem{
+				{
+					Name: "Anlagenstatus",
+					Items: []luxwsclient.ContentItem{
+						{Name: "Betriebszustand", Value: luxwsclient.String(mode)},
+					},
+				},
+				{
+					Name: "Ausgänge",
+					Items: []luxwsclient.ContentItem{
+						{Name: "Abtauventil", Value: luxwsclient.String(valve)},
+					},
+				},
+			},
+		}
+	}
+
+	for _, tc := range []struct {
+		name    string
+		advance time.Duration
+		input   *luxwsclient.ContentRoot
+		want    string
+	}{
+		{
+			name:  "idle",
+			input: content("Heizen", "Aus"),
+			want: `
+# HELP luxws_defrost_active Whether a defrost cycle is in progress
+# TYPE luxws_defrost_active gauge
+luxws_defrost_active 0
+# HELP luxws_defrost_cycles_total Number of completed defrost cycles
+# TYPE luxws_defrost_cycles_total counter
+luxws_defrost_cycles_total 0
+# HELP luxws_defrost_last_duration_seconds Duration of the most recent completed defrost cycle
+# TYPE luxws_defrost_last_duration_seconds gauge
+luxws_defrost_last_duration_seconds 0
+# HELP luxws_defrost_duration_seconds Duration of completed defrost cycles
+# TYPE luxws_defrost_duration_seconds histogram
+luxws_defrost_duration_seconds_bucket{le="60"} 0
+luxws_defrost_duration_seconds_bucket{le="120"} 0
+luxws_defrost_duration_seconds_bucket{le="180"} 0
+luxws_defrost_duration_seconds_bucket{le="240"} 0
+luxws_defrost_duration_seconds_bucket{le="300"} 0
+luxws_defrost_duration_seconds_bucket{le="450"} 0
+luxws_defrost_duration_seconds_bucket{le="600"} 0
+luxws_defrost_duration_seconds_bucket{le="900"} 0
+luxws_defrost_duration_seconds_bucket{le="1200"} 0
+luxws_defrost_duration_seconds_bucket{le="+Inf"} 0
+luxws_defrost_duration_seconds_sum 0
+luxws_defrost_duration_seconds_count 0
+`,
+		},
+		{
+			name:    "valve open",
+			advance: time.Minute,
+			input:   content("Heizen", "Ein"),
+			want: `
+# HELP luxws_defrost_active Whether a defrost cycle is in progress
+# TYPE luxws_defrost_active gauge
+luxws_defrost_active 1
+# HELP luxws_defrost_cycles_total Number of completed defrost cycles
+# TYPE luxws_defrost_cycles_total counter
+luxws_defrost_cycles_total 0
+# HELP luxws_defrost_last_duration_seconds Duration of the most recent completed defrost cycle
+# TYPE luxws_defrost_last_duration_seconds gauge
+luxws_defrost_last_duration_seconds 0
+# HELP luxws_defrost_duration_seconds Duration of completed defrost cycles
+# TYPE luxws_defrost_duration_seconds histogram
+luxws_defrost_duration_seconds_bucket{le="60"} 0
+luxws_defrost_duration_seconds_bucket{le="120"} 0
+luxws_defrost_duration_seconds_bucket{le="180"} 0
+luxws_defrost_duration_seconds_bucket{le="240"} 0
+luxws_defrost_duration_seconds_bucket{le="300"} 0
+luxws_defrost_duration_seconds_bucket{le="450"} 0
+luxws_defrost_duration_seconds_bucket{le="600"} 0
+luxws_defrost_duration_seconds_bucket{le="900"} 0
+luxws_defrost_duration_seconds_bucket{le="1200"} 0
+luxws_defrost_duration_seconds_bucket{le="+Inf"} 0
+luxws_defrost_duration_seconds_sum 0
+luxws_defrost_duration_seconds_count 0
+`,
+		},
+		{
+			name:    "mode",
+			advance: time.Minute,
+			input:   content("Abtauen", "Aus"),
+		},
+		{
+			name:    "done",
+			advance: 30 * time.Second,
+			input:   content("Heizen", "Aus"),
+			want: `
+# HELP luxws_defrost_active Whether a defrost cycle is in progress
+# TYPE luxws_defrost_active gauge
+luxws_defrost_active 0
+# HELP luxws_defrost_cycles_total Number of completed defrost cycles
+# TYPE luxws_defrost_cycles_total counter
+luxws_defrost_cycles_total 1
+# HELP luxws_defrost_last_duration_seconds Duration of the most recent completed defrost cycle
+# TYPE luxws_defrost_last_duration_seconds gauge
+luxws_defrost_last_duration_seconds 90
+# HELP luxws_defrost_duration_seconds Duration of completed defrost cycles
+# TYPE luxws_defrost_duration_seconds histogram
+luxws_defrost_duration_seconds_bucket{le="60"} 0
+luxws_defrost_duration_seconds_bucket{le="120"} 1
+luxws_defrost_duration_seconds_bucket{le="180"} 1
+luxws_defrost_duration_seconds_bucket{le="240"} 1
+luxws_defrost_duration_seconds_bucket{le="300"} 1
+luxws_defrost_duration_seconds_bucket{le="450"} 1
+luxws_defrost_duration_seconds_bucket{le="600"} 1
+luxws_defrost_duration_seconds_bucket{le="900"} 1
+luxws_defrost_duration_seconds_bucket{le="1200"} 1
+luxws_defrost_duration_seconds_bucket{le="+Inf"} 1
+luxws_defrost_duration_seconds_sum 90
+luxws_defrost_duration_seconds_count 1
+`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			now = now.Add(tc.advance)
+
+			ch := make(chan reading, 10)
+
+			if tc.want == "" {
+				if err := c.collectDefrost(ch, tc.input); err != nil {
+					t.Errorf("collectDefrost() failed: %v", err)
+				}
+
+				return
+			}
+
+			a := &adapter{
+				c: c,
+				collect: func(ch chan<- reading) error {
+					return c.collectDefrost(ch, tc.input)
+				},
+			}
+			a.collectAndCompare(t, tc.want, nil)
+		})
+	}
+}
+
+func TestDefrostDetectorBucketsChanged(t *testing.T) {
+	discardAllLogs(t)
+
+	stateFile := filepath.Join(t.TempDir(), "defrost.json")
+
+	if err := os.WriteFile(stateFile, []byte(`{"cycles":3,"last_seconds":60,`+
+		`"durations":{"bounds":[60],"buckets":[3],"count":3,"sum":150}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := newDefrostDetector(stateFile)
+	if err != nil {
+		t.Fatalf("newDefrostDetector() failed: %v", err)
+	}
+
+	// Cycles are kept, durations restart with the current buckets
+	want := defrostState{
+		Cycles:      3,
+		LastSeconds: 60,
+		Durations:   newDefrostDurations(),
+	}
+
+	if diff := cmp.Diff(want, d.snapshot()); diff != "" {
+		t.Errorf("State difference (-want +got):\n%s", diff)
+	}
+}
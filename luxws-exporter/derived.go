@@ -0,0 +1,114 @@
+package main
+
+import (
+	"github.com/hansmi/wp2reg-luxws/luxwsclient"
+)
+
+// Volumetric heat capacity of water in joules per liter and kelvin.
+const waterHeatCapacity = 4186
+
+// measurement is a parsed value with its normalized unit.
+type measurement struct {
+	value float64
+	unit  string
+}
+
+// findMeasurement returns the parsed value of the first item with the given
+// name in a group. Missing groups, items and unparseable values are not an
+// error as derived metrics are best-effort.
+func (c *collector) findMeasurement(content *luxwsclient.ContentRoot, groupName, name string) (measurement, bool) {
+	group := content.FindByName(groupName)
+	if group == nil {
+		return measurement{}, false
+	}
+
+	for _, item := range group.Items {
+		if item.Value == nil || normalizeSpace(item.Name) != name {
+			continue
+		}
+
+		if value, unit, err := c.parseValue(*item.Value); err == nil {
+			return measurement{value, unit}, true
+		}
+
+		break
+	}
+
+	return measurement{}, false
+}
+
+// watts converts a power measurement to watts.
+func (m measurement) watts() (float64, bool) {
+	switch m.unit {
+	case "kW":
+		return m.value * 1000, true
+	}
+
+	return 0, false
+}
+
+// litersPerSecond converts a flow rate measurement to liters per second.
+func (m measurement) litersPerSecond() (float64, bool) {
+	switch m.unit {
+	case "l/h":
+		return m.value / 3600, true
+	case "m³/h":
+		return m.value * 1000 / 3600, true
+	}
+
+	return 0, false
+}
+
+// collectDerived computes metrics from correlated measurements: the spread
+// between flow and return temperature, the thermal power delivered by the
+// water flow and the coefficient of performance. Metrics are only exported if
+// all inputs are available with known units.
+func (c *collector) collectDerived(ch chan<- reading, content *luxwsclient.ContentRoot, _ *quirks) error {
+	var spread, thermalPower float64
+	var hasSpread, hasThermalPower bool
+
+	if flow, ok := c.findMeasurement(content, c.terms.NavTemperatures, c.terms.TemperatureFlow); ok && flow.unit == "degC" {
+		if ret, ok := c.findMeasurement(content, c.terms.NavTemperatures, c.terms.TemperatureReturn); ok && ret.unit == "degC" {
+			spread = flow.value - ret.value
+			hasSpread = true
+
+			ch <- newReading(c.tempSpreadDesc, spread)
+		}
+	}
+
+	if rate, ok := c.findMeasurement(content, c.terms.NavInputs, c.terms.InputFlowRate); ok && hasSpread {
+		if lps, ok := rate.litersPerSecond(); ok {
+			thermalPower = lps * spread * waterHeatCapacity
+			hasThermalPower = true
+
+			ch <- newReading(c.thermalPowerDesc, thermalPower)
+		}
+	}
+
+	input, ok := c.findMeasurement(content, c.terms.NavSystemStatus, c.terms.StatusPowerInput)
+	if !ok {
+		return nil
+	}
+
+	inputWatts, ok := input.watts()
+	if !ok || inputWatts <= 0 {
+		return nil
+	}
+
+	ch <- newReading(c.powerInputDesc, inputWatts)
+
+	// Prefer the heat output reported by the controller over the computed
+	// value.
+	if output, ok := c.findMeasurement(content, c.terms.NavSystemStatus, c.terms.StatusPowerOutput); ok {
+		if outputWatts, ok := output.watts(); ok {
+			thermalPower = outputWatts
+			hasThermalPower = true
+		}
+	}
+
+	if hasThermalPower {
+		ch <- newReading(c.copDesc, thermalPower/inputWatts)
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hansmi/wp2reg-luxws/luxwsclient"
+	"github.com/hansmi/wp2reg-luxws/luxwslang"
+)
+
+func TestCollectDerived(t *testing.T) {
+	c := newCollector(collectorOpts{
+		terms: luxwslang.German,
+		loc:   time.UTC,
+	})
+
+	content := func(temps, inputs, status []luxwsclient.ContentItem) *luxwsclient.ContentRoot {
+		return &luxwsclient.ContentRoot{
+			Items: []luxwsclient.ContentItem{
+				{Name: "Temperaturen", Items: temps},
+				{Name: "Eingänge", Items: inputs},
+				{Name: "Anlagenstatus", Items: status},
+			},
+		}
+	}
+
+	for _, tc := range []struct {
+		name  string
+		input *luxwsclient.ContentRoot
+		want  string
+	}{
+		{
+			name:  "empty",
+			input: &luxwsclient.ContentRoot{},
+		},
+		{
+			name: "all",
+			input: content(
+				[]luxwsclient.ContentItem{
+					{Name: "Vorlauf", Value: luxwsclient.String("35.0 °C")},
+					{Name: "Rücklauf", Value: luxwsclient.String("30.0 °C")},
+				},
+				[]luxwsclient.ContentItem{
+					{Name: "Durchfluss", Value: luxwsclient.String("0.72 m³/h")},
+				},
+				[]luxwsclient.ContentItem{
+					{Name: "Leistungsaufnahme", Value: luxwsclient.String("1.0 kW")},
+				},
+			),
+			want: `
+# HELP luxws_coefficient_of_performance Instantaneous coefficient of performance (heat output divided by electrical power input)
+# TYPE luxws_coefficient_of_performance gauge
+luxws_coefficient_of_performance 4.186
+# HELP luxws_power_input_watts Electrical power input
+# TYPE luxws_power_input_watts gauge
+luxws_power_input_watts 1000
+# HELP luxws_temperature_spread_kelvins Difference between flow and return temperature
+# TYPE luxws_temperature_spread_kelvins gauge
+luxws_temperature_spread_kelvins 5
+# HELP luxws_thermal_power_watts Thermal power computed from flow rate and temperature spread
+# TYPE luxws_thermal_power_watts gauge
+luxws_thermal_power_watts 4186
+`,
+		},
+		{
+			name: "reported heat output",
+			input: content(
+				nil,
+				nil,
+				[]luxwsclient.ContentItem{
+					{Name: "Leistung Ist", Value: luxwsclient.String("6 kW")},
+					{Name: "Leistungsaufnahme", Value: luxwsclient.String("1.5 kW")},
+				},
+			),
+			want: `
+# HELP luxws_coefficient_of_performance Instantaneous coefficient of performance (heat output divided by electrical power input)
+# TYPE luxws_coefficient_of_performance gauge
+luxws_coefficient_of_performance 4
+# HELP luxws_power_input_watts Electrical power input
+# TYPE luxws_power_input_watts gauge
+luxws_power_input_watts 1500
+`,
+		},
+		{
+			name: "unusable",
+			input: content(
+				[]luxwsclient.ContentItem{
+					{Name: "Vorlauf", Value: luxwsclient.String("35.0 °C")},
+					{Name: "Rücklauf", Value: luxwsclient.String("garbage")},
+				},
+				[]luxwsclient.ContentItem{
+					{Name: "Durchfluss", Value: luxwsclient.String("1000 l/h")},
+				},
+				[]luxwsclient.ContentItem{
+					{Name: "Leistung Ist", Value: luxwsclient.String("6 kW")},
+					{Name: "Leistungsaufnahme", Value: luxwsclient.String("0 kW")},
+				},
+			),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			a := &adapter{
+				c: c,
+				collect: func(ch chan<- reading) error {
+					return c.collectDerived(ch, tc.input, nil)
+				},
+			}
+			a.collectAndCompare(t, tc.want, nil)
+		})
+	}
+}
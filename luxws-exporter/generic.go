@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hansmi/wp2reg-luxws/luxwsclient"
+	"go.uber.org/multierr"
+)
+
+// genericOpts configures the export of all items found on all pages.
+type genericOpts struct {
+	enabled bool
+
+	// Only items with a matching path are exported if set.
+	include *regexp.Regexp
+
+	// Items with a matching path are not exported if set.
+	exclude *regexp.Regexp
+}
+
+func (o genericOpts) matches(path string) bool {
+	return (o.include == nil || o.include.MatchString(path)) &&
+		(o.exclude == nil || !o.exclude.MatchString(path))
+}
+
+// compileAnchored compiles a regular expression matching the whole input.
+// An empty expression returns nil.
+func compileAnchored(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+
+	return regexp.Compile("^(?:" + expr + ")$")
+}
+
+type contentGetter interface {
+	Get(context.Context, string) (*luxwsclient.ContentRoot, error)
+}
+
+// genericValue parses the value of an item. Booleans, measurements and
+// enumerations are numeric. All other values are returned as text.
+func (c *collector) genericValue(item *luxwsclient.ContentItem) (value float64, unit string, numeric bool) {
+	if len(item.Options) > 0 {
+		if item.Raw != nil {
+			if v, err := strconv.ParseFloat(strings.TrimSpace(*item.Raw), 64); err == nil {
+				return v, "", true
+			}
+		}
+
+		for _, opt := range item.Options {
+			if opt.Name == *item.Value {
+				if v, err := strconv.ParseFloat(strings.TrimSpace(opt.Value), 64); err == nil {
+					return v, "", true
+				}
+			}
+		}
+	}
+
+	if v, unit, err := c.parseValue(*item.Value); err == nil {
+		return v, unit, true
+	}
+
+	return 0, "", false
+}
+
+// collectGenericItems exports all items below the given ones. Paths are built
+// from the normalized item names separated by slashes. Duplicate names within
+// a group are made unique by appending a counter (e.g. "[2]").
+func (c *collector) collectGenericItems(ch chan<- reading, prefix string, items []luxwsclient.ContentItem) {
+	seen := map[string]int{}
+
+	for idx := range items {
+		item := &items[idx]
+		name := normalizeSpace(item.Name)
+
+		seen[name]++
+
+		if count := seen[name]; count > 1 {
+			name = fmt.Sprintf("%s[%d]", name, count)
+		}
+
+		path := prefix + "/" + name
+
+		if len(item.Items) > 0 {
+			c.collectGenericItems(ch, path, item.Items)
+		}
+
+		if item.Value == nil || !c.generic.matches(path) {
+			continue
+		}
+
+		if value, unit, ok := c.genericValue(item); ok {
+			ch <- newReading(c.genericValueDesc, value, path, unit)
+		} else {
+			ch <- newReading(c.genericTextDesc, 1, path, normalizeSpace(*item.Value))
+		}
+	}
+}
+
+// collectGeneric fetches all top-level navigation pages and exports their
+// items. Already fetched pages can be supplied by their ID.
+func (c *collector) collectGeneric(ctx context.Context, ch chan<- reading, cl contentGetter, nav *luxwsclient.NavRoot, fetched map[string]*luxwsclient.ContentRoot) error {
+	var err error
+
+	seen := map[string]bool{}
+
+	for _, page := range nav.Items {
+		name := normalizeSpace(page.Name)
+
+		if seen[name] {
+			continue
+		}
+
+		seen[name] = true
+
+		content := fetched[page.ID]
+
+		if content == nil {
+			var getErr error
+
+			if content, getErr = cl.Get(ctx, page.ID); getErr != nil {
+				multierr.AppendInto(&err, fmt.Errorf("fetching page %q failed: %w", name, getErr))
+				continue
+			}
+		}
+
+		c.collectGenericItems(ch, name, content.Items)
+	}
+
+	return err
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/hansmi/wp2reg-luxws/luxwsclient"
+	"github.com/hansmi/wp2reg-luxws/luxwslang"
+)
+
+type fakeContentGetter map[string]*luxwsclient.ContentRoot
+
+func (g fakeContentGetter) Get(_ context.Context, id string) (*luxwsclient.ContentRoot, error) {
+	if content, ok := g[id]; ok {
+		return content, nil
+	}
+
+	return nil, errors.New("not found")
+}
+
+func TestCollectGeneric(t *testing.T) {
+	nav := &luxwsclient.NavRoot{
+		Items: []luxwsclient.NavItem{
+			{ID: "0x1", Name: "Informationen"},
+			{ID: "0x2", Name: "Einstellungen"},
+			{ID: "0x3", Name: "Zugang"},
+		},
+	}
+
+	info := &luxwsclient.ContentRoot{
+		Items: []luxwsclient.ContentItem{
+			{
+				Name: "Temperaturen",
+				Items: []luxwsclient.ContentItem{
+					{Name: "Vorlauf", Value: luxwsclient.String("30.1°C")},
+					{Name: "Rücklauf", Value: luxwsclient.String("25.4 °C")},
+				},
+			},
+			{
+				Name: "Anlagenstatus",
+				Items: []luxwsclient.ContentItem{
+					{Name: "Wärmepumpen Typ", Value: luxwsclient.String("LWD")},
+					{Name: "Wärmepumpen Typ", Value: luxwsclient.String("L2A")},
+					{Name: "Abtaubedarf", Value: luxwsclient.String("Ein")},
+				},
+			},
+		},
+	}
+
+	getter := fakeContentGetter{
+		"0x2": {
+			Items: []luxwsclient.ContentItem{
+				{
+					Name: "Betriebsart",
+					Items: []luxwsclient.ContentItem{
+						{
+							Name: "Heizung",
+							Options: []luxwsclient.ContentItemOption{
+								{Value: "0", Name: "Automatik"},
+								{Value: "4", Name: "Aus"},
+							},
+							Value: luxwsclient.String("Aus"),
+						},
+						{
+							Name: "Warmwasser",
+							Options: []luxwsclient.ContentItemOption{
+								{Value: "0", Name: "Automatik"},
+							},
+							Raw:   luxwsclient.String("0"),
+							Value: luxwsclient.String("Automatik"),
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tc := range []struct {
+		name    string
+		opts    genericOpts
+		want    string
+		wantErr string
+	}{
+		{
+			name: "all",
+			want: `
+# HELP luxws_text_info Textual value of an item
+# TYPE luxws_text_info gauge
+luxws_text_info{path="Informationen/Anlagenstatus/Wärmepumpen Typ",value="LWD"} 1
+luxws_text_info{path="Informationen/Anlagenstatus/Wärmepumpen Typ[2]",value="L2A"} 1
+# HELP luxws_value Numeric value of an item
+# TYPE luxws_value gauge
+luxws_value{path="Einstellungen/Betriebsart/Heizung",unit=""} 4
+luxws_value{path="Einstellungen/Betriebsart/Warmwasser",unit=""} 0
+luxws_value{path="Informationen/Anlagenstatus/Abtaubedarf",unit="bool"} 1
+luxws_value{path="Informationen/Temperaturen/Rücklauf",unit="degC"} 25.4
+luxws_value{path="Informationen/Temperaturen/Vorlauf",unit="degC"} 30.1
+`,
+			wantErr: `fetching page "Zugang" failed: not found`,
+		},
+		{
+			name: "filtered",
+			opts: genericOpts{
+				include: regexp.MustCompile(`^(?:Informationen/.*)$`),
+				exclude: regexp.MustCompile(`^(?:.*/Vorlauf|.*Typ.*)$`),
+			},
+			want: `
+# HELP luxws_value Numeric value of an item
+# TYPE luxws_value gauge
+luxws_value{path="Informationen/Anlagenstatus/Abtaubedarf",unit="bool"} 1
+luxws_value{path="Informationen/Temperaturen/Rücklauf",unit="degC"} 25.4
+`,
+			wantErr: `fetching page "Zugang" failed: not found`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.opts.enabled = true
+
+			c := newCollector(collectorOpts{
+				terms:   luxwslang.German,
+				loc:     time.UTC,
+				generic: tc.opts,
+			})
+
+			var err error
+
+			a := &adapter{
+				c:           c,
+				metricNames: []string{"luxws_value", "luxws_text_info"},
+				collect: func(ch chan<- reading) error {
+					err = c.collectGeneric(context.Background(), ch, getter, nav, map[string]*luxwsclient.ContentRoot{
+						"0x1": info,
+					})
+
+					return nil
+				},
+			}
+			a.collectAndCompare(t, tc.want, nil)
+
+			if err == nil || err.Error() != tc.wantErr {
+				t.Errorf("collectGeneric() returned %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestCompileAnchored(t *testing.T) {
+	if re, err := compileAnchored(""); err != nil || re != nil {
+		t.Errorf("compileAnchored(\"\") = %v, %v; want nil", re, err)
+	}
+
+	re, err := compileAnchored("a|b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for input, want := range map[string]bool{
+		"a":  true,
+		"b":  true,
+		"ab": false,
+		"xa": false,
+	} {
+		if got := re.MatchString(input); got != want {
+			t.Errorf("MatchString(%q) = %v, want %v", input, got, want)
+		}
+	}
+
+	if _, err := compileAnchored("("); err == nil {
+		t.Errorf("Invalid expression didn't fail")
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hansmi/wp2reg-luxws/luxwsclient"
+)
+
+// contactStatus describes the most recent attempt to log into a controller.
+type contactStatus struct {
+	lastAttempt time.Time
+	lastSuccess time.Time
+	lastErr     error
+}
+
+func (c *collector) recordContact(err error) {
+	c.contactMu.Lock()
+	defer c.contactMu.Unlock()
+
+	c.contact.lastAttempt = c.now()
+	c.contact.lastErr = err
+
+	if err == nil {
+		c.contact.lastSuccess = c.contact.lastAttempt
+	}
+}
+
+func (c *collector) contactStatus() contactStatus {
+	c.contactMu.Lock()
+	defer c.contactMu.Unlock()
+
+	return c.contact
+}
+
+// connect establishes a connection to the controller and logs in. The
+// outcome is recorded for readiness checks.
+func (c *collector) connect(ctx context.Context, extraOpts ...luxwsclient.Option) (*luxwsclient.Client, *luxwsclient.NavRoot, error) {
+	opts := append(slices.Clip(c.clientOpts), extraOpts...)
+
+	cl, err := luxwsclient.Dial(ctx, c.address, opts...)
+	if err != nil {
+		c.recordContact(err)
+		return nil, nil, withStatus(statusDial, err)
+	}
+
+	nav, err := cl.Login(ctx, c.password)
+	c.recordContact(err)
+
+	if err != nil {
+		cl.Close()
+		return nil, nil, withStatus(statusLogin, err)
+	}
+
+	return cl, nav, nil
+}
+
+// checkReady verifies that the most recent attempt to contact the controller
+// succeeded and that it happened within the given window. The controller
+// itself is not contacted.
+func (c *collector) checkReady(window time.Duration) error {
+	status := c.contactStatus()
+
+	switch {
+	case status.lastAttempt.IsZero():
+		return errors.New("not contacted yet")
+
+	case status.lastErr != nil:
+		return status.lastErr
+
+	case c.now().Sub(status.lastSuccess) > window:
+		return fmt.Errorf("last successful contact at %s", status.lastSuccess.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// readyHandler reports whether all controllers were reachable recently.
+type readyHandler struct {
+	window  time.Duration
+	targets func() map[string]*collector
+}
+
+func (h *readyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	targets := h.targets()
+
+	names := make([]string, 0, len(targets))
+
+	for name := range targets {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var lines []string
+
+	ready := true
+
+	for _, name := range names {
+		if err := targets[name].checkReady(h.window); err != nil {
+			ready = false
+			lines = append(lines, fmt.Sprintf("%s: %v", name, err))
+		} else {
+			lines = append(lines, fmt.Sprintf("%s: ok", name))
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	if ready {
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, "Ready.\n")
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		io.WriteString(w, "Not ready.\n")
+	}
+
+	io.WriteString(w, strings.Join(append(lines, ""), "\n"))
+}
+
+// healthyHandler reports liveness of the process.
+func healthyHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.WriteString(w, "Healthy.\n")
+}
+
+// healthcheckURL derives the URL of a health endpoint from the first listen
+// address.
+func healthcheckURL(listenAddresses []string, path string, useTLS bool) (string, error) {
+	if len(listenAddresses) == 0 {
+		return "", errors.New("no listen address")
+	}
+
+	host, port, err := net.SplitHostPort(listenAddresses[0])
+	if err != nil {
+		return "", err
+	}
+
+	if host == "" {
+		host = "localhost"
+	}
+
+	scheme := "http"
+
+	if useTLS {
+		scheme = "https"
+	}
+
+	return (&url.URL{
+		Scheme: scheme,
+		Host:   net.JoinHostPort(host, port),
+		Path:   path,
+	}).String(), nil
+}
+
+type healthcheckOpts struct {
+	timeout time.Duration
+
+	// Credentials for basic authentication. Not sent unless a username is
+	// given.
+	username string
+	password string
+
+	// Skip verification of the server certificate. Certificates rarely
+	// cover the listen address used when the URL is derived.
+	insecureSkipVerify bool
+}
+
+// runHealthcheck queries a health endpoint and returns an error unless the
+// response status is 200.
+func runHealthcheck(ctx context.Context, url string, opts healthcheckOpts) error {
+	ctx, cancel := context.WithTimeout(ctx, opts.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	if opts.username != "" {
+		req.SetBasicAuth(opts.username, opts.password)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		InsecureSkipVerify: opts.insecureSkipVerify,
+	}
+
+	defer transport.CloseIdleConnections()
+
+	resp, err := (&http.Client{Transport: transport}).Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s\n%s", url, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
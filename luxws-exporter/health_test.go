@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hansmi/wp2reg-luxws/luxwslang"
+)
+
+func TestReadyHandler(t *testing.T) {
+	now := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	newTarget := func(lastAttempt time.Time, lastErr error) *collector {
+		c := newCollector(collectorOpts{
+			terms:   luxwslang.English,
+			loc:     time.UTC,
+			timeout: time.Minute,
+			address: "192.0.2.1:8214",
+		})
+		c.now = func() time.Time {
+			return now
+		}
+		c.contact.lastAttempt = lastAttempt
+		c.contact.lastErr = lastErr
+
+		if lastErr == nil {
+			c.contact.lastSuccess = lastAttempt
+		} else {
+			c.contact.lastSuccess = lastAttempt.Add(-time.Minute)
+		}
+
+		return c
+	}
+
+	for _, tc := range []struct {
+		name     string
+		targets  map[string]*collector
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "no targets",
+			wantCode: http.StatusOK,
+			wantBody: "Ready.\n",
+		},
+		{
+			name: "recent contact",
+			targets: map[string]*collector{
+				"a": newTarget(now.Add(-time.Minute), nil),
+			},
+			wantCode: http.StatusOK,
+			wantBody: "Ready.\na: ok\n",
+		},
+		{
+			name: "unreachable",
+			targets: map[string]*collector{
+				"a": newTarget(now.Add(-time.Minute), nil),
+				"b": newTarget(now.Add(-time.Hour), nil),
+				"c": newTarget(time.Time{}, nil),
+				"d": newTarget(now.Add(-time.Second), errors.New("login failed")),
+			},
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: "Not ready.\n" +
+				"a: ok\n" +
+				"b: last successful contact at 2019-12-31T23:00:00Z\n" +
+				"c: not contacted yet\n" +
+				"d: login failed\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := &readyHandler{
+				window: 5 * time.Minute,
+				targets: func() map[string]*collector {
+					return tc.targets
+				},
+			}
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/-/ready", nil))
+
+			if rec.Code != tc.wantCode {
+				t.Errorf("Got status %d, want %d", rec.Code, tc.wantCode)
+			}
+
+			if got := rec.Body.String(); got != tc.wantBody {
+				t.Errorf("Got body %q, want %q", got, tc.wantBody)
+			}
+		})
+	}
+}
+
+func TestHealthcheck(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/-/healthy", healthyHandler)
+	mux.HandleFunc("/-/ready", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Not ready.", http.StatusServiceUnavailable)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	opts := healthcheckOpts{timeout: time.Minute}
+
+	if err := runHealthcheck(ctx, server.URL+"/-/healthy", opts); err != nil {
+		t.Errorf("runHealthcheck() failed: %v", err)
+	}
+
+	if err := runHealthcheck(ctx, server.URL+"/-/ready", opts); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("runHealthcheck() returned %v, want status error", err)
+	}
+
+	resp, err := http.Get(server.URL + "/-/healthy")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	if body, _ := io.ReadAll(resp.Body); string(body) != "Healthy.\n" {
+		t.Errorf("Got body %q", body)
+	}
+}
+
+func TestHealthcheckTLSAndAuth(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "admin" || password != "secret" {
+			http.Error(w, "", http.StatusUnauthorized)
+			return
+		}
+
+		healthyHandler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	for _, tc := range []struct {
+		name    string
+		opts    healthcheckOpts
+		wantErr string
+	}{
+		{
+			name:    "unverified certificate",
+			opts:    healthcheckOpts{username: "admin", password: "secret"},
+			wantErr: "certificate",
+		},
+		{
+			name:    "no credentials",
+			opts:    healthcheckOpts{insecureSkipVerify: true},
+			wantErr: "401",
+		},
+		{
+			name:    "wrong password",
+			opts:    healthcheckOpts{insecureSkipVerify: true, username: "admin", password: "wrong"},
+			wantErr: "401",
+		},
+		{
+			name: "success",
+			opts: healthcheckOpts{insecureSkipVerify: true, username: "admin", password: "secret"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.opts.timeout = time.Minute
+
+			err := runHealthcheck(context.Background(), server.URL+"/-/healthy", tc.opts)
+
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Errorf("runHealthcheck() failed: %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("runHealthcheck() returned %v, want error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestHealthcheckURL(t *testing.T) {
+	for _, tc := range []struct {
+		addresses []string
+		useTLS    bool
+		want      string
+		wantErr   bool
+	}{
+		{addresses: nil, wantErr: true},
+		{addresses: []string{":8081"}, want: "http://localhost:8081/-/healthy"},
+		{addresses: []string{"192.0.2.1:9000", ":8081"}, want: "http://192.0.2.1:9000/-/healthy"},
+		{addresses: []string{"[::1]:8081"}, want: "http://[::1]:8081/-/healthy"},
+		{addresses: []string{":8081"}, useTLS: true, want: "https://localhost:8081/-/healthy"},
+		{addresses: []string{"garbage"}, wantErr: true},
+	} {
+		got, err := healthcheckURL(tc.addresses, "/-/healthy", tc.useTLS)
+
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("healthcheckURL(%q) didn't fail", tc.addresses)
+			}
+		} else if err != nil {
+			t.Errorf("healthcheckURL(%q) failed: %v", tc.addresses, err)
+		} else if got != tc.want {
+			t.Errorf("healthcheckURL(%q) = %q, want %q", tc.addresses, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hansmi/wp2reg-luxws/luxwsclient"
+	"github.com/hansmi/wp2reg-luxws/luxwslang"
+	"go.uber.org/multierr"
+)
+
+// impulseSample is a compressor start count observed at a specific time.
+type impulseSample struct {
+	count float64
+	ts    time.Time
+}
+
+// compressorStats contains the values for a single compressor as found in
+// the operating hours group.
+type compressorStats struct {
+	name     string
+	starts   float64
+	hours    time.Duration
+	hasHours bool
+}
+
+// parseCompressorStats finds all impulse items in the operating hours group.
+// Items with unparseable, non-finite or negative values are skipped and
+// reported as item errors.
+// The compressor name is the item name without the impulse prefix (e.g.
+// "VD1" for "Impulse VD1"). The operating hours are taken from the first
+// other item ending with the same name (e.g. "Betriebstund. VD1").
+func (c *collector) parseCompressorStats(group *luxwsclient.ContentItem) ([]compressorStats, error) {
+	var result []compressorStats
+	var itemErr error
+
+	for _, item := range group.Items {
+		if item.Value == nil || !c.terms.HoursImpulsesRe.MatchString(item.Name) {
+			continue
+		}
+
+		text := strings.TrimSpace(*item.Value)
+
+		if text == "" {
+			continue
+		}
+
+		starts, err := strconv.ParseFloat(text, 64)
+		if err == nil && !(luxwslang.IsFinite(starts) && starts >= 0) {
+			err = fmt.Errorf("start count %q is not a finite, non-negative number", text)
+		}
+
+		if err != nil {
+			multierr.AppendInto(&itemErr, newItemError(reasonInvalidNumber, item.Name,
+				fmt.Errorf("parsing impulses of %q failed: %w", item.Name, err)))
+			continue
+		}
+
+		stats := compressorStats{
+			name:   normalizeSpace(c.terms.HoursImpulsesRe.ReplaceAllString(item.Name, "")),
+			starts: starts,
+		}
+
+		for _, other := range group.Items {
+			if other.Value == nil || c.terms.HoursImpulsesRe.MatchString(other.Name) ||
+				!strings.HasSuffix(normalizeSpace(other.Name), " "+stats.name) {
+				continue
+			}
+
+			if hours, err := c.terms.ParseDuration(*other.Value); err == nil {
+				stats.hours = hours
+				stats.hasHours = true
+				break
+			}
+		}
+
+		result = append(result, stats)
+	}
+
+	return result, itemErr
+}
+
+// collectImpulses exports compressor start counts and metrics derived from
+// them. The start rate is computed from the count observed during the
+// previous collection.
+func (c *collector) collectImpulses(ch chan<- reading, content *luxwsclient.ContentRoot) error {
+	group, err := findContentItem(content, c.terms.NavOpHours)
+	if err != nil {
+		return err
+	}
+
+	// Unparseable items are skipped
+	allStats, itemErr := c.parseCompressorStats(group)
+
+	now := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, stats := range allStats {
+		c.observeCounter(compressorStartsName, stats.name, stats.starts)
+
+		ch <- newReading(c.compressorStartsDesc, stats.starts, stats.name)
+
+		if stats.hasHours && stats.starts > 0 {
+			ch <- newReading(c.compressorAvgRuntimeDesc,
+				stats.hours.Seconds()/stats.starts, stats.name)
+		}
+
+		if prev, ok := c.impulses[stats.name]; ok && stats.starts >= prev.count {
+			if elapsed := now.Sub(prev.ts); elapsed > 0 {
+				ch <- newReading(c.compressorStartRateDesc,
+					(stats.starts-prev.count)/elapsed.Hours(), stats.name)
+			}
+		}
+
+		c.impulses[stats.name] = impulseSample{
+			count: stats.starts,
+			ts:    now,
+		}
+	}
+
+	return itemErr
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hansmi/wp2reg-luxws/luxwsclient"
+	"github.com/hansmi/wp2reg-luxws/luxwslang"
+)
+
+func TestCollectImpulses(t *testing.T) {
+	c := newCollector(collectorOpts{
+		terms: luxwslang.German,
+		loc:   time.UTC,
+	})
+
+	now := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	c.now = func() time.Time {
+		return now
+	}
+
+	opHours := func(impulsesVD1, impulsesVD2 string) *luxwsclient.ContentRoot {
+		return &luxwsclient.ContentRoot{
+			Items: []luxwsclient.ContentItem{
+				{
+					Name: "Betriebsstunden",
+					Items: []luxwsclient.ContentItem{
+						{Name: "Betriebstund. VD1", Value: luxwsclient.String("100h")},
+						{Name: "Impulse VD1", Value: luxwsclient.String(impulsesVD1)},
+						{Name: "Durchschn.Laufzeit VD1", Value: luxwsclient.String("1:00")},
+						{Name: "Impulse VD2", Value: luxwsclient.String(impulsesVD2)},
+						{Name: "Betriebstunden WP", Value: luxwsclient.String("200h")},
+					},
+				},
+			},
+		}
+	}
+
+	for _, tc := range []struct {
+		name    string
+		advance time.Duration
+		input   *luxwsclient.ContentRoot
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "first",
+			input: opHours("400", "0"),
+			want: `
+# HELP luxws_compressor_average_runtime_seconds Average compressor runtime per start, computed from operating hours and starts
+# TYPE luxws_compressor_average_runtime_seconds gauge
+luxws_compressor_average_runtime_seconds{name="VD1"} 900
+# HELP luxws_compressor_starts_total Number of compressor starts
+# TYPE luxws_compressor_starts_total counter
+luxws_compressor_starts_total{name="VD1"} 400
+luxws_compressor_starts_total{name="VD2"} 0
+`,
+		},
+		{
+			name:    "second",
+			advance: 30 * time.Minute,
+			input:   opHours("403", "0"),
+			want: `
+# HELP luxws_compressor_average_runtime_seconds Average compressor runtime per start, computed from operating hours and starts
+# TYPE luxws_compressor_average_runtime_seconds gauge
+luxws_compressor_average_runtime_seconds{name="VD1"} 893.3002481389578
+# HELP luxws_compressor_starts_per_hour Compressor starts per hour since the previous collection
+# TYPE luxws_compressor_starts_per_hour gauge
+luxws_compressor_starts_per_hour{name="VD1"} 6
+luxws_compressor_starts_per_hour{name="VD2"} 0
+# HELP luxws_compressor_starts_total Number of compressor starts
+# TYPE luxws_compressor_starts_total counter
+luxws_compressor_starts_total{name="VD1"} 403
+luxws_compressor_starts_total{name="VD2"} 0
+`,
+		},
+		{
+			name:    "counter reset",
+			advance: time.Hour,
+			input:   opHours("1", ""),
+			want: `
+# HELP luxws_compressor_average_runtime_seconds Average compressor runtime per start, computed from operating hours and starts
+# TYPE luxws_compressor_average_runtime_seconds gauge
+luxws_compressor_average_runtime_seconds{name="VD1"} 360000
+# HELP luxws_compressor_starts_total Number of compressor starts
+# TYPE luxws_compressor_starts_total counter
+luxws_compressor_starts_total{name="VD1"} 1
+`,
+		},
+		{
+			name:    "invalid",
+			advance: time.Hour,
+			input:   opHours("many", "0"),
+			want: `
+# HELP luxws_compressor_starts_per_hour Compressor starts per hour since the previous collection
+# TYPE luxws_compressor_starts_per_hour gauge
+luxws_compressor_starts_per_hour{name="VD2"} 0
+# HELP luxws_compressor_starts_total Number of compressor starts
+# TYPE luxws_compressor_starts_total counter
+luxws_compressor_starts_total{name="VD2"} 0
+`,
+			wantErr: true,
+		},
+		{
+			name:    "not finite",
+			advance: time.Hour,
+			input:   opHours("NaN", "0"),
+			want: `
+# HELP luxws_compressor_starts_per_hour Compressor starts per hour since the previous collection
+# TYPE luxws_compressor_starts_per_hour gauge
+luxws_compressor_starts_per_hour{name="VD2"} 0
+# HELP luxws_compressor_starts_total Number of compressor starts
+# TYPE luxws_compressor_starts_total counter
+luxws_compressor_starts_total{name="VD2"} 0
+`,
+			wantErr: true,
+		},
+		{
+			name:    "infinite",
+			advance: time.Hour,
+			input:   opHours("+Inf", "0"),
+			want: `
+# HELP luxws_compressor_starts_per_hour Compressor starts per hour since the previous collection
+# TYPE luxws_compressor_starts_per_hour gauge
+luxws_compressor_starts_per_hour{name="VD2"} 0
+# HELP luxws_compressor_starts_total Number of compressor starts
+# TYPE luxws_compressor_starts_total counter
+luxws_compressor_starts_total{name="VD2"} 0
+`,
+			wantErr: true,
+		},
+		{
+			name:    "negative",
+			advance: time.Hour,
+			input:   opHours("-5", "0"),
+			want: `
+# HELP luxws_compressor_starts_per_hour Compressor starts per hour since the previous collection
+# TYPE luxws_compressor_starts_per_hour gauge
+luxws_compressor_starts_per_hour{name="VD2"} 0
+# HELP luxws_compressor_starts_total Number of compressor starts
+# TYPE luxws_compressor_starts_total counter
+luxws_compressor_starts_total{name="VD2"} 0
+`,
+			wantErr: true,
+		},
+	} {
+		now = now.Add(tc.advance)
+
+		var err error
+
+		a := &adapter{
+			c: c,
+			collect: func(ch chan<- reading) error {
+				err = c.collectImpulses(ch, tc.input)
+				return nil
+			},
+		}
+		a.collectAndCompare(t, tc.want, nil)
+
+		var ie *itemError
+
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: collectImpulses() returned %v, want error %v", tc.name, err, tc.wantErr)
+		} else if err != nil && !(errors.As(err, &ie) && ie.reason == reasonInvalidNumber) {
+			t.Errorf("%s: collectImpulses() returned %v, want invalid number item error", tc.name, err)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// influxEncoder produces InfluxDB line protocol. Every reading becomes a line
+// with the metric name as the measurement, labels as tags and a single
+// "value" field. Histograms are split into their series like in the
+// Prometheus text format (e.g. "_bucket" with an "le" tag).
+type influxEncoder struct{}
+
+func (influxEncoder) contentType() string {
+	return "text/plain; charset=utf-8"
+}
+
+var (
+	influxMeasurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "\n", `\n`)
+	influxTagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`)
+)
+
+type influxSample struct {
+	name   string
+	labels []pushLabel
+	value  float64
+}
+
+// influxSamples flattens a reading into its individual samples. Tags are
+// sorted for best performance.
+func influxSamples(r reading, extraLabels map[string]string) []influxSample {
+	labels := pushLabels(r, extraLabels)
+
+	h := r.histogram
+	if h == nil {
+		return []influxSample{{name: r.desc.name, labels: labels, value: r.value}}
+	}
+
+	result := []influxSample{
+		{name: r.desc.name + "_count", labels: labels, value: float64(h.count)},
+		{name: r.desc.name + "_sum", labels: labels, value: h.sum},
+	}
+
+	bucket := func(le string, count uint64) influxSample {
+		bucketLabels := append(slices.Clip(labels), pushLabel{"le", le})
+
+		sort.Slice(bucketLabels, func(i, j int) bool {
+			return bucketLabels[i].name < bucketLabels[j].name
+		})
+
+		return influxSample{name: r.desc.name + "_bucket", labels: bucketLabels, value: float64(count)}
+	}
+
+	for idx, bound := range h.bounds {
+		result = append(result, bucket(strconv.FormatFloat(bound, 'g', -1, 64), h.buckets[idx]))
+	}
+
+	return append(result, bucket("+Inf", h.count))
+}
+
+func writeInfluxLine(buf *bytes.Buffer, s influxSample, ts time.Time) {
+	buf.WriteString(influxMeasurementEscaper.Replace(s.name))
+
+	for _, l := range s.labels {
+		buf.WriteByte(',')
+		buf.WriteString(influxTagEscaper.Replace(l.name))
+		buf.WriteByte('=')
+		buf.WriteString(influxTagEscaper.Replace(l.value))
+	}
+
+	buf.WriteString(" value=")
+	buf.WriteString(strconv.FormatFloat(s.value, 'g', -1, 64))
+	buf.WriteByte(' ')
+	buf.WriteString(strconv.FormatInt(ts.UnixNano(), 10))
+	buf.WriteByte('\n')
+}
+
+func (influxEncoder) encode(readings []reading, extraLabels map[string]string, ts time.Time, batchSize int) ([][]byte, error) {
+	var result [][]byte
+	var buf bytes.Buffer
+	var count int
+
+	for _, r := range readings {
+		for _, s := range influxSamples(r, extraLabels) {
+			// InfluxDB can't store NaN or infinite values
+			if math.IsNaN(s.value) || math.IsInf(s.value, 0) {
+				continue
+			}
+
+			writeInfluxLine(&buf, s, ts)
+			count++
+
+			if count >= batchSize {
+				result = append(result, bytes.Clone(buf.Bytes()))
+				buf.Reset()
+				count = 0
+			}
+		}
+	}
+
+	if count > 0 {
+		result = append(result, bytes.Clone(buf.Bytes()))
+	}
+
+	return result, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// testPushReadings returns readings of every kind, including one which can't
+// be represented.
+func testPushReadings() []reading {
+	temperature := newReadingDesc("luxws_temperature", "Sensor temperature", []string{"name", "unit"}, readingGauge)
+	starts := newReadingDesc("luxws_compressor_starts_total", "Compressor starts", nil, readingCounter)
+	durations := newReadingDesc("luxws_defrost_duration_seconds", "Defrost durations", nil, readingHistogram)
+
+	return []reading{
+		newReading(temperature, 25.5, "Flow, top", "degC"),
+		newReading(starts, 12),
+		newReading(temperature, math.NaN(), "Invalid", ""),
+		newReading(temperature, 30, "Return", "degC"),
+		newHistogramReading(durations, histogramValue{
+			count:   3,
+			sum:     250,
+			bounds:  []float64{60, 120},
+			buckets: []uint64{1, 2},
+		}),
+	}
+}
+
+func TestInfluxEncoder(t *testing.T) {
+	ts := time.Unix(1577836800, 0)
+
+	got, err := influxEncoder{}.encode(testPushReadings(), map[string]string{"site": "home"}, ts, 2)
+	if err != nil {
+		t.Fatalf("encode() failed: %v", err)
+	}
+
+	var gotText []string
+
+	for _, batch := range got {
+		gotText = append(gotText, string(batch))
+	}
+
+	want := []string{
+		`luxws_temperature,name=Flow\,\ top,site=home,unit=degC value=25.5 1577836800000000000` + "\n" +
+			"luxws_compressor_starts_total,site=home value=12 1577836800000000000\n",
+		"luxws_temperature,name=Return,site=home,unit=degC value=30 1577836800000000000\n" +
+			"luxws_defrost_duration_seconds_count,site=home value=3 1577836800000000000\n",
+		"luxws_defrost_duration_seconds_sum,site=home value=250 1577836800000000000\n" +
+			"luxws_defrost_duration_seconds_bucket,le=60,site=home value=1 1577836800000000000\n",
+		"luxws_defrost_duration_seconds_bucket,le=120,site=home value=2 1577836800000000000\n" +
+			"luxws_defrost_duration_seconds_bucket,le=+Inf,site=home value=3 1577836800000000000\n",
+	}
+
+	if diff := cmp.Diff(want, gotText); diff != "" {
+		t.Errorf("encode() difference (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metricLayout selects how measurements are exported.
+type metricLayout int
+
+const (
+	// One metric family per group with the unit as a label (e.g.
+	// luxws_input{name="...",unit="bar"}).
+	layoutLegacy metricLayout = iota
+
+	// One metric family per group and unit with the unit as a name suffix
+	// (e.g. luxws_input_pressure_bars{name="..."}).
+	layoutUnits
+)
+
+var metricLayoutNames = map[metricLayout]string{
+	layoutLegacy: "legacy",
+	layoutUnits:  "units",
+}
+
+func (l metricLayout) String() string {
+	return metricLayoutNames[l]
+}
+
+func metricLayoutValues() []string {
+	var result []string
+
+	for _, name := range metricLayoutNames {
+		result = append(result, name)
+	}
+
+	sort.Strings(result)
+
+	return result
+}
+
+func parseMetricLayout(name string) (metricLayout, error) {
+	for l, n := range metricLayoutNames {
+		if n == name {
+			return l, nil
+		}
+	}
+
+	return layoutLegacy, fmt.Errorf("unknown metric layout %q (one of %q)", name, metricLayoutValues())
+}
+
+// unitInfo describes how values of a normalized unit (as returned by
+// collector.parseValue) are exported in the units layout.
+type unitInfo struct {
+	// Physical quantity; empty for dimensionless values.
+	quantity string
+
+	// Metric name suffix.
+	suffix string
+
+	// Human-readable unit for help texts.
+	help string
+
+	// Factor applied to values.
+	scale float64
+}
+
+var unitInfos = map[string]unitInfo{
+	"":     {suffix: "value", help: "dimensionless", scale: 1},
+	"bool": {suffix: "state", help: "0 = off, 1 = on", scale: 1},
+	"pct":  {suffix: "ratio", help: "ratio from 0 to 1", scale: 0.01},
+	"degC": {quantity: "temperature", suffix: "celsius", help: "degrees Celsius", scale: 1},
+	"K":    {quantity: "temperature_difference", suffix: "kelvins", help: "kelvins", scale: 1},
+	"bar":  {quantity: "pressure", suffix: "bars", help: "bars", scale: 1},
+	"l/h":  {quantity: "flow_rate", suffix: "liters_per_hour", help: "liters per hour", scale: 1},
+	"m³/h": {quantity: "flow_rate", suffix: "cubic_meters_per_hour", help: "cubic meters per hour", scale: 1},
+	"kWh":  {quantity: "energy", suffix: "kilowatt_hours", help: "kilowatt hours", scale: 1},
+	"kW":   {quantity: "power", suffix: "kilowatts", help: "kilowatts", scale: 1},
+	"rpm":  {quantity: "rotational_speed", suffix: "rpm", help: "revolutions per minute", scale: 1},
+	"V":    {quantity: "voltage", suffix: "volts", help: "volts", scale: 1},
+	"Hz":   {quantity: "frequency", suffix: "hertz", help: "hertz", scale: 1},
+	"mA":   {quantity: "current", suffix: "amperes", help: "amperes", scale: 0.001},
+	"s":    {quantity: "duration", suffix: "seconds", help: "seconds", scale: 1},
+}
+
+// unitFamilies contains one descriptor per unit for a group of measurements.
+type unitFamilies struct {
+	kind  readingKind
+	names map[string]string
+	descs map[string]*readingDesc
+}
+
+// newUnitFamilies builds descriptors for all known units. If includeQuantity
+// is set the physical quantity becomes part of the name, e.g.
+// "luxws_input_pressure_bars" instead of "luxws_input_bars". Names of counter
+// families receive a "_total" suffix.
+func newUnitFamilies(prefix, help string, includeQuantity bool, kind readingKind, labels []string) unitFamilies {
+	result := unitFamilies{
+		kind:  kind,
+		names: map[string]string{},
+		descs: map[string]*readingDesc{},
+	}
+
+	for unit, info := range unitInfos {
+		parts := []string{prefix}
+
+		if includeQuantity && info.quantity != "" {
+			parts = append(parts, info.quantity)
+		}
+
+		parts = append(parts, info.suffix)
+
+		if kind == readingCounter {
+			parts = append(parts, "total")
+		}
+
+		name := strings.Join(parts, "_")
+
+		result.names[unit] = name
+		result.descs[unit] = newReadingDesc(name,
+			fmt.Sprintf("%s (%s)", help, info.help), labels, kind)
+	}
+
+	return result
+}
+
+func (f unitFamilies) describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range f.descs {
+		ch <- desc.prom
+	}
+}
+
+// newReading returns a reading for a value with the given unit. The value is
+// scaled as necessary.
+func (f unitFamilies) newReading(value float64, unit string, labelValues ...string) (reading, error) {
+	desc, ok := f.descs[unit]
+	if !ok {
+		return reading{}, fmt.Errorf("unit %q not supported", unit)
+	}
+
+	return newReading(desc, value*unitInfos[unit].scale, labelValues...), nil
+}
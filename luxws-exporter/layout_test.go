@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hansmi/wp2reg-luxws/luxwsclient"
+	"github.com/hansmi/wp2reg-luxws/luxwslang"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/expfmt"
+)
+
+var updateGolden = flag.Bool("update", false, "Update golden files")
+
+func TestParseMetricLayout(t *testing.T) {
+	for _, l := range []metricLayout{layoutLegacy, layoutUnits} {
+		if got, err := parseMetricLayout(l.String()); err != nil {
+			t.Errorf("parseMetricLayout(%q) failed: %v", l, err)
+		} else if got != l {
+			t.Errorf("parseMetricLayout(%q) = %v", l, got)
+		}
+	}
+
+	if _, err := parseMetricLayout("foo"); err == nil {
+		t.Errorf("parseMetricLayout() with unknown name didn't fail")
+	}
+}
+
+func TestUnitFamiliesComplete(t *testing.T) {
+	families := newUnitFamilies("test", "Test", true, readingGauge, nil)
+
+	names := map[string]string{}
+
+	for unit := range unitInfos {
+		desc, ok := families.descs[unit]
+		if !ok {
+			t.Errorf("Unit %q has no descriptor", unit)
+			continue
+		}
+
+		name := desc.prom.String()
+
+		if other, ok := names[name]; ok {
+			t.Errorf("Units %q and %q share descriptor %s", unit, other, name)
+		}
+
+		names[name] = unit
+	}
+}
+
+func TestUnitsLayoutGolden(t *testing.T) {
+	input := &luxwsclient.ContentRoot{
+		Items: []luxwsclient.ContentItem{
+			{
+				Name: "Temperaturen",
+				Items: []luxwsclient.ContentItem{
+					{Name: "Vorlauf", Value: luxwsclient.String("30.1°C")},
+					{Name: "Rücklauf", Value: luxwsclient.String("25.4 °C")},
+					{Name: "Hysterese", Value: luxwsclient.String("2.0 K")},
+				},
+			},
+			{
+				Name: "Eingänge",
+				Items: []luxwsclient.ContentItem{
+					{Name: "ASD", Value: luxwsclient.String("Ein")},
+					{Name: "HD", Value: luxwsclient.String("Aus")},
+					{Name: "Durchfluss", Value: luxwsclient.String("1200 l/h")},
+					{Name: "Volumenstrom", Value: luxwsclient.String("1.2 m³/h")},
+					{Name: "Niederdruck", Value: luxwsclient.String("7,5 bar")},
+					{Name: "Analog-In", Value: luxwsclient.String("3.30 V")},
+					{Name: "Stromaufnahme", Value: luxwsclient.String("250 mA")},
+					{Name: "Smart Grid", Value: luxwsclient.String("2")},
+				},
+			},
+			{
+				Name: "Ausgänge",
+				Items: []luxwsclient.ContentItem{
+					{Name: "Verdichter", Value: luxwsclient.String("Ein")},
+					{Name: "Ventilator", Value: luxwsclient.String("450 RPM")},
+					{Name: "AO 1", Value: luxwsclient.String("45 %")},
+					{Name: "Frequenz", Value: luxwsclient.String("50 Hz")},
+					{Name: "Laufzeit", Value: luxwsclient.String("2 min")},
+				},
+			},
+			{
+				Name: "Wärmemenge",
+				Items: []luxwsclient.ContentItem{
+					{Name: "Heizung", Value: luxwsclient.String("12345.6 kWh")},
+					{Name: "Warmwasser", Value: luxwsclient.String("2345.6 kWh")},
+				},
+			},
+			{
+				Name: "Anlagenstatus",
+				Items: []luxwsclient.ContentItem{
+					{Name: "Wärmepumpen Typ", Value: luxwsclient.String("LWD")},
+					{Name: "Softwarestand", Value: luxwsclient.String("V3.85.6")},
+					{Name: "Betriebszustand", Value: luxwsclient.String("Heizen")},
+					{Name: "Leistung Ist", Value: luxwsclient.String("4.2 kW")},
+					{Name: "Leistungsaufnahme", Value: luxwsclient.String("1.5 kW")},
+				},
+			},
+			{Name: "Betriebsstunden"},
+			{Name: "Ablaufzeiten"},
+			{Name: "Fehlerspeicher"},
+			{Name: "Abschaltungen"},
+		},
+	}
+
+	c := newCollector(collectorOpts{
+		terms:  luxwslang.German,
+		loc:    time.UTC,
+		layout: layoutUnits,
+	})
+
+	a := &adapter{
+		c: c,
+		collect: func(ch chan<- reading) error {
+			return c.collectAll(ch, input)
+		},
+	}
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(a)
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather() failed: %v", err)
+	}
+
+	if a.collectErr != nil {
+		t.Errorf("Collection failed: %v", a.collectErr)
+	}
+
+	var buf bytes.Buffer
+
+	for _, mf := range families {
+		if _, err := expfmt.MetricFamilyToText(&buf, mf); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	golden := filepath.Join("testdata", "units_layout.prom")
+
+	if *updateGolden {
+		if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.Open(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer want.Close()
+
+	if err := testutil.GatherAndCompare(reg, want); err != nil {
+		t.Error(err)
+	}
+}
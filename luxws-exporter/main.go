@@ -1,9 +1,16 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"html"
 	"log"
 	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/alecthomas/kingpin/v2"
@@ -16,24 +23,100 @@ import (
 	promslogflag "github.com/prometheus/common/promslog/flag"
 	"github.com/prometheus/exporter-toolkit/web"
 	webflag "github.com/prometheus/exporter-toolkit/web/kingpinflag"
+	"golang.org/x/sync/semaphore"
 )
 
+var _ = kingpin.Command("serve", "Run the exporter").Default()
+var healthcheckCmd = kingpin.Command("healthcheck",
+	"Query the health endpoint of a running exporter and exit with a non-zero status on failure (e.g. for a container HEALTHCHECK)")
+var healthcheckURLFlag = healthcheckCmd.Flag("url",
+	"URL to query (default: derived from the first listen address)").PlaceHolder("URL").String()
+var healthcheckReady = healthcheckCmd.Flag("ready",
+	"Query the readiness endpoint instead of the liveness endpoint").Bool()
+var healthcheckUsername = healthcheckCmd.Flag("username",
+	"Username for basic authentication (required if --web.config.file configures basic_auth_users)").String()
+var healthcheckPasswordFile = healthcheckCmd.Flag("password-file",
+	"File containing the password for basic authentication").PlaceHolder("PATH").String()
+var onceCmd = kingpin.Command("once",
+	"Collect a single snapshot from the controller, write it and exit with a non-zero status on failure (e.g. for the node_exporter textfile collector)")
+var onceFormat = onceCmd.Flag("format",
+	fmt.Sprintf("Output format (one of %q)", onceFormats())).Default(onceFormatJSON).Enum(onceFormats()...)
+var onceOutput = onceCmd.Flag("output",
+	`File to replace atomically with the output ("-" = standard output)`).Default("-").PlaceHolder("PATH").String()
+
 var webConfig = webflag.AddFlags(kingpin.CommandLine, ":8081")
 var metricsPath = kingpin.Flag("web.telemetry-path", "Path under which to expose metrics").Default("/metrics").String()
 var disableExporterMetrics = kingpin.Flag("web.disable-exporter-metrics", "Exclude metrics about the exporter itself").Bool()
-var maxConcurrent = kingpin.Flag("web.max-requests", "Maximum number of concurrent scrape requests").Default("3").Uint()
+var maxConcurrent = kingpin.Flag("web.max-requests", "Maximum number of concurrent scrape requests across all targets").Default("3").Uint()
+var readyWindow = kingpin.Flag("web.ready-window",
+	"Readiness requires the most recent controller login to have succeeded within the given window").Default("5m").Duration()
+var debugEndpoint = kingpin.Flag("web.debug-endpoint",
+	"Expose raw responses, parsed content and skipped items of the most recent collection on /debug/last; requires basic authentication via --web.config.file").Bool()
+var probePath = kingpin.Flag("web.probe-path", "Path under which to expose the multi-target probe endpoint").Default("/probe").String()
+var probeAllowedTargets = kingpin.Flag("probe.allowed-target",
+	"host:port permitted as a probe target; may be given multiple times (the probe endpoint is disabled unless at least one target is allowed)").PlaceHolder("HOST:PORT").Strings()
+
+var configFile = kingpin.Flag("config.file",
+	"YAML file defining named controllers; reloaded on SIGHUP or a POST to /-/reload").PlaceHolder("PATH").String()
+
+var pollInterval = kingpin.Flag("poll.interval",
+	"Poll controllers in the background at the given interval and serve the most recent snapshot on scrapes (0 = collect on every scrape)").Default("0").Duration()
+var pollMaxAge = kingpin.Flag("poll.max-age",
+	"Maximum age of a polled snapshot before luxws_up becomes 0 (default: 3 times the poll interval)").Default("0").Duration()
+var pollStateDir = kingpin.Flag("poll.state-dir",
+	"Directory for state persisted across restarts, e.g. defrost cycle counts (default: state is kept in memory)").PlaceHolder("PATH").String()
+
+var outputFile = kingpin.Flag("output.file",
+	`Write the values of every scrape or poll as JSON to the given file; "{controller}" is replaced with the controller name`).PlaceHolder("PATH").String()
+
+var mqttBroker = kingpin.Flag("mqtt.broker",
+	`Publish the values of every scrape or poll to the MQTT broker at the given URL (e.g. "tcp://192.0.2.2:1883")`).PlaceHolder("URL").String()
+var mqttClientID = kingpin.Flag("mqtt.client-id", "MQTT client identifier").Default("luxws-exporter").String()
+var mqttUsername = kingpin.Flag("mqtt.username", "Username for the MQTT broker").String()
+var mqttPasswordFile = kingpin.Flag("mqtt.password-file", "File containing the password for the MQTT broker").PlaceHolder("PATH").String()
+var mqttTopicPrefix = kingpin.Flag("mqtt.topic-prefix", "Prefix for all published topics").Default("luxws").String()
+var mqttQoS = kingpin.Flag("mqtt.qos", "Quality of service level for published messages (0, 1 or 2)").Default("1").Uint8()
+var mqttRetain = kingpin.Flag("mqtt.retain", "Publish values as retained messages").Default("true").Bool()
+var mqttDiscovery = kingpin.Flag("mqtt.discovery", "Publish Home Assistant MQTT discovery configuration").Bool()
+var mqttDiscoveryPrefix = kingpin.Flag("mqtt.discovery-prefix", "Topic prefix for Home Assistant MQTT discovery").Default("homeassistant").String()
+
+var pushInfluxURL = kingpin.Flag("push.influxdb.url",
+	`Push snapshots in InfluxDB line protocol to the given write endpoint (e.g. "http://192.0.2.3:8086/api/v2/write?org=home&bucket=luxws")`).PlaceHolder("URL").String()
+var pushInfluxTokenFile = kingpin.Flag("push.influxdb.token-file", "File containing the InfluxDB API token").PlaceHolder("PATH").String()
+var pushOTLPURL = kingpin.Flag("push.otlp.url",
+	`Push snapshots via OTLP/HTTP with JSON encoding to the given endpoint (e.g. "http://192.0.2.3:4318/v1/metrics")`).PlaceHolder("URL").String()
+var pushOTLPHeaders = kingpin.Flag("push.otlp.header",
+	`Additional HTTP header for OTLP requests (e.g. "Authorization=Bearer ..."); may be given multiple times`).PlaceHolder("NAME=VALUE").Strings()
+var pushBatchSize = kingpin.Flag("push.batch-size", "Maximum number of samples per push request").Default("1000").Int()
+var pushMaxRetries = kingpin.Flag("push.max-retries", "Number of retries with exponential backoff for failed push requests").Default("3").Int()
+var pushBufferDir = kingpin.Flag("push.buffer-dir",
+	"Directory for buffering undelivered push requests (default: undelivered requests are discarded)").PlaceHolder("PATH").String()
+var pushBufferMaxSize = kingpin.Flag("push.buffer-max-size", "Maximum size of buffered push requests per output").Default("10MiB").Bytes()
+
+var layoutName = kingpin.Flag("metrics.layout",
+	fmt.Sprintf("Layout for measurement metrics; %q uses a unit label, %q dedicated unit-suffixed families (one of %q)",
+		layoutLegacy, layoutUnits, metricLayoutValues())).Default(layoutLegacy.String()).String()
+
+var genericEnabled = kingpin.Flag("collector.generic",
+	"Export every item of all pages as luxws_value or luxws_text_info").Bool()
+var genericInclude = kingpin.Flag("collector.generic.include",
+	`Regular expression for item paths (e.g. "Informationen/Temperaturen/.*") to export in generic mode`).PlaceHolder("REGEX").String()
+var genericExclude = kingpin.Flag("collector.generic.exclude",
+	"Regular expression for item paths not to export in generic mode").PlaceHolder("REGEX").String()
 
 var verbose = kingpin.Flag("verbose", "Log sent and received messages").Bool()
 var timeout = kingpin.Flag("scrape-timeout", "Maximum duration for a scrape").Default("1m").Duration()
+var coalesceScrapes = kingpin.Flag("scrape.coalesce",
+	"Let concurrent scrapes of the same controller share a single collection").Bool()
 
 var target = kingpin.Flag("controller.address",
-	`host:port for controller Websocket service (e.g. "192.0.2.1:8214")`).PlaceHolder("HOST:PORT").Required().String()
+	`host:port for controller Websocket service (e.g. "192.0.2.1:8214"); optional when only probing`).PlaceHolder("HOST:PORT").String()
 var httpTarget = kingpin.Flag("controller.address.http",
 	`host:port for controller HTTP service; used to retrieve time (e.g. "192.0.2.1:80")`).PlaceHolder("HOST:PORT").String()
 var timezone = kingpin.Flag("controller.timezone",
 	"Timezone for parsing timestamps").Default(time.Local.String()).String()
 var lang = kingpin.Flag("controller.language",
-	fmt.Sprintf("Controller interface language (one of %q)", supportedLanguages())).PlaceHolder("NAME").Required().String()
+	fmt.Sprintf("Controller interface language (one of %q); default for probes", supportedLanguages())).PlaceHolder("NAME").String()
 
 func supportedLanguages() []string {
 	result := []string{}
@@ -45,18 +128,92 @@ func supportedLanguages() []string {
 	return result
 }
 
+// reloadOnSignal reloads the configuration whenever SIGHUP is received.
+func reloadOnSignal(set *controllerSet) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+
+	for range ch {
+		if err := set.Reload(); err != nil {
+			log.Print(err)
+		} else {
+			log.Print("Configuration reloaded")
+		}
+	}
+}
+
 func main() {
 	promslogConfig := &promslog.Config{}
 	promslogflag.AddFlags(kingpin.CommandLine, promslogConfig)
 
-	kingpin.Parse()
+	cmd := kingpin.Parse()
+
+	if cmd == healthcheckCmd.FullCommand() {
+		path := "/-/healthy"
+
+		if *healthcheckReady {
+			path = "/-/ready"
+		}
+
+		cfg, err := readWebConfig(*webConfig.WebConfigFile)
+		if err != nil {
+			log.Fatalf("Reading web configuration failed: %v", err)
+		}
+
+		opts := healthcheckOpts{
+			timeout:  *timeout,
+			username: *healthcheckUsername,
+		}
+
+		if cfg.basicAuth() && opts.username == "" {
+			log.Fatal("Web configuration requires basic authentication; --username is required")
+		}
+
+		if *healthcheckPasswordFile != "" {
+			password, err := os.ReadFile(*healthcheckPasswordFile)
+			if err != nil {
+				log.Fatalf("Reading password failed: %v", err)
+			}
+
+			opts.password = strings.TrimRight(string(password), "\r\n")
+		}
+
+		url := *healthcheckURLFlag
+
+		if url == "" {
+			if url, err = healthcheckURL(*webConfig.WebListenAddresses, path, cfg.tlsEnabled()); err != nil {
+				log.Fatalf("Determining URL failed: %v", err)
+			}
+
+			opts.insecureSkipVerify = cfg.tlsEnabled()
+		}
+
+		if err := runHealthcheck(context.Background(), url, opts); err != nil {
+			log.Fatal(err)
+		}
+
+		return
+	}
+
+	if *maxConcurrent < 1 {
+		*maxConcurrent = 1
+	}
+
+	if *debugEndpoint {
+		if err := checkDebugAuth(*webConfig.WebConfigFile); err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	opts := collectorOpts{
-		verbose:       *verbose,
-		maxConcurrent: int64(*maxConcurrent),
-		timeout:       *timeout,
-		address:       *target,
-		httpAddress:   *httpTarget,
+		verbose: *verbose,
+		debug:   *debugEndpoint,
+		// Concurrent scrapes are limited across the default target and all
+		// probes.
+		sem:         semaphore.NewWeighted(int64(*maxConcurrent)),
+		timeout:     *timeout,
+		address:     *target,
+		httpAddress: *httpTarget,
 	}
 
 	if loc, err := time.LoadLocation(*timezone); err != nil {
@@ -65,14 +222,199 @@ func main() {
 		opts.loc = loc
 	}
 
-	if terms, err := luxwslang.LookupByID(*lang); err != nil {
+	if *lang == "" {
+		if opts.address != "" {
+			log.Fatalf("Controller language is required")
+		}
+	} else if terms, err := luxwslang.LookupByID(*lang); err != nil {
 		log.Fatalf("Unknown controller language: %v", err)
 	} else {
 		opts.terms = terms
 	}
 
+	if layout, err := parseMetricLayout(*layoutName); err != nil {
+		log.Fatal(err)
+	} else {
+		opts.layout = layout
+	}
+
+	opts.generic.enabled = *genericEnabled
+
+	if re, err := compileAnchored(*genericInclude); err != nil {
+		log.Fatalf("Invalid include expression: %v", err)
+	} else {
+		opts.generic.include = re
+	}
+
+	if re, err := compileAnchored(*genericExclude); err != nil {
+		log.Fatalf("Invalid exclude expression: %v", err)
+	} else {
+		opts.generic.exclude = re
+	}
+
+	if *coalesceScrapes {
+		opts.coalescer = newCoalescer()
+	}
+
+	if cmd == onceCmd.FullCommand() {
+		if opts.address == "" {
+			log.Fatal("Controller address is required")
+		}
+
+		opts.name = "default"
+
+		if err := runOnce(context.Background(), newCollector(opts), *onceFormat, *onceOutput); err != nil {
+			log.Fatal(err)
+		}
+
+		return
+	}
+
+	if opts.address == "" && *configFile == "" && len(*probeAllowedTargets) == 0 {
+		log.Fatal("Controller address, configuration file or allowed probe targets are required")
+	}
+
+	if !*disableExporterMetrics {
+		opts.transportMetrics = newTransportMetrics()
+	}
+
+	poll := pollOpts{
+		interval: *pollInterval,
+		maxAge:   *pollMaxAge,
+		stateDir: *pollStateDir,
+	}
+
+	// Snapshots of scrapes and polls are passed to all outputs.
+	sinks := &sinkSet{}
+	opts.sinks = sinks
+
+	// Polled values are served via the JSON API. Without polling the
+	// controller is contacted for every request.
+	latest := newSnapshotStore()
+
+	if poll.enabled() {
+		sinks.Subscribe("api", latest)
+	}
+
+	mqttConfig := mqttOpts{
+		broker:          *mqttBroker,
+		clientID:        *mqttClientID,
+		username:        *mqttUsername,
+		topicPrefix:     *mqttTopicPrefix,
+		qos:             *mqttQoS,
+		retain:          *mqttRetain,
+		discovery:       *mqttDiscovery,
+		discoveryPrefix: *mqttDiscoveryPrefix,
+		timeout:         *timeout,
+	}
+
+	if err := validateMQTTOpts(mqttConfig); err != nil {
+		log.Fatal(err)
+	}
+
+	if mqttConfig.enabled() {
+		if password, err := readMQTTPassword(*mqttPasswordFile); err != nil {
+			log.Fatal(err)
+		} else {
+			mqttConfig.password = password
+		}
+
+		publisher := newMQTTPublisher(mqttConfig)
+		publisher.Connect()
+
+		defer publisher.Close()
+
+		q := newQueuedSink("mqtt", publisher)
+		go q.Run(context.Background())
+
+		sinks.Subscribe("mqtt", q)
+	}
+
+	if *outputFile != "" {
+		q := newQueuedSink("file", &fileSink{pathTemplate: *outputFile})
+		go q.Run(context.Background())
+
+		sinks.Subscribe("file", q)
+	}
+
 	reg := prometheus.NewPedanticRegistry()
-	reg.MustRegister(newCollector(opts))
+
+	pushSinks, err := newPushSinks(pushConfig{
+		influxURL:       *pushInfluxURL,
+		influxTokenFile: *pushInfluxTokenFile,
+		otlpURL:         *pushOTLPURL,
+		otlpHeaders:     *pushOTLPHeaders,
+		opts: pushOpts{
+			batchSize:      *pushBatchSize,
+			maxRetries:     *pushMaxRetries,
+			minBackoff:     time.Second,
+			maxBackoff:     time.Minute,
+			timeout:        *timeout,
+			bufferDir:      *pushBufferDir,
+			bufferMaxBytes: int64(*pushBufferMaxSize),
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, s := range pushSinks {
+		reg.MustRegister(s.Metrics()...)
+		sinks.Subscribe(s.name, s)
+
+		go s.Run(context.Background())
+	}
+
+	targets := func() map[string]*collector {
+		return nil
+	}
+
+	if opts.address != "" {
+		targetOpts := opts
+		targetOpts.defrost = poll.defrostOpts("default")
+		targetOpts.name = "default"
+
+		c := newCollector(targetOpts)
+
+		targets = func() map[string]*collector {
+			return map[string]*collector{"default": c}
+		}
+
+		if poll.enabled() {
+			p := newPoller(c, poll)
+			go p.Run(context.Background())
+			reg.MustRegister(p)
+		} else {
+			reg.MustRegister(c)
+		}
+	}
+
+	if *configFile != "" {
+		if opts.address != "" {
+			log.Fatalf("Controller address and configuration file are mutually exclusive")
+		}
+
+		set := newControllerSet(*configFile, opts, poll)
+		if err := set.Reload(); err != nil {
+			log.Fatal(err)
+		}
+
+		reg.MustRegister(set)
+		reg.MustRegister(set.Metrics()...)
+
+		http.Handle("/-/reload", set.ReloadHandler())
+		targets = set.Targets
+
+		go reloadOnSignal(set)
+	}
+	if opts.coalescer != nil {
+		reg.MustRegister(opts.coalescer.Metrics()...)
+	}
+
+	if opts.transportMetrics != nil {
+		reg.MustRegister(opts.transportMetrics.Metrics()...)
+	}
+
 	if !*disableExporterMetrics {
 		reg.MustRegister(
 			collectors.NewBuildInfoCollector(),
@@ -83,12 +425,38 @@ func main() {
 	}
 
 	http.Handle(*metricsPath, promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	var probeLink string
+
+	if len(*probeAllowedTargets) > 0 {
+		h, err := newProbeHandler(opts, *probeAllowedTargets)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		http.Handle(*probePath, h)
+
+		probeLink = `<p><a href="` + *probePath + `?target=` + html.EscapeString(url.QueryEscape((*probeAllowedTargets)[0])) + `&amp;language=en">Probe example</a></p>`
+	}
+	http.HandleFunc("/-/healthy", healthyHandler)
+	http.Handle("/-/ready", &readyHandler{
+		window:  *readyWindow,
+		targets: targets,
+	})
+	http.Handle("/api/v1/", &apiHandler{targets: targets, latest: latest})
+	if *debugEndpoint {
+		http.Handle("/debug/last", &debugHandler{
+			webConfigFile: *webConfig.WebConfigFile,
+			targets:       targets,
+		})
+	}
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`<html>
 			<head><title>LuxWS Exporter</title></head>
 			<body>
 			<h1>LuxWS Exporter</h1>
 			<p><a href="` + *metricsPath + `">Metrics</a></p>
+			<p><a href="/api/v1/values">Values (JSON)</a></p>
+			` + probeLink + `
 			</body>
 			</html>`))
 	})
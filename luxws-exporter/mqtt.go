@@ -0,0 +1,368 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"go.uber.org/multierr"
+)
+
+const (
+	mqttPayloadOnline  = "online"
+	mqttPayloadOffline = "offline"
+)
+
+// mqttOpts configures publishing of polled values to an MQTT broker.
+type mqttOpts struct {
+	// Broker URL (e.g. "tcp://192.0.2.2:1883"). Publishing is disabled if
+	// empty.
+	broker   string
+	clientID string
+	username string
+	password string
+
+	// Values are published below "<prefix>/<controller>/".
+	topicPrefix string
+
+	qos    byte
+	retain bool
+
+	// Home Assistant discovery configuration is published below the
+	// discovery prefix if enabled.
+	discovery       bool
+	discoveryPrefix string
+
+	// Maximum duration to wait for the broker to acknowledge the messages
+	// of a snapshot.
+	timeout time.Duration
+}
+
+func (o mqttOpts) enabled() bool {
+	return o.broker != ""
+}
+
+// readMQTTPassword reads the broker password from a file. Trailing newlines
+// are removed.
+func readMQTTPassword(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading MQTT password file: %w", err)
+	}
+
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+// mqttTopicLevel replaces characters not permitted in a topic level.
+func mqttTopicLevel(name string) string {
+	return strings.NewReplacer("/", "_", "+", "_", "#", "_").Replace(name)
+}
+
+// mqttPublisher publishes the values of all controllers via a single broker
+// connection. The availability topic is set to "online" on every connection
+// and to "offline" via the last will.
+type mqttPublisher struct {
+	opts   mqttOpts
+	client mqtt.Client
+
+	mu        sync.Mutex
+	announced map[string]bool
+}
+
+func newMQTTPublisher(opts mqttOpts) *mqttPublisher {
+	if opts.topicPrefix == "" {
+		opts.topicPrefix = "luxws"
+	}
+
+	if opts.discoveryPrefix == "" {
+		opts.discoveryPrefix = "homeassistant"
+	}
+
+	if opts.timeout <= 0 {
+		opts.timeout = 10 * time.Second
+	}
+
+	p := &mqttPublisher{
+		opts:      opts,
+		announced: map[string]bool{},
+	}
+
+	clientOpts := mqtt.NewClientOptions().
+		AddBroker(opts.broker).
+		SetClientID(opts.clientID).
+		SetUsername(opts.username).
+		SetPassword(opts.password).
+		SetConnectTimeout(opts.timeout).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetWill(p.availabilityTopic(), mqttPayloadOffline, opts.qos, true).
+		SetOnConnectHandler(func(client mqtt.Client) {
+			// The will message is published by the broker when the
+			// connection is lost.
+			client.Publish(p.availabilityTopic(), opts.qos, true, mqttPayloadOnline)
+		}).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			log.Printf("MQTT connection lost: %v", err)
+		})
+
+	p.client = mqtt.NewClient(clientOpts)
+
+	return p
+}
+
+func (p *mqttPublisher) availabilityTopic() string {
+	return p.opts.topicPrefix + "/status"
+}
+
+func (p *mqttPublisher) valueTopic(controller, key string) string {
+	return strings.Join([]string{
+		p.opts.topicPrefix, mqttTopicLevel(controller), strings.ReplaceAll(key, ".", "/"),
+	}, "/")
+}
+
+// Connect starts connecting to the broker. Connection attempts are retried
+// in the background.
+func (p *mqttPublisher) Connect() {
+	p.client.Connect()
+}
+
+// Close publishes the offline state and disconnects.
+func (p *mqttPublisher) Close() {
+	if p.client.IsConnectionOpen() {
+		p.client.Publish(p.availabilityTopic(), p.opts.qos, true, mqttPayloadOffline).WaitTimeout(p.opts.timeout)
+	}
+
+	p.client.Disconnect(uint(p.opts.timeout / time.Millisecond))
+}
+
+// mqttPending is a message waiting for acknowledgement by the broker.
+type mqttPending struct {
+	topic string
+	token mqtt.Token
+
+	// Called once the message was acknowledged.
+	delivered func()
+}
+
+func (p *mqttPublisher) publishMessage(topic string, retain bool, payload []byte) mqttPending {
+	return mqttPending{
+		topic: topic,
+		token: p.client.Publish(topic, p.opts.qos, retain, payload),
+	}
+}
+
+// waitMQTT waits for the broker to acknowledge all messages or the context
+// to be done.
+func waitMQTT(ctx context.Context, pending []mqttPending) error {
+	var err error
+
+	for _, m := range pending {
+		select {
+		case <-ctx.Done():
+			return multierr.Append(err, fmt.Errorf("publishing to %q: %w", m.topic, ctx.Err()))
+
+		case <-m.token.Done():
+		}
+
+		if tokenErr := m.token.Error(); tokenErr != nil {
+			multierr.AppendInto(&err, fmt.Errorf("publishing to %q failed: %w", m.topic, tokenErr))
+		} else if m.delivered != nil {
+			m.delivered()
+		}
+	}
+
+	return err
+}
+
+// mqttPayload formats the state of a value.
+func mqttPayload(v apiValue) string {
+	switch {
+	case v.Value != nil:
+		return strconv.FormatFloat(*v.Value, 'f', -1, 64)
+
+	case v.Timestamp != nil:
+		return v.Timestamp.Format(time.RFC3339)
+	}
+
+	return v.Text
+}
+
+// Publish sends all values of a controller and waits for their
+// acknowledgement until the context is done. Discovery configuration is sent
+// once for every value not seen before. Nothing is sent while the broker
+// is unavailable.
+func (p *mqttPublisher) Publish(ctx context.Context, controller string, values []apiValue) error {
+	if !p.client.IsConnectionOpen() {
+		return errors.New("not connected to broker")
+	}
+
+	var err error
+	var pending []mqttPending
+
+	for _, v := range values {
+		stateTopic := p.valueTopic(controller, v.Key)
+
+		if p.opts.discovery {
+			if m, announceErr := p.announce(controller, stateTopic, v); announceErr != nil {
+				multierr.AppendInto(&err, announceErr)
+			} else if m != nil {
+				pending = append(pending, *m)
+			}
+		}
+
+		pending = append(pending, p.publishMessage(stateTopic, p.opts.retain, []byte(mqttPayload(v))))
+	}
+
+	return multierr.Append(err, waitMQTT(ctx, pending))
+}
+
+// Consume publishes the values of a snapshot.
+func (p *mqttPublisher) Consume(ctx context.Context, snap *snapshot) error {
+	ctx, cancel := context.WithTimeout(ctx, p.opts.timeout)
+	defer cancel()
+
+	return p.Publish(ctx, snap.controller, snap.values)
+}
+
+// haDevice identifies the controller in Home Assistant.
+type haDevice struct {
+	Identifiers []string `json:"identifiers"`
+	Name        string   `json:"name"`
+}
+
+// haConfig is the Home Assistant MQTT discovery configuration of a single
+// entity.
+type haConfig struct {
+	Name              string   `json:"name"`
+	UniqueID          string   `json:"unique_id"`
+	ObjectID          string   `json:"object_id"`
+	StateTopic        string   `json:"state_topic"`
+	AvailabilityTopic string   `json:"availability_topic"`
+	DeviceClass       string   `json:"device_class,omitempty"`
+	StateClass        string   `json:"state_class,omitempty"`
+	Unit              string   `json:"unit_of_measurement,omitempty"`
+	PayloadOn         string   `json:"payload_on,omitempty"`
+	PayloadOff        string   `json:"payload_off,omitempty"`
+	Device            haDevice `json:"device"`
+}
+
+// haUnit describes how values with a parsed unit are represented in Home
+// Assistant.
+type haUnit struct {
+	deviceClass string
+	unit        string
+	stateClass  string
+}
+
+var haUnits = map[string]haUnit{
+	"degC": {"temperature", "°C", "measurement"},
+	"K":    {"temperature", "K", "measurement"},
+	"bar":  {"pressure", "bar", "measurement"},
+	"l/h":  {"volume_flow_rate", "L/h", "measurement"},
+	"m³/h": {"volume_flow_rate", "m³/h", "measurement"},
+	"kWh":  {"energy", "kWh", "total_increasing"},
+	"kW":   {"power", "kW", "measurement"},
+	"V":    {"voltage", "V", "measurement"},
+	"mA":   {"current", "mA", "measurement"},
+	"Hz":   {"frequency", "Hz", "measurement"},
+	"s":    {"duration", "s", "measurement"},
+	"rpm":  {"", "rpm", "measurement"},
+	"pct":  {"", "%", "measurement"},
+}
+
+// haDiscovery returns the component type and configuration for a value.
+func haDiscovery(controller, stateTopic, availabilityTopic string, v apiValue) (string, haConfig) {
+	nodeID := "luxws_" + canonicalKey(mqttTopicLevel(controller))
+	objectID := nodeID + "_" + strings.ReplaceAll(v.Key, ".", "_")
+
+	component := "sensor"
+	cfg := haConfig{
+		Name:              v.Path,
+		UniqueID:          objectID,
+		ObjectID:          objectID,
+		StateTopic:        stateTopic,
+		AvailabilityTopic: availabilityTopic,
+		Device: haDevice{
+			Identifiers: []string{nodeID},
+			Name:        "Luxtronik " + controller,
+		},
+	}
+
+	switch {
+	case v.Unit == "bool":
+		component = "binary_sensor"
+		cfg.PayloadOn = "1"
+		cfg.PayloadOff = "0"
+
+	case v.Timestamp != nil:
+		cfg.DeviceClass = "timestamp"
+
+	case v.Value != nil:
+		cfg.StateClass = "measurement"
+
+		if u, ok := haUnits[v.Unit]; ok {
+			cfg.DeviceClass = u.deviceClass
+			cfg.Unit = u.unit
+			cfg.StateClass = u.stateClass
+		}
+	}
+
+	return component, cfg
+}
+
+// announce sends the discovery configuration of a value unless it was
+// already acknowledged by the broker. Nil is returned if nothing was sent.
+func (p *mqttPublisher) announce(controller, stateTopic string, v apiValue) (*mqttPending, error) {
+	component, cfg := haDiscovery(controller, stateTopic, p.availabilityTopic(), v)
+
+	topic := strings.Join([]string{p.opts.discoveryPrefix, component, cfg.Device.Identifiers[0], cfg.ObjectID, "config"}, "/")
+
+	p.mu.Lock()
+	announced := p.announced[topic]
+	p.mu.Unlock()
+
+	if announced {
+		return nil, nil
+	}
+
+	payload, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Discovery configuration is always retained so that Home Assistant
+	// finds it after a restart.
+	m := p.publishMessage(topic, true, payload)
+	m.delivered = func() {
+		p.mu.Lock()
+		p.announced[topic] = true
+		p.mu.Unlock()
+	}
+
+	return &m, nil
+}
+
+// validateMQTTOpts checks the configuration before connecting.
+func validateMQTTOpts(opts mqttOpts) error {
+	if !opts.enabled() {
+		return nil
+	}
+
+	if opts.qos > 2 {
+		return fmt.Errorf("invalid MQTT QoS %d", opts.qos)
+	}
+
+	return nil
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+	"github.com/google/go-cmp/cmp"
+)
+
+type testMQTTMessage struct {
+	Topic   string
+	Payload string
+	QoS     byte
+	Retain  bool
+}
+
+// testMQTTBroker is a minimal in-process broker recording connections and
+// published messages.
+type testMQTTBroker struct {
+	t        *testing.T
+	listener net.Listener
+	messages chan testMQTTMessage
+
+	mu       sync.Mutex
+	connects []*packets.ConnectPacket
+
+	// Published messages are not acknowledged while set.
+	withholdAcks bool
+}
+
+func (b *testMQTTBroker) setWithholdAcks(withhold bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.withholdAcks = withhold
+}
+
+func newTestMQTTBroker(t *testing.T) *testMQTTBroker {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &testMQTTBroker{
+		t:        t,
+		listener: listener,
+		messages: make(chan testMQTTMessage, 100),
+	}
+
+	t.Cleanup(func() {
+		listener.Close()
+	})
+
+	go b.serve()
+
+	return b
+}
+
+func (b *testMQTTBroker) url() string {
+	return "tcp://" + b.listener.Addr().String()
+}
+
+func (b *testMQTTBroker) serve() {
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go b.handle(conn)
+	}
+}
+
+func (b *testMQTTBroker) handle(conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		cp, err := packets.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+
+		var response packets.ControlPacket
+
+		switch p := cp.(type) {
+		case *packets.ConnectPacket:
+			b.mu.Lock()
+			b.connects = append(b.connects, p)
+			b.mu.Unlock()
+
+			ack := packets.NewControlPacket(packets.Connack).(*packets.ConnackPacket)
+			ack.ReturnCode = packets.Accepted
+			response = ack
+
+		case *packets.PublishPacket:
+			b.messages <- testMQTTMessage{
+				Topic:   p.TopicName,
+				Payload: string(p.Payload),
+				QoS:     p.Qos,
+				Retain:  p.Retain,
+			}
+
+			b.mu.Lock()
+			withhold := b.withholdAcks
+			b.mu.Unlock()
+
+			if withhold {
+				continue
+			}
+
+			switch p.Qos {
+			case 1:
+				ack := packets.NewControlPacket(packets.Puback).(*packets.PubackPacket)
+				ack.MessageID = p.MessageID
+				response = ack
+			case 2:
+				rec := packets.NewControlPacket(packets.Pubrec).(*packets.PubrecPacket)
+				rec.MessageID = p.MessageID
+				response = rec
+			}
+
+		case *packets.PubrelPacket:
+			comp := packets.NewControlPacket(packets.Pubcomp).(*packets.PubcompPacket)
+			comp.MessageID = p.MessageID
+			response = comp
+
+		case *packets.PingreqPacket:
+			response = packets.NewControlPacket(packets.Pingresp)
+
+		case *packets.DisconnectPacket:
+			return
+		}
+
+		if response != nil {
+			if err := response.Write(conn); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// expect waits for the given number of messages.
+func (b *testMQTTBroker) expect(count int) []testMQTTMessage {
+	b.t.Helper()
+
+	var result []testMQTTMessage
+
+	timeout := time.After(10 * time.Second)
+
+	for len(result) < count {
+		select {
+		case m := <-b.messages:
+			result = append(result, m)
+		case <-timeout:
+			b.t.Fatalf("Received %d of %d messages: %+v", len(result), count, result)
+		}
+	}
+
+	return result
+}
+
+func TestMQTTPublisher(t *testing.T) {
+	discardAllLogs(t)
+
+	ctx := context.Background()
+	broker := newTestMQTTBroker(t)
+
+	p := newMQTTPublisher(mqttOpts{
+		broker:    broker.url(),
+		clientID:  "test",
+		qos:       1,
+		retain:    true,
+		discovery: true,
+		timeout:   10 * time.Second,
+	})
+	p.Connect()
+
+	if diff := cmp.Diff([]testMQTTMessage{
+		{Topic: "luxws/status", Payload: "online", QoS: 1, Retain: true},
+	}, broker.expect(1)); diff != "" {
+		t.Errorf("Messages difference (-want +got):\n%s", diff)
+	}
+
+	broker.mu.Lock()
+	connect := broker.connects[0]
+	broker.mu.Unlock()
+
+	if !(connect.WillFlag && connect.WillTopic == "luxws/status" && string(connect.WillMessage) == "offline" && connect.WillRetain) {
+		t.Errorf("Unexpected last will: %v", connect)
+	}
+
+	ptr := func(v float64) *float64 {
+		return &v
+	}
+
+	values := []apiValue{
+		{Key: "temperatures.flow", Path: "temperatures/Flow", Name: "Flow", Text: "25.0°C", Value: ptr(25), Unit: "degC"},
+		{Key: "outputs.pump", Path: "outputs/Pump", Name: "Pump", Text: "on", Value: ptr(1), Unit: "bool"},
+		{Key: "status.mode", Path: "status/Mode", Name: "Mode", Text: "heating"},
+	}
+
+	if err := p.Publish(ctx, "a/b", values); err != nil {
+		t.Errorf("Publish() failed: %v", err)
+	}
+
+	got := broker.expect(6)
+
+	for _, idx := range []int{0, 2, 4} {
+		var cfg haConfig
+
+		if err := json.Unmarshal([]byte(got[idx].Payload), &cfg); err != nil {
+			t.Errorf("Decoding discovery configuration failed: %v", err)
+		}
+
+		got[idx].Payload = cfg.DeviceClass + "|" + cfg.Unit + "|" + cfg.StateClass + "|" + cfg.StateTopic
+	}
+
+	if diff := cmp.Diff([]testMQTTMessage{
+		{Topic: "homeassistant/sensor/luxws_a_b/luxws_a_b_temperatures_flow/config", Payload: "temperature|°C|measurement|luxws/a_b/temperatures/flow", QoS: 1, Retain: true},
+		{Topic: "luxws/a_b/temperatures/flow", Payload: "25", QoS: 1, Retain: true},
+		{Topic: "homeassistant/binary_sensor/luxws_a_b/luxws_a_b_outputs_pump/config", Payload: "|||luxws/a_b/outputs/pump", QoS: 1, Retain: true},
+		{Topic: "luxws/a_b/outputs/pump", Payload: "1", QoS: 1, Retain: true},
+		{Topic: "homeassistant/sensor/luxws_a_b/luxws_a_b_status_mode/config", Payload: "|||luxws/a_b/status/mode", QoS: 1, Retain: true},
+		{Topic: "luxws/a_b/status/mode", Payload: "heating", QoS: 1, Retain: true},
+	}, got); diff != "" {
+		t.Errorf("Messages difference (-want +got):\n%s", diff)
+	}
+
+	// Discovery configuration is only sent once
+	if err := p.Publish(ctx, "a/b", values[:1]); err != nil {
+		t.Errorf("Publish() failed: %v", err)
+	}
+
+	if diff := cmp.Diff([]testMQTTMessage{
+		{Topic: "luxws/a_b/temperatures/flow", Payload: "25", QoS: 1, Retain: true},
+	}, broker.expect(1)); diff != "" {
+		t.Errorf("Messages difference (-want +got):\n%s", diff)
+	}
+
+	// Waiting for acknowledgements honours the context
+	broker.setWithholdAcks(true)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+
+	if err := p.Publish(timeoutCtx, "a/b", values[1:]); err == nil {
+		t.Errorf("Publish() succeeded without acknowledgement")
+	}
+
+	broker.expect(2)
+	broker.setWithholdAcks(false)
+
+	p.Close()
+
+	if diff := cmp.Diff([]testMQTTMessage{
+		{Topic: "luxws/status", Payload: "offline", QoS: 1, Retain: true},
+	}, broker.expect(1)); diff != "" {
+		t.Errorf("Messages difference (-want +got):\n%s", diff)
+	}
+}
+
+func TestMQTTPublisherNotConnected(t *testing.T) {
+	discardAllLogs(t)
+
+	p := newMQTTPublisher(mqttOpts{
+		broker:  "tcp://127.0.0.1:1",
+		timeout: time.Minute,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := p.Publish(ctx, "test", []apiValue{{Key: "a", Text: "1"}}); err == nil {
+		t.Errorf("Publish() succeeded without connection")
+	}
+
+	if ctx.Err() != nil {
+		t.Errorf("Publish() waited for an unavailable broker")
+	}
+}
+
+func TestMQTTPayload(t *testing.T) {
+	value := 12.5
+	ts := time.Date(2020, time.January, 2, 3, 4, 5, 0, time.UTC)
+
+	for _, tc := range []struct {
+		value apiValue
+		want  string
+	}{
+		{apiValue{Text: "12.5 bar", Value: &value}, "12.5"},
+		{apiValue{Text: "02.01.20 03:04:05", Timestamp: &ts}, "2020-01-02T03:04:05Z"},
+		{apiValue{Text: "text"}, "text"},
+	} {
+		if got := mqttPayload(tc.value); got != tc.want {
+			t.Errorf("mqttPayload(%+v) = %q, want %q", tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestValidateMQTTOpts(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		opts    mqttOpts
+		wantErr bool
+	}{
+		{name: "disabled"},
+		{name: "valid", opts: mqttOpts{broker: "tcp://localhost:1883", qos: 2}},
+		{name: "qos", opts: mqttOpts{broker: "tcp://localhost:1883", qos: 3}, wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := validateMQTTOpts(tc.opts); (err != nil) != tc.wantErr {
+				t.Errorf("validateMQTTOpts() returned %v, want error %t", err, tc.wantErr)
+			}
+		})
+	}
+}
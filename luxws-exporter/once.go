@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+)
+
+const (
+	onceFormatJSON     = "json"
+	onceFormatCSV      = "csv"
+	onceFormatTextfile = "textfile"
+)
+
+func onceFormats() []string {
+	return []string{onceFormatJSON, onceFormatCSV, onceFormatTextfile}
+}
+
+// snapshotCollector exports the metrics of a successful snapshot.
+type snapshotCollector struct {
+	snap *snapshot
+	up   reading
+}
+
+// Describe sends no descriptors, making the collector unchecked.
+func (snapshotCollector) Describe(chan<- *prometheus.Desc) {}
+
+func (s snapshotCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- s.up.metric()
+
+	for _, r := range s.snap.readings {
+		ch <- r.metric()
+	}
+}
+
+func encodeCSV(values []apiValue) ([]byte, error) {
+	var buf bytes.Buffer
+
+	w := csv.NewWriter(&buf)
+
+	if err := w.Write([]string{"key", "path", "name", "text", "value", "unit", "timestamp"}); err != nil {
+		return nil, err
+	}
+
+	for _, v := range values {
+		var value, timestamp string
+
+		if v.Value != nil {
+			value = strconv.FormatFloat(*v.Value, 'g', -1, 64)
+		}
+
+		if v.Timestamp != nil {
+			timestamp = v.Timestamp.Format(time.RFC3339)
+		}
+
+		if err := w.Write([]string{v.Key, v.Path, v.Name, v.Text, value, v.Unit, timestamp}); err != nil {
+			return nil, err
+		}
+	}
+
+	w.Flush()
+
+	return buf.Bytes(), w.Error()
+}
+
+// encodeTextfile produces the Prometheus text format as read by the textfile
+// collector of node_exporter.
+func encodeTextfile(snap *snapshot, up reading) ([]byte, error) {
+	reg := prometheus.NewRegistry()
+
+	if err := reg.Register(snapshotCollector{snap, up}); err != nil {
+		return nil, err
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+
+	for _, mf := range families {
+		if _, err := expfmt.MetricFamilyToText(&buf, mf); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// encodeOnce converts a successful snapshot. The scrape status is only
+// included in the textfile format.
+func encodeOnce(snap *snapshot, up reading, format string) ([]byte, error) {
+	switch format {
+	case onceFormatJSON:
+		content, err := json.MarshalIndent(snap.valuesResponse(), "", "  ")
+		if err != nil {
+			return nil, err
+		}
+
+		return append(content, '\n'), nil
+
+	case onceFormatCSV:
+		return encodeCSV(snap.values)
+
+	case onceFormatTextfile:
+		return encodeTextfile(snap, up)
+	}
+
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+// runOnce collects a single snapshot and writes it in the given format. The
+// collection is limited by the scrape timeout. An existing output file is left
+// unchanged when the collection fails. Output is written to stdout if the path
+// is empty or "-".
+func runOnce(ctx context.Context, c *collector, format, path string) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	snap := c.snapshot(ctx)
+
+	if snap.err != nil {
+		return fmt.Errorf("collection failed: %w", snap.err)
+	}
+
+	content, err := encodeOnce(snap, snap.up, format)
+	if err != nil {
+		return err
+	}
+
+	if path == "" || path == "-" {
+		_, err = os.Stdout.Write(content)
+		return err
+	}
+
+	return writeFileAtomic(path, content, 0o644)
+}
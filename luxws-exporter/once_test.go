@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hansmi/wp2reg-luxws/luxwslang"
+)
+
+func TestEncodeOnce(t *testing.T) {
+	value := 25.5
+	ts := time.Date(2020, time.March, 1, 12, 0, 0, 0, time.UTC)
+	up := newReading(newReadingDesc("luxws_up", "Whether scrape was successful", []string{"status"}, readingGauge), 1, "")
+
+	snap := &snapshot{
+		controller: "default",
+		time:       ts,
+		values: []apiValue{
+			{Key: "temperatures.flow", Path: "temperatures/Flow", Name: "Flow", Text: "25.5°C", Value: &value, Unit: "degC"},
+			{Key: "error_memory.time", Path: "error memory/Time", Name: "Time", Text: "01.03.20 12:00:00", Timestamp: &ts},
+			{Key: "info.type", Path: "info/Type", Name: "Type", Text: "L2A, \"new\""},
+		},
+		readings: []reading{
+			newReading(newReadingDesc("luxws_temperature", "Sensor temperature", []string{"name", "unit"}, readingGauge),
+				value, "Flow", "degC"),
+		},
+	}
+
+	for _, tc := range []struct {
+		format string
+		want   string
+	}{
+		{
+			format: onceFormatCSV,
+			want: "key,path,name,text,value,unit,timestamp\n" +
+				"temperatures.flow,temperatures/Flow,Flow,25.5°C,25.5,degC,\n" +
+				"error_memory.time,error memory/Time,Time,01.03.20 12:00:00,,,2020-03-01T12:00:00Z\n" +
+				"info.type,info/Type,Type,\"L2A, \"\"new\"\"\",,,\n",
+		},
+		{
+			format: onceFormatTextfile,
+			want: "# HELP luxws_temperature Sensor temperature\n" +
+				"# TYPE luxws_temperature gauge\n" +
+				"luxws_temperature{name=\"Flow\",unit=\"degC\"} 25.5\n" +
+				"# HELP luxws_up Whether scrape was successful\n" +
+				"# TYPE luxws_up gauge\n" +
+				"luxws_up{status=\"\"} 1\n",
+		},
+	} {
+		t.Run(tc.format, func(t *testing.T) {
+			got, err := encodeOnce(snap, up, tc.format)
+			if err != nil {
+				t.Fatalf("encodeOnce() failed: %v", err)
+			}
+
+			if diff := cmp.Diff(tc.want, string(got)); diff != "" {
+				t.Errorf("Output difference (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	if _, err := encodeOnce(snap, up, "xml"); err == nil {
+		t.Errorf("encodeOnce() accepted unknown format")
+	}
+}
+
+func TestRunOnceFailure(t *testing.T) {
+	discardAllLogs(t)
+
+	path := filepath.Join(t.TempDir(), "luxws.prom")
+
+	if err := os.WriteFile(path, []byte("previous\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Sections other than temperatures are missing
+	c := newCollector(collectorOpts{
+		terms:   luxwslang.English,
+		loc:     time.UTC,
+		timeout: time.Minute,
+		address: newTestController(t, `<Content id="0x2">`+
+			`<item id="0x10"><name>temperatures</name>`+
+			`<item id="0x11"><name>Flow</name><value>25.0°C</value></item>`+
+			`</item>`+
+			`</Content>`),
+	})
+
+	if err := runOnce(context.Background(), c, onceFormatTextfile, path); err == nil {
+		t.Errorf("runOnce() succeeded despite failed collection")
+	}
+
+	if content, err := os.ReadFile(path); err != nil {
+		t.Error(err)
+	} else if string(content) != "previous\n" {
+		t.Errorf("Output replaced after failure: %q", content)
+	}
+}
+
+func TestRunOnceTimeout(t *testing.T) {
+	discardAllLogs(t)
+
+	// The controller accepts connections but never replies
+	done := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(done) })
+
+	c := newCollector(collectorOpts{
+		terms:   luxwslang.English,
+		loc:     time.UTC,
+		timeout: 100 * time.Millisecond,
+		address: strings.TrimPrefix(server.URL, "http://"),
+	})
+
+	errCh := make(chan error, 1)
+
+	go func() {
+		errCh <- runOnce(context.Background(), c, onceFormatJSON, filepath.Join(t.TempDir(), "luxws.json"))
+	}()
+
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Errorf("runOnce() succeeded without reply")
+		}
+
+	case <-time.After(10 * time.Second):
+		t.Fatalf("runOnce() didn't honour the timeout")
+	}
+}
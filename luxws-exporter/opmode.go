@@ -0,0 +1,25 @@
+package main
+
+import (
+	"github.com/hansmi/wp2reg-luxws/luxwslang"
+)
+
+// OpenMetrics requires the label of a state set to have the same name as the
+// metric family.
+const opModeStateName = "luxws_operational_mode_state"
+
+// collectOperationMode exports the language-independent operation mode as
+// a state set with one series per known mode and as a numeric code.
+func (c *collector) collectOperationMode(ch chan<- reading, mode luxwslang.OperationMode) {
+	for _, cur := range luxwslang.AllOperationModes() {
+		var value float64
+
+		if cur == mode {
+			value = 1
+		}
+
+		ch <- newReading(c.opModeStateDesc, value, cur.String())
+	}
+
+	ch <- newReading(c.opModeCodeDesc, float64(mode))
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hansmi/wp2reg-luxws/luxwslang"
+)
+
+// Types for the JSON encoding of OTLP metrics export requests. Only the
+// fields required for the metric types produced by the exporter are
+// included. 64-bit integers are encoded as strings.
+type otlpRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpAttribute struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpMetric struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Gauge       *otlpGauge     `json:"gauge,omitempty"`
+	Sum         *otlpSum       `json:"sum,omitempty"`
+	Histogram   *otlpHistogram `json:"histogram,omitempty"`
+}
+
+// Cumulative aggregation temporality.
+const otlpTemporalityCumulative = 2
+
+type otlpGauge struct {
+	DataPoints []otlpNumberDataPoint `json:"dataPoints"`
+}
+
+type otlpSum struct {
+	DataPoints             []otlpNumberDataPoint `json:"dataPoints"`
+	AggregationTemporality int                   `json:"aggregationTemporality"`
+	IsMonotonic            bool                  `json:"isMonotonic"`
+}
+
+type otlpHistogram struct {
+	DataPoints             []otlpHistogramDataPoint `json:"dataPoints"`
+	AggregationTemporality int                      `json:"aggregationTemporality"`
+}
+
+type otlpNumberDataPoint struct {
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	AsDouble          float64         `json:"asDouble"`
+}
+
+type otlpHistogramDataPoint struct {
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	Count             string          `json:"count"`
+	Sum               float64         `json:"sum"`
+	BucketCounts      []string        `json:"bucketCounts"`
+	ExplicitBounds    []float64       `json:"explicitBounds"`
+}
+
+// otlpEncoder produces OTLP/HTTP requests using the JSON encoding. Gauges
+// become gauges, counters monotonic cumulative sums and histograms
+// cumulative histograms. The "_total" suffix is removed from the names of
+// sums as recommended for OTLP.
+type otlpEncoder struct {
+	// Resource attributes identifying the exporter.
+	resource []otlpAttribute
+
+	mu     sync.Mutex
+	series map[string]otlpSeries
+}
+
+// otlpSeries tracks a cumulative series. The start time is the time at which
+// the series was first seen or last reset.
+type otlpSeries struct {
+	start string
+	last  float64
+}
+
+func newOTLPEncoder(serviceName string) *otlpEncoder {
+	return &otlpEncoder{
+		resource: []otlpAttribute{
+			{Key: "service.name", Value: otlpAnyValue{serviceName}},
+		},
+		series: map[string]otlpSeries{},
+	}
+}
+
+// startTime returns the start time of a cumulative series. A value lower
+// than the previous one starts a new series.
+func (e *otlpEncoder) startTime(name string, labels []pushLabel, value float64, ts string) string {
+	parts := []string{name}
+
+	for _, l := range labels {
+		parts = append(parts, l.name, l.value)
+	}
+
+	key := strings.Join(parts, "\x00")
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	series, ok := e.series[key]
+	if !ok || value < series.last {
+		series.start = ts
+	}
+
+	series.last = value
+	e.series[key] = series
+
+	return series.start
+}
+
+func (*otlpEncoder) contentType() string {
+	return "application/json"
+}
+
+func otlpAttributes(labels []pushLabel) []otlpAttribute {
+	var result []otlpAttribute
+
+	for _, l := range labels {
+		result = append(result, otlpAttribute{
+			Key:   l.name,
+			Value: otlpAnyValue{l.value},
+		})
+	}
+
+	return result
+}
+
+// convert converts a single reading. The second return value is false for
+// values which can't be represented, e.g. NaN.
+func (e *otlpEncoder) convert(r reading, extraLabels map[string]string, ts string) (otlpMetric, bool) {
+	if !luxwslang.IsFinite(r.value) {
+		return otlpMetric{}, false
+	}
+
+	result := otlpMetric{
+		Name:        r.desc.name,
+		Description: r.desc.help,
+	}
+
+	labels := pushLabels(r, extraLabels)
+	attrs := otlpAttributes(labels)
+
+	if h := r.histogram; h != nil {
+		dp := otlpHistogramDataPoint{
+			Attributes:        attrs,
+			StartTimeUnixNano: e.startTime(r.desc.name, labels, float64(h.count), ts),
+			TimeUnixNano:      ts,
+			Count:             strconv.FormatUint(h.count, 10),
+			Sum:               h.sum,
+			BucketCounts:      []string{},
+			ExplicitBounds:    slices.Clone(h.bounds),
+		}
+
+		// Prometheus buckets are cumulative, OTLP buckets are not
+		var prev uint64
+
+		for _, count := range h.buckets {
+			dp.BucketCounts = append(dp.BucketCounts, strconv.FormatUint(count-prev, 10))
+			prev = count
+		}
+
+		dp.BucketCounts = append(dp.BucketCounts, strconv.FormatUint(h.count-prev, 10))
+
+		result.Histogram = &otlpHistogram{
+			DataPoints:             []otlpHistogramDataPoint{dp},
+			AggregationTemporality: otlpTemporalityCumulative,
+		}
+
+		return result, true
+	}
+
+	points := []otlpNumberDataPoint{{
+		Attributes:   attrs,
+		TimeUnixNano: ts,
+		AsDouble:     r.value,
+	}}
+
+	switch r.desc.kind {
+	case readingCounter:
+		result.Name = strings.TrimSuffix(r.desc.name, "_total")
+		points[0].StartTimeUnixNano = e.startTime(r.desc.name, labels, r.value, ts)
+
+		result.Sum = &otlpSum{
+			DataPoints:             points,
+			AggregationTemporality: otlpTemporalityCumulative,
+			IsMonotonic:            true,
+		}
+
+	default:
+		result.Gauge = &otlpGauge{DataPoints: points}
+	}
+
+	return result, true
+}
+
+// merge appends the data points of another metric of the same family.
+func (m *otlpMetric) merge(other otlpMetric) bool {
+	switch {
+	case m.Name != other.Name:
+		return false
+
+	case m.Gauge != nil && other.Gauge != nil:
+		m.Gauge.DataPoints = append(m.Gauge.DataPoints, other.Gauge.DataPoints...)
+
+	case m.Sum != nil && other.Sum != nil:
+		m.Sum.DataPoints = append(m.Sum.DataPoints, other.Sum.DataPoints...)
+
+	case m.Histogram != nil && other.Histogram != nil:
+		m.Histogram.DataPoints = append(m.Histogram.DataPoints, other.Histogram.DataPoints...)
+
+	default:
+		return false
+	}
+
+	return true
+}
+
+func (e *otlpEncoder) encode(readings []reading, extraLabels map[string]string, ts time.Time, batchSize int) ([][]byte, error) {
+	var result [][]byte
+	var metrics []otlpMetric
+	var count int
+
+	// Position of every family in the current batch
+	families := map[string]int{}
+
+	tsText := strconv.FormatInt(ts.UnixNano(), 10)
+
+	flush := func() error {
+		body, err := json.Marshal(otlpRequest{
+			ResourceMetrics: []otlpResourceMetrics{{
+				Resource: otlpResource{Attributes: e.resource},
+				ScopeMetrics: []otlpScopeMetrics{{
+					Scope:   otlpScope{Name: "github.com/hansmi/wp2reg-luxws/luxws-exporter"},
+					Metrics: metrics,
+				}},
+			}},
+		})
+		if err != nil {
+			return err
+		}
+
+		result = append(result, body)
+		metrics = nil
+		count = 0
+		clear(families)
+
+		return nil
+	}
+
+	for _, r := range readings {
+		converted, ok := e.convert(r, extraLabels, tsText)
+		if !ok {
+			continue
+		}
+
+		if idx, ok := families[converted.Name]; !ok || !metrics[idx].merge(converted) {
+			families[converted.Name] = len(metrics)
+			metrics = append(metrics, converted)
+		}
+
+		count++
+
+		if count >= batchSize {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if count > 0 {
+		if err := flush(); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestOTLPEncoder(t *testing.T) {
+	ts := time.Unix(1577836800, 0)
+
+	got, err := newOTLPEncoder("test").encode(testPushReadings(), map[string]string{"site": "home"}, ts, 3)
+	if err != nil {
+		t.Fatalf("encode() failed: %v", err)
+	}
+
+	var requests []otlpRequest
+
+	for _, batch := range got {
+		var req otlpRequest
+
+		if err := json.Unmarshal(batch, &req); err != nil {
+			t.Fatalf("Decoding %s failed: %v", batch, err)
+		}
+
+		requests = append(requests, req)
+	}
+
+	const tsText = "1577836800000000000"
+
+	site := otlpAttribute{Key: "site", Value: otlpAnyValue{"home"}}
+
+	request := func(metrics ...otlpMetric) otlpRequest {
+		return otlpRequest{ResourceMetrics: []otlpResourceMetrics{{
+			Resource: otlpResource{Attributes: []otlpAttribute{{Key: "service.name", Value: otlpAnyValue{"test"}}}},
+			ScopeMetrics: []otlpScopeMetrics{{
+				Scope:   otlpScope{Name: "github.com/hansmi/wp2reg-luxws/luxws-exporter"},
+				Metrics: metrics,
+			}},
+		}}}
+	}
+
+	want := []otlpRequest{
+		request(
+			otlpMetric{
+				Name:        "luxws_temperature",
+				Description: "Sensor temperature",
+				Gauge: &otlpGauge{
+					DataPoints: []otlpNumberDataPoint{
+						{
+							Attributes: []otlpAttribute{
+								{Key: "name", Value: otlpAnyValue{"Flow, top"}},
+								site,
+								{Key: "unit", Value: otlpAnyValue{"degC"}},
+							},
+							TimeUnixNano: tsText,
+							AsDouble:     25.5,
+						},
+						{
+							Attributes: []otlpAttribute{
+								{Key: "name", Value: otlpAnyValue{"Return"}},
+								site,
+								{Key: "unit", Value: otlpAnyValue{"degC"}},
+							},
+							TimeUnixNano: tsText,
+							AsDouble:     30,
+						},
+					},
+				},
+			},
+			otlpMetric{
+				Name:        "luxws_compressor_starts",
+				Description: "Compressor starts",
+				Sum: &otlpSum{
+					DataPoints: []otlpNumberDataPoint{{
+						Attributes:        []otlpAttribute{site},
+						StartTimeUnixNano: tsText,
+						TimeUnixNano:      tsText,
+						AsDouble:          12,
+					}},
+					AggregationTemporality: otlpTemporalityCumulative,
+					IsMonotonic:            true,
+				},
+			},
+		),
+		request(otlpMetric{
+			Name:        "luxws_defrost_duration_seconds",
+			Description: "Defrost durations",
+			Histogram: &otlpHistogram{
+				DataPoints: []otlpHistogramDataPoint{{
+					Attributes:        []otlpAttribute{site},
+					StartTimeUnixNano: tsText,
+					TimeUnixNano:      tsText,
+					Count:             "3",
+					Sum:               250,
+					BucketCounts:      []string{"1", "1", "1"},
+					ExplicitBounds:    []float64{60, 120},
+				}},
+				AggregationTemporality: otlpTemporalityCumulative,
+			},
+		}),
+	}
+
+	if diff := cmp.Diff(want, requests); diff != "" {
+		t.Errorf("encode() difference (-want +got):\n%s", diff)
+	}
+}
+
+func TestOTLPEncoderStartTime(t *testing.T) {
+	enc := newOTLPEncoder("test")
+	desc := newReadingDesc("luxws_compressor_starts_total", "Compressor starts", []string{"name"}, readingCounter)
+
+	for _, tc := range []struct {
+		ts        int64
+		value     float64
+		name      string
+		wantStart string
+	}{
+		{ts: 1000, value: 10, name: "VD1", wantStart: "1000000000000"},
+		{ts: 2000, value: 12, name: "VD1", wantStart: "1000000000000"},
+		{ts: 2000, value: 3, name: "VD2", wantStart: "2000000000000"},
+		{ts: 3000, value: 12, name: "VD1", wantStart: "1000000000000"},
+		{ts: 4000, value: 2, name: "VD1", wantStart: "4000000000000"},
+		{ts: 5000, value: 5, name: "VD1", wantStart: "4000000000000"},
+	} {
+		got, err := enc.encode([]reading{newReading(desc, tc.value, tc.name)}, nil, time.Unix(tc.ts, 0), 10)
+		if err != nil {
+			t.Fatalf("encode() failed: %v", err)
+		}
+
+		var req otlpRequest
+
+		if err := json.Unmarshal(got[0], &req); err != nil {
+			t.Fatalf("Decoding %s failed: %v", got[0], err)
+		}
+
+		point := req.ResourceMetrics[0].ScopeMetrics[0].Metrics[0].Sum.DataPoints[0]
+
+		if point.StartTimeUnixNano != tc.wantStart {
+			t.Errorf("Start time of %s at %d is %q, want %q", tc.name, tc.ts, point.StartTimeUnixNano, tc.wantStart)
+		}
+	}
+}
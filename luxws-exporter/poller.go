@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type pollOpts struct {
+	// Interval between polls. Polling is disabled if zero.
+	interval time.Duration
+
+	// Maximum age of the most recent snapshot before it's considered
+	// stale. Defaults to three times the interval.
+	maxAge time.Duration
+
+	// Directory for state persisted across restarts. State is only kept in
+	// memory if empty.
+	stateDir string
+}
+
+func (o pollOpts) enabled() bool {
+	return o.interval > 0
+}
+
+// defrostOpts returns the defrost detection options for a controller with
+// the given name.
+func (o pollOpts) defrostOpts(name string) defrostOpts {
+	result := defrostOpts{
+		enabled: o.enabled(),
+	}
+
+	if result.enabled && o.stateDir != "" {
+		// Names may contain arbitrary characters, including path
+		// separators.
+		result.stateFile = filepath.Join(o.stateDir, "defrost-"+url.PathEscape(name)+".json")
+	}
+
+	return result
+}
+
+// poller collects from a controller in the background and serves the most
+// recent snapshot from memory. Scrapes don't cause any communication with the
+// controller. The poller itself is the Prometheus sink.
+type poller struct {
+	c        *collector
+	opts     pollOpts
+	snapshot func(context.Context) *snapshot
+	now      func() time.Time
+
+	lastSuccessDesc *prometheus.Desc
+
+	mu          sync.Mutex
+	readings    []reading
+	lastErr     error
+	lastPoll    time.Time
+	lastSuccess time.Time
+}
+
+func newPoller(c *collector, opts pollOpts) *poller {
+	if opts.maxAge <= 0 {
+		opts.maxAge = 3 * opts.interval
+	}
+
+	return &poller{
+		c:        c,
+		opts:     opts,
+		snapshot: c.snapshot,
+		now:      time.Now,
+		lastSuccessDesc: prometheus.NewDesc("luxws_last_success_timestamp_seconds",
+			"Time of the most recent successful poll in seconds since epoch (1970)", nil, nil),
+	}
+}
+
+// poll performs a single collection and passes the result to all sinks.
+func (p *poller) poll(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, p.c.timeout)
+	defer cancel()
+
+	snap := p.snapshot(ctx)
+
+	p.Consume(ctx, snap)
+	p.c.publish(ctx, snap)
+
+	return snap.err
+}
+
+// Consume stores the readings of a snapshot. Like a scrape without polling,
+// a failed poll yields the readings collected until the failure, if any.
+func (p *poller) Consume(_ context.Context, snap *snapshot) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.readings = snap.readings
+	p.lastErr = snap.err
+	p.lastPoll = p.now()
+
+	if snap.err == nil {
+		p.lastSuccess = p.lastPoll
+	}
+
+	return nil
+}
+
+// Run polls the controller until the context is cancelled.
+func (p *poller) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.opts.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.poll(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Poll failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *poller) Describe(ch chan<- *prometheus.Desc) {
+	p.c.Describe(ch)
+	ch <- p.lastSuccessDesc
+}
+
+func (p *poller) Collect(ch chan<- prometheus.Metric) {
+	p.mu.Lock()
+	readings := p.readings
+	lastErr := p.lastErr
+	lastPoll := p.lastPoll
+	lastSuccess := p.lastSuccess
+	p.mu.Unlock()
+
+	var lastSuccessValue float64
+
+	if !lastSuccess.IsZero() {
+		lastSuccessValue = float64(lastSuccess.UnixNano()) / 1e9
+	}
+
+	ch <- prometheus.MustNewConstMetric(p.lastSuccessDesc, prometheus.GaugeValue, lastSuccessValue)
+
+	if lastPoll.IsZero() {
+		ch <- newReading(p.c.upDesc, 0, statusPending).metric()
+		return
+	}
+
+	if age := p.now().Sub(lastPoll); age > p.opts.maxAge {
+		ch <- newReading(p.c.upDesc, 0, statusStale).metric()
+		return
+	}
+
+	for _, r := range readings {
+		ch <- r.metric()
+	}
+
+	ch <- p.c.upReading(lastErr).metric()
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hansmi/wp2reg-luxws/luxwslang"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPoller(t *testing.T) {
+	c := newCollector(collectorOpts{
+		terms:   luxwslang.German,
+		loc:     time.UTC,
+		timeout: time.Minute,
+	})
+
+	now := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	var gatherErr error
+	var partial bool
+
+	p := newPoller(c, pollOpts{
+		interval: time.Minute,
+	})
+	p.now = func() time.Time {
+		return now
+	}
+	p.snapshot = func(context.Context) *snapshot {
+		if gatherErr != nil && !partial {
+			return &snapshot{err: gatherErr}
+		}
+
+		return &snapshot{
+			readings: []reading{
+				newReading(c.temperatureDesc, 21, "inside", "degC"),
+			},
+			err: gatherErr,
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+
+	for _, step := range []struct {
+		name    string
+		advance time.Duration
+		err     error
+		partial bool
+		poll    bool
+		want    string
+	}{
+		{
+			name: "before first poll",
+			want: `
+# HELP luxws_last_success_timestamp_seconds Time of the most recent successful poll in seconds since epoch (1970)
+# TYPE luxws_last_success_timestamp_seconds gauge
+luxws_last_success_timestamp_seconds 0
+# HELP luxws_up Whether scrape was successful
+# TYPE luxws_up gauge
+luxws_up{status="pending"} 0
+`,
+		},
+		{
+			name: "success",
+			poll: true,
+			want: `
+# HELP luxws_last_success_timestamp_seconds Time of the most recent successful poll in seconds since epoch (1970)
+# TYPE luxws_last_success_timestamp_seconds gauge
+luxws_last_success_timestamp_seconds 1577836800
+# HELP luxws_temperature Sensor temperature
+# TYPE luxws_temperature gauge
+luxws_temperature{name="inside",unit="degC"} 21
+# HELP luxws_up Whether scrape was successful
+# TYPE luxws_up gauge
+luxws_up{status=""} 1
+`,
+		},
+		{
+			name:    "partial",
+			advance: time.Minute,
+			err:     withStatus(statusParse, errors.New("section failed")),
+			partial: true,
+			poll:    true,
+			want: `
+# HELP luxws_last_success_timestamp_seconds Time of the most recent successful poll in seconds since epoch (1970)
+# TYPE luxws_last_success_timestamp_seconds gauge
+luxws_last_success_timestamp_seconds 1577836800
+# HELP luxws_temperature Sensor temperature
+# TYPE luxws_temperature gauge
+luxws_temperature{name="inside",unit="degC"} 21
+# HELP luxws_up Whether scrape was successful
+# TYPE luxws_up gauge
+luxws_up{status="parse"} 0
+`,
+		},
+		{
+			name:    "failure",
+			advance: time.Minute,
+			err:     withStatus(statusDial, errors.New("unreachable")),
+			poll:    true,
+			want: `
+# HELP luxws_last_success_timestamp_seconds Time of the most recent successful poll in seconds since epoch (1970)
+# TYPE luxws_last_success_timestamp_seconds gauge
+luxws_last_success_timestamp_seconds 1577836800
+# HELP luxws_up Whether scrape was successful
+# TYPE luxws_up gauge
+luxws_up{status="dial"} 0
+`,
+		},
+		{
+			name:    "stale",
+			advance: 210 * time.Second,
+			want: `
+# HELP luxws_last_success_timestamp_seconds Time of the most recent successful poll in seconds since epoch (1970)
+# TYPE luxws_last_success_timestamp_seconds gauge
+luxws_last_success_timestamp_seconds 1577836800
+# HELP luxws_up Whether scrape was successful
+# TYPE luxws_up gauge
+luxws_up{status="stale"} 0
+`,
+		},
+		{
+			name: "recovered",
+			poll: true,
+			want: `
+# HELP luxws_last_success_timestamp_seconds Time of the most recent successful poll in seconds since epoch (1970)
+# TYPE luxws_last_success_timestamp_seconds gauge
+luxws_last_success_timestamp_seconds 1577837130
+# HELP luxws_temperature Sensor temperature
+# TYPE luxws_temperature gauge
+luxws_temperature{name="inside",unit="degC"} 21
+# HELP luxws_up Whether scrape was successful
+# TYPE luxws_up gauge
+luxws_up{status=""} 1
+`,
+		},
+	} {
+		now = now.Add(step.advance)
+		gatherErr = step.err
+		partial = step.partial
+
+		if step.poll {
+			if err := p.poll(ctx); err != step.err {
+				t.Errorf("%s: poll() returned %v, want %v", step.name, err, step.err)
+			}
+		}
+
+		if err := testutil.CollectAndCompare(p, strings.NewReader(step.want)); err != nil {
+			t.Errorf("%s: %v", step.name, err)
+		}
+	}
+}
+
+func TestPollOptsDefrostStateFile(t *testing.T) {
+	dir := t.TempDir()
+	opts := pollOpts{
+		interval: time.Minute,
+		stateDir: dir,
+	}
+
+	for _, name := range []string{"default", "../x", "a/../../b", `c:\d`, ".."} {
+		got := opts.defrostOpts(name).stateFile
+
+		if filepath.Dir(got) != dir {
+			t.Errorf("State file %q for controller %q is outside of %q", got, name, dir)
+		}
+	}
+
+	if got := (pollOpts{stateDir: dir}).defrostOpts("default"); got.enabled || got.stateFile != "" {
+		t.Errorf("Defrost detection enabled without polling: %+v", got)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hansmi/wp2reg-luxws/luxwslang"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// probeHandler implements a multi-target endpoint in the style of the
+// blackbox_exporter. Every request constructs a new collector for the
+// requested target.
+type probeHandler struct {
+	// Template for collector options. Addresses, language and timezone are
+	// taken from the request.
+	opts collectorOpts
+
+	// Whether every probe reports metrics about the communication with the
+	// controller.
+	transportMetrics bool
+
+	// Permitted targets.
+	allowedTargets map[string]struct{}
+}
+
+func newProbeHandler(opts collectorOpts, allowedTargets []string) (*probeHandler, error) {
+	if len(allowedTargets) == 0 {
+		return nil, errors.New("probing requires at least one allowed target")
+	}
+
+	h := &probeHandler{
+		opts:             opts,
+		transportMetrics: opts.transportMetrics != nil,
+		allowedTargets:   map[string]struct{}{},
+	}
+
+	// Probe targets are arbitrary. State shared across probes, e.g. metrics
+	// labelled with the target, would grow without bounds.
+	h.opts.transportMetrics = nil
+	h.opts.coalescer = nil
+	h.opts.sinks = nil
+	h.opts.address = ""
+	h.opts.httpAddress = ""
+
+	for _, i := range allowedTargets {
+		h.allowedTargets[i] = struct{}{}
+	}
+
+	return h, nil
+}
+
+func (h *probeHandler) isAllowed(target string) bool {
+	_, ok := h.allowedTargets[target]
+
+	return ok
+}
+
+func (h *probeHandler) buildOpts(r *http.Request) (collectorOpts, int, error) {
+	query := r.URL.Query()
+	opts := h.opts
+
+	if opts.address = query.Get("target"); opts.address == "" {
+		return opts, http.StatusBadRequest, fmt.Errorf("target parameter is missing")
+	}
+
+	if !h.isAllowed(opts.address) {
+		return opts, http.StatusForbidden, fmt.Errorf("target %q is not allowed", opts.address)
+	}
+
+	if httpTarget := query.Get("http_target"); httpTarget != "" {
+		if !h.isAllowed(httpTarget) {
+			return opts, http.StatusForbidden, fmt.Errorf("HTTP target %q is not allowed", httpTarget)
+		}
+
+		opts.httpAddress = httpTarget
+	}
+
+	if lang := query.Get("language"); lang != "" {
+		terms, err := luxwslang.LookupByID(lang)
+		if err != nil {
+			return opts, http.StatusBadRequest, err
+		}
+
+		opts.terms = terms
+	} else if opts.terms == nil {
+		return opts, http.StatusBadRequest, fmt.Errorf("language parameter is missing")
+	}
+
+	if tz := query.Get("timezone"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return opts, http.StatusBadRequest, fmt.Errorf("loading timezone %q failed: %w", tz, err)
+		}
+
+		opts.loc = loc
+	}
+
+	return opts, http.StatusOK, nil
+}
+
+func (h *probeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	opts, code, err := h.buildOpts(r)
+	if err != nil {
+		http.Error(w, err.Error(), code)
+		return
+	}
+
+	reg := prometheus.NewPedanticRegistry()
+	gatherers := prometheus.Gatherers{reg}
+
+	if h.transportMetrics {
+		// Transport metrics only live as long as the probe. They're gathered
+		// after the collection is complete.
+		opts.transportMetrics = newTransportMetrics()
+
+		transportReg := prometheus.NewPedanticRegistry()
+		transportReg.MustRegister(opts.transportMetrics.Metrics()...)
+
+		gatherers = append(gatherers, transportReg)
+	}
+
+	reg.MustRegister(newCollector(opts))
+
+	promhttp.HandlerFor(gatherers, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
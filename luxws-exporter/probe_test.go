@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hansmi/wp2reg-luxws/luxwslang"
+	"golang.org/x/sync/semaphore"
+)
+
+func TestProbeHandler(t *testing.T) {
+	controller := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "", http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(controller.Close)
+
+	controllerURL, err := url.Parse(controller.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	discardAllLogs(t)
+
+	for _, tc := range []struct {
+		name     string
+		opts     collectorOpts
+		allowed  []string
+		query    url.Values
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "missing target",
+			allowed:  []string{controllerURL.Host},
+			query:    url.Values{},
+			wantCode: http.StatusBadRequest,
+			wantBody: "target parameter is missing",
+		},
+		{
+			name:    "missing language",
+			allowed: []string{controllerURL.Host},
+			query: url.Values{
+				"target": {controllerURL.Host},
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: "language parameter is missing",
+		},
+		{
+			name:    "unknown language",
+			allowed: []string{controllerURL.Host},
+			query: url.Values{
+				"target":   {controllerURL.Host},
+				"language": {"xx"},
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: `language "xx" not found`,
+		},
+		{
+			name:    "bad timezone",
+			allowed: []string{controllerURL.Host},
+			query: url.Values{
+				"target":   {controllerURL.Host},
+				"language": {"en"},
+				"timezone": {"Nowhere/Nothing"},
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: `loading timezone "Nowhere/Nothing" failed`,
+		},
+		{
+			name:    "target not allowed",
+			allowed: []string{"192.0.2.1:8214"},
+			query: url.Values{
+				"target":   {controllerURL.Host},
+				"language": {"en"},
+			},
+			wantCode: http.StatusForbidden,
+			wantBody: "is not allowed",
+		},
+		{
+			name:    "HTTP target not allowed",
+			allowed: []string{controllerURL.Host},
+			query: url.Values{
+				"target":      {controllerURL.Host},
+				"http_target": {"192.0.2.1:80"},
+				"language":    {"en"},
+			},
+			wantCode: http.StatusForbidden,
+			wantBody: `HTTP target "192.0.2.1:80" is not allowed`,
+		},
+		{
+			name:    "failed scrape",
+			allowed: []string{controllerURL.Host},
+			query: url.Values{
+				"target":   {controllerURL.Host},
+				"language": {"en"},
+				"timezone": {"UTC"},
+			},
+			wantCode: http.StatusOK,
+			wantBody: `luxws_up{status="dial"} 0`,
+		},
+		{
+			name:    "transport metrics",
+			allowed: []string{controllerURL.Host},
+			opts: collectorOpts{
+				transportMetrics: newTransportMetrics(),
+			},
+			query: url.Values{
+				"target":   {controllerURL.Host},
+				"language": {"en"},
+			},
+			wantCode: http.StatusOK,
+			wantBody: `luxws_dial_duration_seconds_count{target="` + controllerURL.Host + `"} 1`,
+		},
+		{
+			name:    "default language",
+			allowed: []string{controllerURL.Host},
+			opts: collectorOpts{
+				terms: luxwslang.German,
+			},
+			query: url.Values{
+				"target": {controllerURL.Host},
+			},
+			wantCode: http.StatusOK,
+			wantBody: `luxws_up{status="dial"} 0`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.opts.timeout = time.Minute
+			tc.opts.loc = time.UTC
+
+			h, err := newProbeHandler(tc.opts, tc.allowed)
+			if err != nil {
+				t.Fatalf("newProbeHandler() failed: %v", err)
+			}
+
+			server := httptest.NewServer(h)
+			t.Cleanup(server.Close)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			t.Cleanup(cancel)
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/probe?"+tc.query.Encode(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := server.Client().Do(req)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.StatusCode != tc.wantCode {
+				t.Errorf("Got status %d, want %d", resp.StatusCode, tc.wantCode)
+			}
+
+			if !strings.Contains(string(body), tc.wantBody) {
+				t.Errorf("Response body %q doesn't contain %q", body, tc.wantBody)
+			}
+		})
+	}
+}
+
+func TestProbeHandlerWithoutAllowedTargets(t *testing.T) {
+	if _, err := newProbeHandler(collectorOpts{}, nil); err == nil {
+		t.Errorf("newProbeHandler() succeeded without allowed targets")
+	}
+}
+
+func TestProbeHandlerSharedSemaphore(t *testing.T) {
+	sem := semaphore.NewWeighted(1)
+	h, err := newProbeHandler(collectorOpts{
+		sem:   sem,
+		terms: luxwslang.English,
+	}, []string{"192.0.2.1:8214"})
+	if err != nil {
+		t.Fatalf("newProbeHandler() failed: %v", err)
+	}
+
+	opts, _, err := h.buildOpts(httptest.NewRequest(http.MethodGet, "/probe?target=192.0.2.1:8214", nil))
+	if err != nil {
+		t.Fatalf("buildOpts() failed: %v", err)
+	}
+
+	if c := newCollector(opts); c.sem != sem {
+		t.Errorf("Collector doesn't use shared semaphore")
+	}
+}
+
+func TestProbeHandlerIsolation(t *testing.T) {
+	h, err := newProbeHandler(collectorOpts{
+		terms:            luxwslang.English,
+		address:          "192.0.2.1:8214",
+		httpAddress:      "192.0.2.1:80",
+		coalescer:        newCoalescer(),
+		transportMetrics: newTransportMetrics(),
+	}, []string{"192.0.2.2:8214", "192.0.2.2:80"})
+	if err != nil {
+		t.Fatalf("newProbeHandler() failed: %v", err)
+	}
+
+	for _, tc := range []struct {
+		query           string
+		wantHTTPAddress string
+	}{
+		{query: "target=192.0.2.2:8214"},
+		{query: "target=192.0.2.2:8214&http_target=192.0.2.2:80", wantHTTPAddress: "192.0.2.2:80"},
+	} {
+		opts, _, err := h.buildOpts(httptest.NewRequest(http.MethodGet, "/probe?"+tc.query, nil))
+		if err != nil {
+			t.Fatalf("buildOpts() failed: %v", err)
+		}
+
+		if opts.httpAddress != tc.wantHTTPAddress {
+			t.Errorf("Probe with %q uses HTTP address %q, want %q", tc.query, opts.httpAddress, tc.wantHTTPAddress)
+		}
+
+		if opts.coalescer != nil || opts.transportMetrics != nil {
+			t.Errorf("Probe with %q shares state with other collectors", tc.query)
+		}
+	}
+}
@@ -0,0 +1,615 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// pushEncoder converts readings into request bodies of at most batchSize
+// samples each. The extra labels are added to every reading.
+type pushEncoder interface {
+	contentType() string
+	encode(readings []reading, extraLabels map[string]string, ts time.Time, batchSize int) ([][]byte, error)
+}
+
+type pushLabel struct {
+	name, value string
+}
+
+// pushLabels returns the labels of a reading combined with the extra labels,
+// sorted by name. Like in Prometheus empty values are omitted.
+func pushLabels(r reading, extraLabels map[string]string) []pushLabel {
+	result := make([]pushLabel, 0, len(r.labelValues)+len(extraLabels))
+
+	for idx, value := range r.labelValues {
+		if value != "" {
+			result = append(result, pushLabel{r.desc.labelNames[idx], value})
+		}
+	}
+
+	for name, value := range extraLabels {
+		if value != "" {
+			result = append(result, pushLabel{name, value})
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].name < result[j].name
+	})
+
+	return result
+}
+
+// pushQueueSize is the number of encoded snapshots waiting for delivery
+// before further snapshots go directly to the buffer.
+const pushQueueSize = 16
+
+// pushOpts configures the sending of snapshots to a push output.
+type pushOpts struct {
+	url    string
+	header http.Header
+
+	// Maximum number of samples per request.
+	batchSize int
+
+	// Number of attempts after the first failure of a request. The delay
+	// between attempts doubles every time, starting at minBackoff.
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	timeout time.Duration
+
+	// Requests which couldn't be delivered are buffered in the given
+	// directory if set. The oldest requests are discarded when the buffer
+	// grows beyond bufferMaxBytes.
+	bufferDir      string
+	bufferMaxBytes int64
+}
+
+// pushSink sends snapshots to a push output via HTTP POST. Snapshots are
+// encoded when consumed and delivered in the background by Run, so slow or
+// unavailable outputs don't delay collections.
+type pushSink struct {
+	name   string
+	opts   pushOpts
+	enc    pushEncoder
+	client *http.Client
+	buffer *pushBuffer
+	queue  chan [][]byte
+	sleep  func(context.Context, time.Duration) error
+
+	sentBatches   prometheus.Counter
+	failedBatches prometheus.Counter
+	droppedBytes  prometheus.Counter
+	bufferedBytes prometheus.GaugeFunc
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func newPushSink(name string, enc pushEncoder, opts pushOpts) (*pushSink, error) {
+	if opts.batchSize < 1 {
+		opts.batchSize = 1000
+	}
+
+	if opts.minBackoff <= 0 {
+		opts.minBackoff = time.Second
+	}
+
+	if opts.maxBackoff < opts.minBackoff {
+		opts.maxBackoff = opts.minBackoff
+	}
+
+	s := &pushSink{
+		name:   name,
+		opts:   opts,
+		enc:    enc,
+		client: &http.Client{Timeout: opts.timeout},
+		queue:  make(chan [][]byte, pushQueueSize),
+		sleep:  sleepContext,
+	}
+
+	if opts.bufferDir != "" {
+		buffer, err := newPushBuffer(filepath.Join(opts.bufferDir, name), opts.bufferMaxBytes)
+		if err != nil {
+			return nil, err
+		}
+
+		s.buffer = buffer
+	}
+
+	constLabels := prometheus.Labels{"output": name}
+
+	s.sentBatches = prometheus.NewCounter(prometheus.CounterOpts{
+		Name:        "luxws_exporter_push_sent_batches_total",
+		Help:        "Number of batches delivered to the push output",
+		ConstLabels: constLabels,
+	})
+	s.failedBatches = prometheus.NewCounter(prometheus.CounterOpts{
+		Name:        "luxws_exporter_push_failed_batches_total",
+		Help:        "Number of batches which couldn't be delivered after all retries",
+		ConstLabels: constLabels,
+	})
+	s.droppedBytes = prometheus.NewCounter(prometheus.CounterOpts{
+		Name:        "luxws_exporter_push_dropped_bytes_total",
+		Help:        "Size of undelivered batches discarded because the buffer was full or disabled",
+		ConstLabels: constLabels,
+	})
+	s.bufferedBytes = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "luxws_exporter_push_buffered_bytes",
+		Help:        "Size of undelivered batches buffered on disk",
+		ConstLabels: constLabels,
+	}, func() float64 {
+		if s.buffer == nil {
+			return 0
+		}
+
+		return float64(s.buffer.size())
+	})
+
+	return s, nil
+}
+
+// Metrics returns the collectors describing the state of the output.
+func (s *pushSink) Metrics() []prometheus.Collector {
+	return []prometheus.Collector{s.sentBatches, s.failedBatches, s.droppedBytes, s.bufferedBytes}
+}
+
+// send delivers a single request body.
+func (s *pushSink) send(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.opts.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	for name, values := range s.opts.header {
+		req.Header[name] = values
+	}
+
+	req.Header.Set("Content-Type", s.enc.contentType())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	return nil
+}
+
+// sendWithRetry delivers a request body, retrying with an exponentially
+// growing delay.
+func (s *pushSink) sendWithRetry(ctx context.Context, body []byte) error {
+	delay := s.opts.minBackoff
+
+	for attempt := 0; ; attempt++ {
+		err := s.send(ctx, body)
+		if err == nil || attempt >= s.opts.maxRetries || ctx.Err() != nil {
+			return err
+		}
+
+		if err := s.sleep(ctx, delay); err != nil {
+			return err
+		}
+
+		delay = min(2*delay, s.opts.maxBackoff)
+	}
+}
+
+// Consume encodes a snapshot, including its scrape status, and queues it for
+// delivery. Points are stamped with the time of the snapshot. If the queue is
+// full the snapshot is buffered right away.
+func (s *pushSink) Consume(_ context.Context, snap *snapshot) error {
+	readings := append(slices.Clip(snap.readings), snap.up)
+
+	batches, err := s.enc.encode(readings, snap.labels, snap.time, s.opts.batchSize)
+	if err != nil {
+		return err
+	}
+
+	if len(batches) == 0 {
+		return nil
+	}
+
+	select {
+	case s.queue <- batches:
+	default:
+		for _, body := range batches {
+			s.store(body)
+		}
+
+		return errors.New("delivery queue is full")
+	}
+
+	return nil
+}
+
+// Run delivers queued snapshots until the context is cancelled. Buffered
+// batches are also retried every maxBackoff so that they're delivered even
+// when no further snapshots arrive.
+func (s *pushSink) Run(ctx context.Context) {
+	var retry <-chan time.Time
+
+	if s.buffer != nil {
+		ticker := time.NewTicker(s.opts.maxBackoff)
+		defer ticker.Stop()
+
+		retry = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case batches := <-s.queue:
+			if err := s.deliver(ctx, batches); err != nil && ctx.Err() == nil {
+				log.Printf("Push failed: %v", err)
+			}
+
+		case <-retry:
+			if s.buffer.size() == 0 {
+				continue
+			}
+
+			if err := s.drainBuffer(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Push failed: %v", err)
+			}
+		}
+	}
+}
+
+// drainBuffer delivers buffered batches from previous failures, oldest
+// first.
+func (s *pushSink) drainBuffer(ctx context.Context) error {
+	if s.buffer == nil {
+		return nil
+	}
+
+	if err := s.buffer.drain(func(body []byte) error {
+		return s.sendWithRetry(ctx, body)
+	}); err != nil {
+		return fmt.Errorf("%s: delivering buffered batch failed: %w", s.name, err)
+	}
+
+	return nil
+}
+
+// deliver sends the batches of a snapshot. Buffered batches from previous
+// failures are delivered first.
+func (s *pushSink) deliver(ctx context.Context, batches [][]byte) error {
+	if err := s.drainBuffer(ctx); err != nil {
+		// The output is still unavailable; keep the order of batches.
+		for _, body := range batches {
+			s.failedBatches.Inc()
+			s.store(body)
+		}
+
+		return err
+	}
+
+	var result error
+
+	for idx, body := range batches {
+		if err := s.sendWithRetry(ctx, body); err != nil {
+			for _, body := range batches[idx:] {
+				s.failedBatches.Inc()
+				s.store(body)
+			}
+
+			result = fmt.Errorf("%s: %w", s.name, err)
+			break
+		}
+
+		s.sentBatches.Inc()
+	}
+
+	return result
+}
+
+// store buffers an undelivered batch.
+func (s *pushSink) store(body []byte) {
+	if s.buffer == nil {
+		s.droppedBytes.Add(float64(len(body)))
+		return
+	}
+
+	dropped, err := s.buffer.store(body)
+	if err != nil {
+		log.Printf("Buffering batch for %s failed: %v", s.name, err)
+		dropped += int64(len(body))
+	}
+
+	s.droppedBytes.Add(float64(dropped))
+}
+
+// pushBuffer is a bounded on-disk queue of request bodies. Every entry is
+// stored in its own file; file names sort in insertion order.
+type pushBuffer struct {
+	dir      string
+	maxBytes int64
+
+	mu    sync.Mutex
+	seq   uint64
+	total int64
+}
+
+const pushBufferSuffix = ".batch"
+
+func newPushBuffer(dir string, maxBytes int64) (*pushBuffer, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	b := &pushBuffer{
+		dir:      dir,
+		maxBytes: maxBytes,
+	}
+
+	entries, err := b.entries()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		b.total += e.size
+	}
+
+	b.seq = uint64(time.Now().UnixNano())
+
+	return b, nil
+}
+
+type pushBufferEntry struct {
+	path string
+	size int64
+}
+
+// entries returns all buffered files, oldest first.
+func (b *pushBuffer) entries() ([]pushBufferEntry, error) {
+	dirEntries, err := os.ReadDir(b.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []pushBufferEntry
+
+	for _, de := range dirEntries {
+		if de.IsDir() || !strings.HasSuffix(de.Name(), pushBufferSuffix) {
+			continue
+		}
+
+		info, err := de.Info()
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		result = append(result, pushBufferEntry{
+			path: filepath.Join(b.dir, de.Name()),
+			size: info.Size(),
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].path < result[j].path
+	})
+
+	return result, nil
+}
+
+func (b *pushBuffer) size() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.total
+}
+
+// store appends a request body and discards the oldest entries if the buffer
+// is full. The number of discarded bytes is returned.
+func (b *pushBuffer) store(body []byte) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.maxBytes > 0 && int64(len(body)) > b.maxBytes {
+		return int64(len(body)), nil
+	}
+
+	b.seq++
+
+	path := filepath.Join(b.dir, fmt.Sprintf("%020d%s", b.seq, pushBufferSuffix))
+
+	tmp, err := os.CreateTemp(b.dir, ".tmp-*")
+	if err != nil {
+		return 0, err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(body); err != nil {
+		tmp.Close()
+		return 0, err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return 0, err
+	}
+
+	b.total += int64(len(body))
+
+	var dropped int64
+
+	if b.maxBytes > 0 && b.total > b.maxBytes {
+		entries, err := b.entries()
+		if err != nil {
+			return 0, err
+		}
+
+		for _, e := range entries {
+			if b.total <= b.maxBytes {
+				break
+			}
+
+			if err := os.Remove(e.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return dropped, err
+			}
+
+			b.total -= e.size
+			dropped += e.size
+		}
+	}
+
+	return dropped, nil
+}
+
+// drain passes all buffered entries to the given function, oldest first.
+// Delivered entries are removed. Draining stops at the first error.
+func (b *pushBuffer) drain(fn func([]byte) error) error {
+	b.mu.Lock()
+	entries, err := b.entries()
+	b.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		body, err := os.ReadFile(e.path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+
+			return err
+		}
+
+		if err := fn(body); err != nil {
+			return err
+		}
+
+		b.mu.Lock()
+		if err := os.Remove(e.path); err == nil {
+			b.total -= e.size
+		}
+		b.mu.Unlock()
+	}
+
+	return nil
+}
+
+// parseHeaders converts "Name: value" or "Name=value" pairs into HTTP
+// headers.
+func parseHeaders(values []string) (http.Header, error) {
+	result := http.Header{}
+
+	for _, v := range values {
+		idx := strings.IndexAny(v, ":=")
+		if idx < 1 {
+			return nil, fmt.Errorf("invalid header %q", v)
+		}
+
+		result.Add(strings.TrimSpace(v[:idx]), strings.TrimSpace(v[idx+1:]))
+	}
+
+	return result, nil
+}
+
+// pushConfig describes all push outputs.
+type pushConfig struct {
+	// InfluxDB write endpoint including query parameters (e.g.
+	// "http://192.0.2.3:8086/api/v2/write?org=home&bucket=luxws").
+	influxURL       string
+	influxTokenFile string
+
+	// OTLP/HTTP metrics endpoint (e.g. "http://192.0.2.3:4318/v1/metrics").
+	otlpURL     string
+	otlpHeaders []string
+
+	opts pushOpts
+}
+
+// newPushSinks constructs the configured push outputs.
+func newPushSinks(cfg pushConfig) ([]*pushSink, error) {
+	var result []*pushSink
+
+	if cfg.influxURL != "" {
+		opts := cfg.opts
+		opts.url = cfg.influxURL
+		opts.header = http.Header{}
+
+		if cfg.influxTokenFile != "" {
+			content, err := os.ReadFile(cfg.influxTokenFile)
+			if err != nil {
+				return nil, fmt.Errorf("reading InfluxDB token file: %w", err)
+			}
+
+			opts.header.Set("Authorization", "Token "+strings.TrimSpace(string(content)))
+		}
+
+		s, err := newPushSink("influxdb", influxEncoder{}, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, s)
+	}
+
+	if cfg.otlpURL != "" {
+		opts := cfg.opts
+		opts.url = cfg.otlpURL
+
+		header, err := parseHeaders(cfg.otlpHeaders)
+		if err != nil {
+			return nil, err
+		}
+
+		opts.header = header
+
+		s, err := newPushSink("otlp", newOTLPEncoder("luxws-exporter"), opts)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, s)
+	}
+
+	return result, nil
+}
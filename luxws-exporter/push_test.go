@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func testBatches(bodies ...string) [][]byte {
+	var result [][]byte
+
+	for _, body := range bodies {
+		result = append(result, []byte(body))
+	}
+
+	return result
+}
+
+// testPushServer records request bodies. Requests fail while unavailable is
+// set.
+type testPushServer struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	unavailable bool
+	attempts    int
+	received    []string
+}
+
+func newTestPushServer(t *testing.T) *testPushServer {
+	s := &testPushServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		s.attempts++
+
+		if s.unavailable {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		if got := r.Header.Get("X-Test"); got != "value" {
+			t.Errorf("Header X-Test is %q", got)
+		}
+
+		s.received = append(s.received, string(body))
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *testPushServer) setUnavailable(unavailable bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.unavailable = unavailable
+}
+
+func (s *testPushServer) result() (int, []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempts, received := s.attempts, s.received
+
+	s.attempts = 0
+	s.received = nil
+
+	return attempts, received
+}
+
+func newTestPushSink(t *testing.T, server *testPushServer, bufferDir string, bufferMaxBytes int64) (*pushSink, *[]time.Duration) {
+	t.Helper()
+
+	s, err := newPushSink("test", influxEncoder{}, pushOpts{
+		url:            server.URL,
+		header:         http.Header{"X-Test": {"value"}},
+		maxRetries:     2,
+		minBackoff:     time.Second,
+		maxBackoff:     90 * time.Second,
+		bufferDir:      bufferDir,
+		bufferMaxBytes: bufferMaxBytes,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var delays []time.Duration
+
+	s.sleep = func(_ context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+
+	return s, &delays
+}
+
+func TestPushSinkRetry(t *testing.T) {
+	ctx := context.Background()
+	server := newTestPushServer(t)
+	s, delays := newTestPushSink(t, server, "", 0)
+
+	if err := s.deliver(ctx, testBatches("a", "b")); err != nil {
+		t.Errorf("deliver() failed: %v", err)
+	}
+
+	if attempts, received := server.result(); attempts != 2 || !cmp.Equal(received, []string{"a", "b"}) {
+		t.Errorf("Got %d attempts, received %q", attempts, received)
+	}
+
+	server.setUnavailable(true)
+
+	if err := s.deliver(ctx, testBatches("c")); err == nil {
+		t.Errorf("deliver() succeeded while output is unavailable")
+	}
+
+	if attempts, _ := server.result(); attempts != 3 {
+		t.Errorf("Got %d attempts, want 3", attempts)
+	}
+
+	if diff := cmp.Diff([]time.Duration{time.Second, 2 * time.Second}, *delays); diff != "" {
+		t.Errorf("Delays difference (-want +got):\n%s", diff)
+	}
+}
+
+func TestPushSinkBuffer(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	server := newTestPushServer(t)
+	s, _ := newTestPushSink(t, server, dir, 100)
+
+	server.setUnavailable(true)
+
+	for _, names := range [][]string{{"first", "second"}, {"third"}} {
+		if err := s.deliver(ctx, testBatches(names...)); err == nil {
+			t.Errorf("deliver() succeeded while output is unavailable")
+		}
+	}
+
+	if got, want := s.buffer.size(), int64(len("firstsecondthird")); got != want {
+		t.Errorf("Buffer size is %d, want %d", got, want)
+	}
+
+	// Buffered batches survive a restart
+	s, _ = newTestPushSink(t, server, dir, 100)
+
+	server.setUnavailable(false)
+
+	if err := s.deliver(ctx, testBatches("fourth")); err != nil {
+		t.Errorf("deliver() failed: %v", err)
+	}
+
+	if _, received := server.result(); !cmp.Equal(received, []string{"first", "second", "third", "fourth"}) {
+		t.Errorf("Received %q", received)
+	}
+
+	if got := s.buffer.size(); got != 0 {
+		t.Errorf("Buffer size is %d after delivery", got)
+	}
+}
+
+func TestPushSinkRetryBuffer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	server := newTestPushServer(t)
+	s, _ := newTestPushSink(t, server, t.TempDir(), 100)
+	s.opts.maxBackoff = 10 * time.Millisecond
+
+	server.setUnavailable(true)
+
+	if err := s.deliver(ctx, testBatches("first", "second")); err == nil {
+		t.Errorf("deliver() succeeded while output is unavailable")
+	}
+
+	server.setUnavailable(false)
+	server.result()
+
+	// Buffered batches are delivered without further snapshots
+	go s.Run(ctx)
+
+	for deadline := time.Now().Add(10 * time.Second); s.buffer.size() > 0; {
+		if time.Now().After(deadline) {
+			t.Fatalf("Buffered batches not delivered")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, received := server.result(); !cmp.Equal(received, []string{"first", "second"}) {
+		t.Errorf("Received %q", received)
+	}
+}
+
+func TestPushSinkConsume(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	server := newTestPushServer(t)
+	s, _ := newTestPushSink(t, server, "", 0)
+
+	desc := newReadingDesc("luxws_temperature", "Sensor temperature", []string{"name", "unit"}, readingGauge)
+	upDesc := newReadingDesc("luxws_up", "Whether scrape was successful", []string{"status"}, readingGauge)
+
+	snap := &snapshot{
+		controller: "heatpump",
+		labels:     map[string]string{"controller": "heatpump"},
+		time:       time.Unix(1577836800, 0),
+		readings:   []reading{newReading(desc, 25.5, "Flow", "degC")},
+		up:         newReading(upDesc, 1, statusOK),
+	}
+
+	// Nothing is delivered without a running sink; overflowing snapshots
+	// are discarded without a buffer.
+	for range pushQueueSize {
+		if err := s.Consume(ctx, snap); err != nil {
+			t.Errorf("Consume() failed: %v", err)
+		}
+	}
+
+	if err := s.Consume(ctx, snap); err == nil {
+		t.Errorf("Consume() succeeded with a full queue")
+	}
+
+	if got := testutil.ToFloat64(s.droppedBytes); got == 0 {
+		t.Errorf("No bytes dropped with a full queue")
+	}
+
+	go s.Run(ctx)
+
+	want := "luxws_temperature,controller=heatpump,name=Flow,unit=degC value=25.5 1577836800000000000\n" +
+		"luxws_up,controller=heatpump value=1 1577836800000000000\n"
+
+	for deadline := time.Now().Add(10 * time.Second); ; {
+		if got := testutil.ToFloat64(s.sentBatches); got >= pushQueueSize {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("Snapshots not delivered")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	_, received := server.result()
+
+	if len(received) != pushQueueSize {
+		t.Errorf("Received %d batches, want %d", len(received), pushQueueSize)
+	}
+
+	for _, body := range received {
+		if diff := cmp.Diff(want, body); diff != "" {
+			t.Errorf("Body difference (-want +got):\n%s", diff)
+		}
+	}
+}
+
+func TestPushBufferLimit(t *testing.T) {
+	dir := t.TempDir()
+
+	b, err := newPushBuffer(dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		body        string
+		wantDropped int64
+	}{
+		{"1234", 0},
+		{"5678", 0},
+		{"abcd", 4},
+		{"too large for buffer", 20},
+	} {
+		dropped, err := b.store([]byte(tc.body))
+		if err != nil {
+			t.Errorf("store(%q) failed: %v", tc.body, err)
+		}
+
+		if dropped != tc.wantDropped {
+			t.Errorf("store(%q) dropped %d bytes, want %d", tc.body, dropped, tc.wantDropped)
+		}
+	}
+
+	var got []string
+
+	if err := b.drain(func(body []byte) error {
+		got = append(got, string(body))
+		return nil
+	}); err != nil {
+		t.Errorf("drain() failed: %v", err)
+	}
+
+	if diff := cmp.Diff([]string{"5678", "abcd"}, got); diff != "" {
+		t.Errorf("Buffered entries difference (-want +got):\n%s", diff)
+	}
+
+	if entries, err := os.ReadDir(dir); err != nil {
+		t.Error(err)
+	} else if len(entries) != 0 {
+		t.Errorf("Buffer directory not empty: %v", entries)
+	}
+}
+
+func TestParseHeaders(t *testing.T) {
+	got, err := parseHeaders([]string{"Authorization=Bearer x=y", "X-Scope: home"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(http.Header{
+		"Authorization": {"Bearer x=y"},
+		"X-Scope":       {"home"},
+	}, got); diff != "" {
+		t.Errorf("Headers difference (-want +got):\n%s", diff)
+	}
+
+	if _, err := parseHeaders([]string{"invalid"}); err == nil {
+		t.Errorf("parseHeaders() accepted invalid header")
+	}
+}
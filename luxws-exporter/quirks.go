@@ -1,5 +1,14 @@
 package main
 
+import "strings"
+
 type quirks struct {
 	missingSuppliedHeat bool
 }
+
+// observeType updates the quirks based on a heat pump type name.
+func (q *quirks) observeType(name string) {
+	if strings.EqualFold(name, "L2A") {
+		q.missingSuppliedHeat = true
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// readingKind describes how the values of a reading family behave over time.
+type readingKind int
+
+const (
+	// The value may go up and down.
+	readingGauge readingKind = iota
+
+	// The value only increases, except for resets.
+	readingCounter
+
+	// Distribution of observations. The value is the sum of all
+	// observations.
+	readingHistogram
+)
+
+func (k readingKind) valueType() prometheus.ValueType {
+	if k == readingCounter {
+		return prometheus.CounterValue
+	}
+
+	return prometheus.GaugeValue
+}
+
+// readingDesc describes a family of readings, e.g. all temperatures.
+type readingDesc struct {
+	name       string
+	help       string
+	kind       readingKind
+	labelNames []string
+
+	// Descriptor of the derived Prometheus metrics.
+	prom *prometheus.Desc
+}
+
+func newReadingDesc(name, help string, labelNames []string, kind readingKind) *readingDesc {
+	return &readingDesc{
+		name:       name,
+		help:       help,
+		kind:       kind,
+		labelNames: labelNames,
+		prom:       prometheus.NewDesc(name, help, labelNames, nil),
+	}
+}
+
+// reading is a single value produced by a collection. Readings don't depend
+// on any particular output; Prometheus metrics are derived from them.
+type reading struct {
+	desc        *readingDesc
+	labelValues []string
+	value       float64
+
+	// Only set for histograms.
+	histogram *histogramValue
+}
+
+// histogramValue is the distribution of a histogram reading.
+type histogramValue struct {
+	count uint64
+	sum   float64
+
+	// Upper bounds in increasing order, excluding +Inf.
+	bounds []float64
+
+	// Cumulative number of observations less than or equal to the bound
+	// with the same index.
+	buckets []uint64
+}
+
+// newReading returns a reading of the given family. The label values must
+// match the label names of the family.
+func newReading(desc *readingDesc, value float64, labelValues ...string) reading {
+	return reading{
+		desc:        desc,
+		labelValues: labelValues,
+		value:       value,
+	}
+}
+
+// newHistogramReading returns a reading of a histogram family.
+func newHistogramReading(desc *readingDesc, h histogramValue, labelValues ...string) reading {
+	return reading{
+		desc:        desc,
+		labelValues: labelValues,
+		value:       h.sum,
+		histogram:   &h,
+	}
+}
+
+// metric converts the reading into a Prometheus metric.
+func (r reading) metric() prometheus.Metric {
+	if h := r.histogram; h != nil {
+		buckets := make(map[float64]uint64, len(h.bounds))
+
+		for idx, bound := range h.bounds {
+			buckets[bound] = h.buckets[idx]
+		}
+
+		return prometheus.MustNewConstHistogram(r.desc.prom, h.count, h.sum, buckets, r.labelValues...)
+	}
+
+	return prometheus.MustNewConstMetric(r.desc.prom, r.desc.kind.valueType(), r.value, r.labelValues...)
+}
+
+// collectReadings runs fn with a channel for readings and returns all
+// readings sent to it.
+func collectReadings(fn func(chan<- reading) error) ([]reading, error) {
+	var result []reading
+
+	ch := make(chan reading)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for r := range ch {
+			result = append(result, r)
+		}
+	}()
+
+	err := fn(ch)
+
+	close(ch)
+	<-done
+
+	return result, err
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type readingCollector []reading
+
+func (readingCollector) Describe(chan<- *prometheus.Desc) {}
+
+func (c readingCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, r := range c {
+		ch <- r.metric()
+	}
+}
+
+func TestReadingMetric(t *testing.T) {
+	gauge := newReadingDesc("test_gauge", "Gauge", []string{"name"}, readingGauge)
+	counter := newReadingDesc("test_total", "Counter", nil, readingCounter)
+	histogram := newReadingDesc("test_seconds", "Histogram", nil, readingHistogram)
+
+	readings, err := collectReadings(func(ch chan<- reading) error {
+		ch <- newReading(gauge, 1.5, "a")
+		ch <- newReading(counter, 3)
+		ch <- newHistogramReading(histogram, histogramValue{
+			count:   3,
+			sum:     7.5,
+			bounds:  []float64{1, 5},
+			buckets: []uint64{1, 2},
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("collectReadings() failed: %v", err)
+	}
+
+	if err := testutil.CollectAndCompare(readingCollector(readings), strings.NewReader(`
+# HELP test_gauge Gauge
+# TYPE test_gauge gauge
+test_gauge{name="a"} 1.5
+# HELP test_seconds Histogram
+# TYPE test_seconds histogram
+test_seconds_bucket{le="1"} 1
+test_seconds_bucket{le="5"} 2
+test_seconds_bucket{le="+Inf"} 3
+test_seconds_sum 7.5
+test_seconds_count 3
+# HELP test_total Counter
+# TYPE test_total counter
+test_total 3
+`)); err != nil {
+		t.Error(err)
+	}
+}
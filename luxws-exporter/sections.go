@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"sort"
+
+	"github.com/hansmi/wp2reg-luxws/luxwsclient"
+	"github.com/hansmi/wp2reg-luxws/luxwslang"
+	"go.uber.org/multierr"
+)
+
+// Reasons for item-level parse errors. The set is fixed to keep the
+// cardinality of luxws_parse_errors_total low.
+const (
+	reasonUnknownUnit        = "unknown_unit"
+	reasonUnsupportedUnit    = "unsupported_unit"
+	reasonInvalidMeasurement = "invalid_measurement"
+	reasonInvalidDuration    = "invalid_duration"
+	reasonInvalidTimestamp   = "invalid_timestamp"
+	reasonInvalidNumber      = "invalid_number"
+)
+
+// itemError is a failure to parse the value of a single item. The item is
+// skipped while the remaining items of the section are still collected.
+type itemError struct {
+	reason string
+	item   string
+	err    error
+}
+
+func newItemError(reason, item string, err error) error {
+	return &itemError{
+		reason: reason,
+		item:   item,
+		err:    err,
+	}
+}
+
+// newMeasurementError classifies an error returned by collector.parseValue.
+func newMeasurementError(item string, err error) error {
+	reason := reasonInvalidMeasurement
+
+	if errors.Is(err, luxwslang.ErrUnknownUnit) {
+		reason = reasonUnknownUnit
+	}
+
+	return newItemError(reason, item, err)
+}
+
+func (e *itemError) Error() string {
+	return e.err.Error()
+}
+
+func (e *itemError) Unwrap() error {
+	return e.err
+}
+
+// skippedItem is an item which couldn't be parsed.
+type skippedItem struct {
+	section string
+	*itemError
+}
+
+type parseErrorKey struct {
+	section string
+	reason  string
+}
+
+// splitItemErrors separates item-level errors from errors affecting a whole
+// section.
+func splitItemErrors(err error) (items []*itemError, remaining error) {
+	for _, cur := range multierr.Errors(err) {
+		var ie *itemError
+
+		if errors.As(cur, &ie) {
+			items = append(items, ie)
+		} else {
+			multierr.AppendInto(&remaining, cur)
+		}
+	}
+
+	return items, remaining
+}
+
+// collectSection runs the collection function of a section and reports its
+// status. Item-level errors are counted and returned separately from errors
+// affecting the whole section.
+func (c *collector) collectSection(ch chan<- reading, s contentSection, content *luxwsclient.ContentRoot, q *quirks) ([]skippedItem, error) {
+	items, err := splitItemErrors(s.fn(ch, content, q))
+
+	var skipped []skippedItem
+
+	if len(items) > 0 {
+		c.parseErrorsMu.Lock()
+
+		for _, ie := range items {
+			skipped = append(skipped, skippedItem{s.name, ie})
+
+			c.parseErrors[parseErrorKey{s.name, ie.reason}]++
+
+			if c.verbose {
+				log.Printf("Section %s: skipping item %q: %v", s.name, ie.item, ie.err)
+			}
+		}
+
+		c.parseErrorsMu.Unlock()
+	}
+
+	var up float64
+
+	if err == nil {
+		up = 1
+	}
+
+	ch <- newReading(c.sectionUpDesc, up, s.name)
+
+	return skipped, err
+}
+
+// collectParseErrors exports the number of skipped items per section and
+// reason.
+func (c *collector) collectParseErrors(ch chan<- reading) {
+	c.parseErrorsMu.Lock()
+	defer c.parseErrorsMu.Unlock()
+
+	keys := make([]parseErrorKey, 0, len(c.parseErrors))
+
+	for key := range c.parseErrors {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].section != keys[j].section {
+			return keys[i].section < keys[j].section
+		}
+
+		return keys[i].reason < keys[j].reason
+	})
+
+	for _, key := range keys {
+		ch <- newReading(c.parseErrorsDesc, c.parseErrors[key], key.section, key.reason)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/hansmi/wp2reg-luxws/luxwslang"
+	"github.com/hansmi/wp2reg-luxws/luxwssim"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// TestCollectSimulator collects all sections from a simulated controller for
+// all supported languages and heat pump variants.
+func TestCollectSimulator(t *testing.T) {
+	for _, terms := range luxwslang.All() {
+		for _, variant := range luxwssim.Variants() {
+			if variant.Latin1 && terms.ID == "cz" {
+				continue
+			}
+
+			t.Run(terms.ID+"/"+variant.Name, func(t *testing.T) {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+				t.Cleanup(cancel)
+
+				sim, err := luxwssim.NewServer(luxwssim.Options{
+					Terms:   terms,
+					Variant: variant,
+				})
+				if err != nil {
+					t.Fatalf("NewServer() failed: %v", err)
+				}
+
+				server := httptest.NewServer(sim)
+				t.Cleanup(server.Close)
+
+				serverURL, err := url.Parse(server.URL)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				c := newCollector(collectorOpts{
+					terms:   terms,
+					loc:     time.UTC,
+					timeout: time.Minute,
+					address: serverURL.Host,
+					layout:  layoutUnits,
+				})
+
+				a := &adapter{
+					c: c,
+					collect: func(ch chan<- reading) error {
+						return c.collect(ctx, ch, nil)
+					},
+				}
+
+				reg := prometheus.NewPedanticRegistry()
+				reg.MustRegister(a)
+
+				families, err := reg.Gather()
+				if err != nil {
+					t.Fatalf("Gather() failed: %v", err)
+				}
+
+				if a.collectErr != nil {
+					t.Fatalf("Collection failed: %v", a.collectErr)
+				}
+
+				if len(c.parseErrors) > 0 {
+					t.Errorf("Items skipped: %v", c.parseErrors)
+				}
+
+				found := map[string]bool{}
+
+				for _, mf := range families {
+					found[mf.GetName()] = true
+				}
+
+				for _, name := range []string{
+					"luxws_info",
+					"luxws_temperature_celsius",
+					"luxws_input_flow_rate_liters_per_hour",
+					compressorStartsName,
+					"luxws_temperature_spread_kelvins",
+				} {
+					if !found[name] {
+						t.Errorf("Metric %q not collected", name)
+					}
+				}
+
+				if got := found["luxws_supplied_heat_kilowatt_hours_total"]; got != variant.HeatQuantity {
+					t.Errorf("Supplied heat collected: %v, want %v", got, variant.HeatQuantity)
+				}
+			})
+		}
+	}
+}
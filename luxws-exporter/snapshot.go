@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+)
+
+// snapshot is the result of a single collection from a controller. Sinks
+// must treat snapshots as read-only.
+type snapshot struct {
+	// Name of the controller ("default" when not using a configuration
+	// file).
+	controller string
+
+	// Labels identifying the controller in push outputs, e.g. the
+	// controller name and configured labels. Empty when not using
+	// a configuration file.
+	labels map[string]string
+
+	time time.Time
+
+	// Items of the information page. Empty if the page couldn't be
+	// retrieved.
+	values []apiValue
+
+	// Readings of the collection, excluding the scrape status. Prometheus
+	// metrics and other outputs are derived from them.
+	readings []reading
+
+	// Scrape status derived from err.
+	up reading
+
+	// Reason for an unsuccessful collection.
+	err error
+}
+
+// sink receives every snapshot produced by a scrape or poll. Implementations
+// must be safe for concurrent use as controllers are collected independently.
+// Consume is called before scrapes return and must not block on external
+// systems; such outputs are wrapped in a queuedSink.
+type sink interface {
+	Consume(ctx context.Context, s *snapshot) error
+}
+
+// sinkFunc is an adapter to use ordinary functions as sinks.
+type sinkFunc func(context.Context, *snapshot) error
+
+func (f sinkFunc) Consume(ctx context.Context, s *snapshot) error {
+	return f(ctx, s)
+}
+
+// forgetter is implemented by sinks keeping state per controller.
+type forgetter interface {
+	// Forget discards all state kept for a controller.
+	Forget(controller string)
+}
+
+type namedSink struct {
+	name string
+	sink
+}
+
+// sinkSet passes snapshots to all subscribed sinks. Errors of individual
+// sinks don't prevent delivery to others.
+type sinkSet struct {
+	mu    sync.RWMutex
+	sinks []namedSink
+}
+
+// Subscribe adds a sink. The name is used in error messages.
+func (s *sinkSet) Subscribe(name string, sk sink) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sinks = append(s.sinks, namedSink{name, sk})
+}
+
+func (s *sinkSet) Consume(ctx context.Context, snap *snapshot) error {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.RLock()
+	sinks := s.sinks
+	s.mu.RUnlock()
+
+	var err error
+
+	for _, sk := range sinks {
+		if sinkErr := sk.Consume(ctx, snap); sinkErr != nil {
+			multierr.AppendInto(&err, fmt.Errorf("%s: %w", sk.name, sinkErr))
+		}
+	}
+
+	return err
+}
+
+// Forget passes on to all sinks keeping state per controller. Used when
+// a controller is removed or its configuration changed.
+func (s *sinkSet) Forget(controller string) {
+	if s == nil {
+		return
+	}
+
+	s.mu.RLock()
+	sinks := s.sinks
+	s.mu.RUnlock()
+
+	for _, sk := range sinks {
+		if f, ok := sk.sink.(forgetter); ok {
+			f.Forget(controller)
+		}
+	}
+}
+
+// sinkQueueSize is the number of snapshots waiting for a queued sink before
+// further snapshots are discarded.
+const sinkQueueSize = 16
+
+// queuedSink passes snapshots to another sink in the background so that slow
+// or unavailable outputs don't delay scrapes and polls.
+type queuedSink struct {
+	name  string
+	next  sink
+	queue chan *snapshot
+}
+
+func newQueuedSink(name string, next sink) *queuedSink {
+	return &queuedSink{
+		name:  name,
+		next:  next,
+		queue: make(chan *snapshot, sinkQueueSize),
+	}
+}
+
+// Consume queues a snapshot. The snapshot is discarded if the queue is full.
+func (q *queuedSink) Consume(_ context.Context, snap *snapshot) error {
+	select {
+	case q.queue <- snap:
+		return nil
+	default:
+		return errors.New("queue is full, snapshot discarded")
+	}
+}
+
+// Run passes queued snapshots on until the context is cancelled.
+func (q *queuedSink) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case snap := <-q.queue:
+			if err := q.next.Consume(ctx, snap); err != nil && ctx.Err() == nil {
+				log.Printf("Output failed: %s: %v", q.name, err)
+			}
+		}
+	}
+}
+
+// snapshotStore keeps the most recent snapshot with values of every
+// controller.
+type snapshotStore struct {
+	mu     sync.Mutex
+	latest map[string]*snapshot
+}
+
+func newSnapshotStore() *snapshotStore {
+	return &snapshotStore{
+		latest: map[string]*snapshot{},
+	}
+}
+
+func (s *snapshotStore) Consume(_ context.Context, snap *snapshot) error {
+	if len(snap.values) > 0 {
+		s.mu.Lock()
+		s.latest[snap.controller] = snap
+		s.mu.Unlock()
+	}
+
+	return nil
+}
+
+func (s *snapshotStore) Forget(controller string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.latest, controller)
+}
+
+// get returns the most recent snapshot of a controller or nil.
+func (s *snapshotStore) get(controller string) *snapshot {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.latest[controller]
+}
+
+// valuesResponse returns the representation used by the JSON API.
+func (s *snapshot) valuesResponse() apiValuesResponse {
+	values := s.values
+
+	if values == nil {
+		values = []apiValue{}
+	}
+
+	return apiValuesResponse{
+		Controller: s.controller,
+		Time:       s.time.UTC(),
+		Values:     values,
+	}
+}
+
+// writeFileAtomic replaces a file with the given content. Readers never see
+// a partially written file.
+func writeFileAtomic(path string, content []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// fileSink writes the values of every successful collection to a file. The
+// path may contain "{controller}" to write one file per controller.
+type fileSink struct {
+	pathTemplate string
+}
+
+func (s *fileSink) path(controller string) string {
+	return strings.ReplaceAll(s.pathTemplate, "{controller}", controller)
+}
+
+func (s *fileSink) Consume(_ context.Context, snap *snapshot) error {
+	if snap.err != nil {
+		return nil
+	}
+
+	content, err := json.MarshalIndent(snap.valuesResponse(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(s.path(snap.controller), append(content, '\n'), 0o644)
+}
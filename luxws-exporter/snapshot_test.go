@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hansmi/wp2reg-luxws/luxwslang"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollectorSnapshot(t *testing.T) {
+	discardAllLogs(t)
+
+	now := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	c := newCollector(collectorOpts{
+		terms:   luxwslang.English,
+		loc:     time.UTC,
+		timeout: time.Minute,
+		name:    "test",
+		address: newTestController(t, `<Content id="0x2">`+
+			`<item id="0x10"><name>temperatures</name>`+
+			`<item id="0x11"><name>Flow</name><value>25.0°C</value></item>`+
+			`</item>`+
+			`</Content>`),
+	})
+	c.now = func() time.Time {
+		return now
+	}
+
+	snap := c.snapshot(context.Background())
+
+	if snap.err == nil {
+		t.Errorf("Collection succeeded despite missing sections")
+	}
+
+	if snap.controller != "test" || !snap.time.Equal(now) {
+		t.Errorf("Unexpected snapshot %q at %v", snap.controller, snap.time)
+	}
+
+	if len(snap.readings) == 0 {
+		t.Errorf("Snapshot has no readings")
+	}
+
+	value := 25.0
+
+	if diff := cmp.Diff([]apiValue{
+		{Key: "temperatures.flow", Path: "temperatures/Flow", Name: "Flow", Text: "25.0°C", Value: &value, Unit: "degC"},
+	}, snap.values); diff != "" {
+		t.Errorf("Values difference (-want +got):\n%s", diff)
+	}
+}
+
+func TestCollectorScrapeSinks(t *testing.T) {
+	discardAllLogs(t)
+
+	var got []*snapshot
+
+	sinks := &sinkSet{}
+	sinks.Subscribe("test", sinkFunc(func(_ context.Context, snap *snapshot) error {
+		got = append(got, snap)
+		return nil
+	}))
+
+	c := newCollector(collectorOpts{
+		terms:   luxwslang.English,
+		loc:     time.UTC,
+		timeout: time.Minute,
+		name:    "test",
+		address: newTestController(t, debugTestContent),
+		sinks:   sinks,
+	})
+
+	count := testutil.CollectAndCount(c)
+
+	if len(got) != 1 {
+		t.Fatalf("Sinks received %d snapshots, want 1", len(got))
+	}
+
+	// All readings are exported in addition to the scrape status
+	if want := len(got[0].readings) + 1; count != want {
+		t.Errorf("Collected %d metrics, want %d", count, want)
+	}
+
+	if got[0].controller != "test" || len(got[0].values) == 0 {
+		t.Errorf("Unexpected snapshot %q with %d values", got[0].controller, len(got[0].values))
+	}
+}
+
+func TestSinkSetForget(t *testing.T) {
+	latest := newSnapshotStore()
+
+	s := &sinkSet{}
+	s.Subscribe("api", latest)
+	s.Subscribe("other", sinkFunc(func(context.Context, *snapshot) error {
+		return nil
+	}))
+
+	for _, name := range []string{"a", "b"} {
+		s.Consume(context.Background(), &snapshot{
+			controller: name,
+			values:     []apiValue{{Key: "a", Text: "1"}},
+		})
+	}
+
+	s.Forget("a")
+	(*sinkSet)(nil).Forget("a")
+
+	if latest.get("a") != nil {
+		t.Errorf("Snapshot of forgotten controller was kept")
+	}
+
+	if latest.get("b") == nil {
+		t.Errorf("Snapshot of other controller was discarded")
+	}
+}
+
+func TestSinkSet(t *testing.T) {
+	var s sinkSet
+	var got []string
+
+	for _, name := range []string{"first", "failing", "last"} {
+		s.Subscribe(name, sinkFunc(func(_ context.Context, snap *snapshot) error {
+			got = append(got, name+":"+snap.controller)
+
+			if name == "failing" {
+				return errors.New("test error")
+			}
+
+			return nil
+		}))
+	}
+
+	err := s.Consume(context.Background(), &snapshot{controller: "a"})
+
+	if err == nil || err.Error() != "failing: test error" {
+		t.Errorf("Consume() returned %v", err)
+	}
+
+	if diff := cmp.Diff([]string{"first:a", "failing:a", "last:a"}, got); diff != "" {
+		t.Errorf("Delivery difference (-want +got):\n%s", diff)
+	}
+
+	if err := (*sinkSet)(nil).Consume(context.Background(), &snapshot{}); err != nil {
+		t.Errorf("Consume() on nil set returned %v", err)
+	}
+}
+
+func TestQueuedSink(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	release := make(chan struct{})
+	received := make(chan string, sinkQueueSize+1)
+
+	q := newQueuedSink("test", sinkFunc(func(_ context.Context, snap *snapshot) error {
+		<-release
+		received <- snap.controller
+		return nil
+	}))
+
+	// A blocked output doesn't delay consumers
+	for range sinkQueueSize {
+		if err := q.Consume(ctx, &snapshot{controller: "a"}); err != nil {
+			t.Errorf("Consume() failed: %v", err)
+		}
+	}
+
+	if err := q.Consume(ctx, &snapshot{controller: "b"}); err == nil {
+		t.Errorf("Consume() succeeded with a full queue")
+	}
+
+	go q.Run(ctx)
+
+	close(release)
+
+	for range sinkQueueSize {
+		select {
+		case got := <-received:
+			if got != "a" {
+				t.Errorf("Received snapshot of %q", got)
+			}
+
+		case <-time.After(10 * time.Second):
+			t.Fatalf("Queued snapshots not delivered")
+		}
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s := &fileSink{pathTemplate: filepath.Join(dir, "values-{controller}.json")}
+
+	value := 1.5
+	ts := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	values := []apiValue{{Key: "a", Path: "a", Name: "a", Text: "1.5", Value: &value}}
+
+	if err := s.Consume(ctx, &snapshot{controller: "x", time: ts, values: values}); err != nil {
+		t.Errorf("Consume() failed: %v", err)
+	}
+
+	// Failed collections don't replace the file
+	if err := s.Consume(ctx, &snapshot{controller: "x", err: errors.New("test")}); err != nil {
+		t.Errorf("Consume() failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "values-x.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got apiValuesResponse
+
+	if err := json.Unmarshal(content, &got); err != nil {
+		t.Fatalf("Decoding %s failed: %v", content, err)
+	}
+
+	if diff := cmp.Diff(apiValuesResponse{Controller: "x", Time: ts, Values: values}, got); diff != "" {
+		t.Errorf("File content difference (-want +got):\n%s", diff)
+	}
+
+	if entries, err := os.ReadDir(dir); err != nil {
+		t.Error(err)
+	} else if len(entries) != 1 {
+		t.Errorf("Temporary files left behind: %v", entries)
+	}
+}
+
+func TestPollerSinks(t *testing.T) {
+	discardAllLogs(t)
+
+	sinks := &sinkSet{}
+
+	c := newCollector(collectorOpts{
+		terms:   luxwslang.English,
+		loc:     time.UTC,
+		timeout: time.Minute,
+		sinks:   sinks,
+	})
+
+	value := 1.0
+	latest := newSnapshotStore()
+
+	sinks.Subscribe("api", latest)
+
+	p := newPoller(c, pollOpts{
+		interval: time.Minute,
+	})
+	p.snapshot = func(context.Context) *snapshot {
+		return &snapshot{
+			controller: "a",
+			values:     []apiValue{{Key: "a", Text: "1", Value: &value}},
+		}
+	}
+
+	if err := p.poll(context.Background()); err != nil {
+		t.Errorf("poll() failed: %v", err)
+	}
+
+	h := &apiHandler{
+		targets: func() map[string]*collector {
+			return map[string]*collector{"a": c}
+		},
+		latest: latest,
+	}
+
+	// Served without contacting the controller
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/values", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Got status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var got apiValuesResponse
+
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Decoding response failed: %v", err)
+	}
+
+	if diff := cmp.Diff([]apiValue{{Key: "a", Text: "1", Value: &value}}, got.Values); diff != "" {
+		t.Errorf("Values difference (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+// Values of the status label of luxws_up. The set is fixed to keep the
+// cardinality low; the complete error is logged.
+const (
+	statusOK      = ""
+	statusDial    = "dial"
+	statusLogin   = "login"
+	statusFetch   = "fetch"
+	statusParse   = "parse"
+	statusHTTP    = "http"
+	statusState   = "state"
+	statusTimeout = "timeout"
+	statusPending = "pending"
+	statusStale   = "stale"
+	statusUnknown = "unknown"
+)
+
+// statusError associates an error with a status for luxws_up.
+type statusError struct {
+	status string
+	err    error
+}
+
+// withStatus returns err annotated with the given status. Nil is returned if
+// err is nil.
+func withStatus(status string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &statusError{
+		status: status,
+		err:    err,
+	}
+}
+
+func (e *statusError) Error() string {
+	return e.err.Error()
+}
+
+func (e *statusError) Unwrap() error {
+	return e.err
+}
+
+// errorStatus returns the status for luxws_up describing err. Timeouts take
+// precedence over the annotated status.
+func errorStatus(err error) string {
+	if err == nil {
+		return statusOK
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return statusTimeout
+	}
+
+	var se *statusError
+
+	if errors.As(err, &se) {
+		return se.status
+	}
+
+	return statusUnknown
+}
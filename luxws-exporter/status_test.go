@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"go.uber.org/multierr"
+)
+
+func TestErrorStatus(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "success", want: statusOK},
+		{name: "unknown", err: errors.New("test"), want: statusUnknown},
+		{name: "dial", err: withStatus(statusDial, errors.New("refused")), want: statusDial},
+		{
+			name: "wrapped",
+			err:  fmt.Errorf("collection failed: %w", withStatus(statusLogin, errors.New("denied"))),
+			want: statusLogin,
+		},
+		{
+			name: "combined",
+			err: multierr.Combine(
+				withStatus(statusParse, errors.New("missing group")),
+				withStatus(statusHTTP, errors.New("no date")),
+			),
+			want: statusParse,
+		},
+		{
+			name: "timeout",
+			err:  withStatus(statusFetch, fmt.Errorf("get: %w", context.DeadlineExceeded)),
+			want: statusTimeout,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := errorStatus(tc.err); got != tc.want {
+				t.Errorf("errorStatus(%v) = %q, want %q", tc.err, got, tc.want)
+			}
+		})
+	}
+
+	if err := withStatus(statusDial, nil); err != nil {
+		t.Errorf("withStatus(nil) = %v, want nil", err)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/hansmi/wp2reg-luxws/luxws"
+	"github.com/hansmi/wp2reg-luxws/luxwsclient"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Controllers have been observed to slow down to responses taking 20 seconds
+// and more before hanging completely.
+var transportBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60}
+
+// transportMetrics measures the communication with controllers.
+type transportMetrics struct {
+	dial      *prometheus.HistogramVec
+	roundTrip *prometheus.HistogramVec
+	decode    *prometheus.HistogramVec
+	http      *prometheus.HistogramVec
+	sent      *prometheus.CounterVec
+	received  *prometheus.CounterVec
+	failures  *prometheus.CounterVec
+}
+
+func newTransportMetrics() *transportMetrics {
+	histogram := func(name, help string, labels ...string) *prometheus.HistogramVec {
+		return prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    name,
+			Help:    help,
+			Buckets: transportBuckets,
+		}, append([]string{"target"}, labels...))
+	}
+
+	return &transportMetrics{
+		dial: histogram("luxws_dial_duration_seconds",
+			"Time taken to establish Websocket connections"),
+		roundTrip: histogram("luxws_roundtrip_duration_seconds",
+			"Time between sending a command and receiving the complete response", "command"),
+		decode: histogram("luxws_decode_duration_seconds",
+			"Time taken to decode XML responses"),
+		http: histogram("luxws_http_duration_seconds",
+			"Time taken to query the controller time via HTTP"),
+		sent: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "luxws_sent_bytes_total",
+			Help: "Number of bytes sent in Websocket messages",
+		}, []string{"target"}),
+		received: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "luxws_received_bytes_total",
+			Help: "Number of bytes received in Websocket messages",
+		}, []string{"target"}),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "luxws_transport_failures_total",
+			Help: "Number of failed connection attempts and round trips",
+		}, []string{"target", "operation"}),
+	}
+}
+
+// Metrics returns the collectors for transport metrics.
+func (m *transportMetrics) Metrics() []prometheus.Collector {
+	return []prometheus.Collector{
+		m.dial, m.roundTrip, m.decode, m.http,
+		m.sent, m.received, m.failures,
+	}
+}
+
+// clientOptions returns client options installing hooks which record metrics
+// for the given target.
+func (m *transportMetrics) clientOptions(target string) []luxwsclient.Option {
+	return []luxwsclient.Option{
+		luxwsclient.WithTransportOptions(luxws.WithHooks(luxws.Hooks{
+			Dialed: func(d time.Duration, err error) {
+				m.dial.WithLabelValues(target).Observe(d.Seconds())
+
+				if err != nil {
+					m.failures.WithLabelValues(target, "dial").Inc()
+				}
+			},
+			RoundTripDone: func(command string, d time.Duration, err error) {
+				command = strings.ToLower(command)
+
+				m.roundTrip.WithLabelValues(target, command).Observe(d.Seconds())
+
+				if err != nil {
+					m.failures.WithLabelValues(target, command).Inc()
+				}
+			},
+			MessageSent: func(size int) {
+				m.sent.WithLabelValues(target).Add(float64(size))
+			},
+			MessageReceived: func(size int) {
+				m.received.WithLabelValues(target).Add(float64(size))
+			},
+		})),
+		luxwsclient.WithDecodeHook(func(d time.Duration) {
+			m.decode.WithLabelValues(target).Observe(d.Seconds())
+		}),
+	}
+}
+
+// observeHTTP records the duration of an HTTP request.
+func (m *transportMetrics) observeHTTP(target string, d time.Duration, err error) {
+	m.http.WithLabelValues(target).Observe(d.Seconds())
+
+	if err != nil {
+		m.failures.WithLabelValues(target, "http").Inc()
+	}
+}
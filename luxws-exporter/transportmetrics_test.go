@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/hansmi/wp2reg-luxws/luxwslang"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestTransportMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", "Mon, 02 Jan 2006 15:04:05 GMT")
+		http.Error(w, "", http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := newTransportMetrics()
+
+	c := newCollector(collectorOpts{
+		terms:            luxwslang.English,
+		loc:              time.UTC,
+		timeout:          time.Minute,
+		address:          serverURL.Host,
+		httpAddress:      serverURL.Host,
+		transportMetrics: m,
+	})
+
+	discardAllLogs(t)
+
+	ch := make(chan reading, 100)
+
+	if err := c.collectWebSocket(context.Background(), ch, nil); err == nil {
+		t.Errorf("collectWebSocket() didn't fail")
+	}
+
+	if err := c.collectHTTP(context.Background(), ch); err != nil {
+		t.Errorf("collectHTTP() failed: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		c    prometheus.Collector
+		want int
+	}{
+		{"dial", m.dial, 1},
+		{"http", m.http, 1},
+		{"roundtrip", m.roundTrip, 0},
+		{"failures", m.failures, 1},
+	} {
+		if got := testutil.CollectAndCount(tc.c); got != tc.want {
+			t.Errorf("%s: got %d series, want %d", tc.name, got, tc.want)
+		}
+	}
+
+	if got := testutil.ToFloat64(m.failures.WithLabelValues(serverURL.Host, "dial")); got != 1 {
+		t.Errorf("Dial failures = %v, want 1", got)
+	}
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(m.Metrics()...)
+
+	if _, err := reg.Gather(); err != nil {
+		t.Errorf("Gather() failed: %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"go.yaml.in/yaml/v2"
+)
+
+// webConfigFile contains the settings of the web configuration file (see
+// github.com/prometheus/exporter-toolkit) which are relevant outside of the
+// web server itself.
+type webConfigFile struct {
+	TLSServerConfig struct {
+		Cert     string `yaml:"cert"`
+		Key      string `yaml:"key"`
+		CertFile string `yaml:"cert_file"`
+		KeyFile  string `yaml:"key_file"`
+	} `yaml:"tls_server_config"`
+
+	Users map[string]string `yaml:"basic_auth_users"`
+}
+
+// readWebConfig parses a web configuration file. An empty path yields the
+// defaults, i.e. plain HTTP without authentication.
+func readWebConfig(path string) (*webConfigFile, error) {
+	cfg := &webConfigFile{}
+
+	if path == "" {
+		return cfg, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(content, cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s failed: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// tlsEnabled reports whether the web server serves HTTPS. The conditions
+// match those of the exporter toolkit.
+func (c *webConfigFile) tlsEnabled() bool {
+	t := c.TLSServerConfig
+
+	return t.Cert != "" || t.Key != "" || t.CertFile != "" || t.KeyFile != ""
+}
+
+// basicAuth reports whether the web server requires basic authentication.
+func (c *webConfigFile) basicAuth() bool {
+	return len(c.Users) > 0
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadWebConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		return path
+	}
+
+	for _, tc := range []struct {
+		name          string
+		path          string
+		wantErr       bool
+		wantTLS       bool
+		wantBasicAuth bool
+	}{
+		{name: "no file"},
+		{name: "missing", path: filepath.Join(dir, "missing.yml"), wantErr: true},
+		{name: "invalid", path: write("invalid.yml", "tls_server_config: [\n"), wantErr: true},
+		{name: "empty", path: write("empty.yml", "")},
+		{
+			name:    "tls files",
+			path:    write("tls.yml", "tls_server_config:\n  cert_file: server.crt\n  key_file: server.key\n"),
+			wantTLS: true,
+		},
+		{
+			name:    "tls inline",
+			path:    write("inline.yml", "tls_server_config:\n  cert: CERT\n  key: KEY\n"),
+			wantTLS: true,
+		},
+		{
+			name:          "users",
+			path:          write("users.yml", "basic_auth_users:\n  admin: $2y$10$abcdefghijklmnopqrstuv\n"),
+			wantBasicAuth: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, err := readWebConfig(tc.path)
+
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("readWebConfig() didn't fail")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("readWebConfig() failed: %v", err)
+			}
+
+			if got := cfg.tlsEnabled(); got != tc.wantTLS {
+				t.Errorf("tlsEnabled() = %t, want %t", got, tc.wantTLS)
+			}
+
+			if got := cfg.basicAuth(); got != tc.wantBasicAuth {
+				t.Errorf("basicAuth() = %t, want %t", got, tc.wantBasicAuth)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+// Command luxws-sim simulates a heat pump controller speaking the Lux_WS
+// protocol for development and testing without real hardware.
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/hansmi/wp2reg-luxws/luxwslang"
+	"github.com/hansmi/wp2reg-luxws/luxwssim"
+)
+
+var listenAddress = kingpin.Flag("listen", "Address on which to accept Websocket connections").Default(":8214").PlaceHolder("HOST:PORT").String()
+var lang = kingpin.Flag("language",
+	fmt.Sprintf("Interface language (one of %q)", supportedLanguages())).Default(luxwslang.German.ID).Enum(supportedLanguages()...)
+var variant = kingpin.Flag("variant",
+	fmt.Sprintf("Heat pump and firmware variant (one of %q)", variantNames())).Default("default").Enum(variantNames()...)
+var modelFile = kingpin.Flag("model",
+	"Serve pages from a YAML or XML model file instead of the built-in model").PlaceHolder("PATH").ExistingFile()
+var password = kingpin.Flag("password", "Password required for changing settings; any password is accepted if empty").Envar("LUXWS_PASSWORD").String()
+var speed = kingpin.Flag("speed", "Ratio of simulated to real time (e.g. 60 for one minute per second)").Default("1").Float64()
+var verbose = kingpin.Flag("verbose", "Log connections and received messages").Bool()
+
+func supportedLanguages() []string {
+	result := []string{}
+
+	for _, terms := range luxwslang.All() {
+		result = append(result, terms.ID)
+	}
+
+	return result
+}
+
+func variantNames() []string {
+	result := []string{}
+
+	for _, v := range luxwssim.Variants() {
+		result = append(result, v.Name)
+	}
+
+	return result
+}
+
+func main() {
+	log.SetFlags(log.LstdFlags | log.Lmicroseconds)
+
+	kingpin.Parse()
+
+	opts := luxwssim.Options{
+		Password: *password,
+		Speed:    *speed,
+	}
+
+	var err error
+
+	if opts.Terms, err = luxwslang.LookupByID(*lang); err != nil {
+		log.Fatal(err)
+	}
+
+	if opts.Variant, err = luxwssim.LookupVariant(*variant); err != nil {
+		log.Fatal(err)
+	}
+
+	if *modelFile != "" {
+		if opts.Model, err = luxwssim.LoadModel(*modelFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *verbose {
+		opts.Logf = log.Printf
+	}
+
+	s, err := luxwssim.NewServer(opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("Simulating %s (%s) in %s on %s", opts.Variant.Type, opts.Variant.Name, opts.Terms.Name, *listenAddress)
+
+	log.Fatal(http.ListenAndServe(*listenAddress, s))
+}
@@ -0,0 +1,232 @@
+package luxws
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Direction describes whether a recorded message was sent or received.
+type Direction string
+
+const (
+	DirectionSend    Direction = "send"
+	DirectionReceive Direction = "recv"
+)
+
+// RedactedPassword replaces the password of LOGIN commands in recordings.
+const RedactedPassword = "<redacted>"
+
+// Message is a single message of a recorded session.
+type Message struct {
+	// Time since the first message of the recording.
+	Offset time.Duration
+
+	Direction Direction
+
+	// Websocket message type (e.g. websocket.TextMessage).
+	Type int
+
+	Payload []byte
+}
+
+// messageJSON is the encoding of messages in recordings. Payloads which
+// aren't valid UTF-8, e.g. in a legacy charset, are encoded using base64.
+type messageJSON struct {
+	Offset        time.Duration `json:"offset"`
+	Direction     Direction     `json:"dir"`
+	Type          int           `json:"type"`
+	Payload       *string       `json:"payload,omitempty"`
+	PayloadBase64 []byte        `json:"payload_base64,omitempty"`
+}
+
+func (m Message) MarshalJSON() ([]byte, error) {
+	enc := messageJSON{
+		Offset:    m.Offset,
+		Direction: m.Direction,
+		Type:      m.Type,
+	}
+
+	if utf8.Valid(m.Payload) {
+		payload := string(m.Payload)
+		enc.Payload = &payload
+	} else {
+		enc.PayloadBase64 = m.Payload
+	}
+
+	return marshalJSON(enc)
+}
+
+// marshalJSON encodes a value without escaping HTML characters to keep XML
+// payloads readable. The trailing newline is included.
+func marshalJSON(v any) ([]byte, error) {
+	var buf bytes.Buffer
+
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (m *Message) UnmarshalJSON(data []byte) error {
+	var enc messageJSON
+
+	if err := json.Unmarshal(data, &enc); err != nil {
+		return err
+	}
+
+	*m = Message{
+		Offset:    enc.Offset,
+		Direction: enc.Direction,
+		Type:      enc.Type,
+		Payload:   enc.PayloadBase64,
+	}
+
+	if enc.Payload != nil {
+		m.Payload = []byte(*enc.Payload)
+	}
+
+	return nil
+}
+
+// redactRequest removes the password from LOGIN commands.
+func redactRequest(req string) string {
+	if strings.HasPrefix(req, "LOGIN;") {
+		return "LOGIN;" + RedactedPassword
+	}
+
+	return req
+}
+
+// Recorder writes all messages of a connection to a writer, one JSON object
+// per line. Passwords are redacted. Recorders are safe for concurrent use.
+type Recorder struct {
+	mu    sync.Mutex
+	w     io.Writer
+	start time.Time
+	err   error
+	now   func() time.Time
+}
+
+// NewRecorder returns a recorder writing to w. Offsets are relative to the
+// first recorded message.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{
+		w:   w,
+		now: time.Now,
+	}
+}
+
+func (r *Recorder) record(dir Direction, messageType int, payload []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return
+	}
+
+	now := r.now()
+
+	if r.start.IsZero() {
+		r.start = now
+	}
+
+	msg := Message{
+		Offset:    now.Sub(r.start),
+		Direction: dir,
+		Type:      messageType,
+		Payload:   payload,
+	}
+
+	if dir == DirectionSend {
+		msg.Payload = []byte(redactRequest(string(payload)))
+	}
+
+	data, err := marshalJSON(msg)
+	if err == nil {
+		_, err = r.w.Write(data)
+	}
+
+	r.err = err
+}
+
+// Err returns the first error encountered while writing.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.err
+}
+
+// WithRecorder records all messages sent and received by the transport.
+func WithRecorder(r *Recorder) Option {
+	return func(t *transport) {
+		t.recorder = r
+	}
+}
+
+// recordingConn passes all messages to a recorder.
+type recordingConn struct {
+	websocketConn
+	r *Recorder
+}
+
+func (c *recordingConn) WriteMessage(messageType int, payload []byte) error {
+	err := c.websocketConn.WriteMessage(messageType, payload)
+
+	if err == nil {
+		c.r.record(DirectionSend, messageType, payload)
+	}
+
+	return err
+}
+
+func (c *recordingConn) ReadMessage() (int, []byte, error) {
+	messageType, payload, err := c.websocketConn.ReadMessage()
+
+	if err == nil {
+		c.r.record(DirectionReceive, messageType, payload)
+	}
+
+	return messageType, payload, err
+}
+
+// ReadRecording parses a recording as written by a Recorder.
+func ReadRecording(r io.Reader) ([]Message, error) {
+	var result []Message
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16*1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+
+		var msg Message
+
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		switch msg.Direction {
+		case DirectionSend, DirectionReceive:
+		default:
+			return nil, fmt.Errorf("line %d: unknown direction %q", line, msg.Direction)
+		}
+
+		result = append(result, msg)
+	}
+
+	return result, scanner.Err()
+}
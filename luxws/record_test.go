@@ -0,0 +1,97 @@
+package luxws
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gorilla/websocket"
+)
+
+func TestRecorder(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+
+	var buf bytes.Buffer
+
+	rec := NewRecorder(&buf)
+
+	start := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	calls := 0
+	rec.now = func() time.Time {
+		calls++
+		return start.Add(time.Duration(calls-1) * time.Millisecond)
+	}
+
+	fc := newFakeConn(t)
+	fc.handleWrite = func(payload []byte, out chan<- cannedMessage) error {
+		response := "<Navigation/>"
+
+		if strings.HasPrefix(string(payload), "GET;") {
+			response = "<Content>\xfc</Content>"
+		}
+
+		out <- cannedMessage{
+			messageType: websocket.TextMessage,
+			payload:     []byte(response),
+		}
+
+		return nil
+	}
+
+	tr := newTransport(fc, []Option{
+		WithLogFunc(t.Logf),
+		WithRecorder(rec),
+	})
+	t.Cleanup(func() {
+		tr.Close()
+	})
+
+	for _, req := range []string{"LOGIN;secret", "GET;0x1"} {
+		if err := tr.RoundTrip(ctx, req, func([]byte) error {
+			return nil
+		}); err != nil {
+			t.Errorf("RoundTrip(%q) failed: %v", req, err)
+		}
+	}
+
+	if err := rec.Err(); err != nil {
+		t.Errorf("Recording failed: %v", err)
+	}
+
+	if strings.Contains(buf.String(), "secret") {
+		t.Errorf("Password not redacted: %s", buf.String())
+	}
+
+	if !strings.Contains(buf.String(), `"payload":"<Navigation/>"`) {
+		t.Errorf("Payload not readable: %s", buf.String())
+	}
+
+	got, err := ReadRecording(&buf)
+	if err != nil {
+		t.Fatalf("ReadRecording() failed: %v", err)
+	}
+
+	if diff := cmp.Diff([]Message{
+		{0, DirectionSend, websocket.TextMessage, []byte("LOGIN;" + RedactedPassword)},
+		{time.Millisecond, DirectionReceive, websocket.TextMessage, []byte("<Navigation/>")},
+		{2 * time.Millisecond, DirectionSend, websocket.TextMessage, []byte("GET;0x1")},
+		{3 * time.Millisecond, DirectionReceive, websocket.TextMessage, []byte("<Content>\xfc</Content>")},
+	}, got); diff != "" {
+		t.Errorf("Recording difference (-want +got):\n%s", diff)
+	}
+}
+
+func TestReadRecordingInvalid(t *testing.T) {
+	for _, input := range []string{
+		"not json",
+		`{"dir":"sideways"}`,
+	} {
+		if _, err := ReadRecording(strings.NewReader(input)); err == nil {
+			t.Errorf("ReadRecording(%q) succeeded", input)
+		}
+	}
+}
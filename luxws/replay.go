@@ -0,0 +1,214 @@
+package luxws
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrReplayMismatch is returned when a request sent to a replay transport
+// doesn't match the recording.
+var ErrReplayMismatch = errors.New("request doesn't match recording")
+
+// ReplayOptions controls how requests are matched against a recording.
+type ReplayOptions struct {
+	// By default requests must be sent in the recorded order. With loose
+	// matching any recorded request is accepted regardless of order and
+	// requests can be repeated; the responses of the most recent matching
+	// exchange are sent again once all have been used.
+	Loose bool
+
+	// Deliver responses with the recorded delays instead of immediately.
+	Timing bool
+}
+
+// matchRequest reports whether a request matches a recorded one. The
+// password of LOGIN commands is only compared if it wasn't redacted.
+func matchRequest(recorded, req string) bool {
+	if recorded == "LOGIN;"+RedactedPassword {
+		return strings.HasPrefix(req, "LOGIN;")
+	}
+
+	return recorded == req
+}
+
+type replayMessage struct {
+	Message
+	due time.Time
+}
+
+// replayConn is a websocket connection serving responses from a recording.
+type replayConn struct {
+	opts     ReplayOptions
+	messages []Message
+	now      func() time.Time
+	incoming chan replayMessage
+	closed   chan struct{}
+
+	mu   sync.Mutex
+	used []bool
+	next int
+}
+
+func newReplayConn(messages []Message, opts ReplayOptions) *replayConn {
+	c := &replayConn{
+		opts:     opts,
+		messages: messages,
+		now:      time.Now,
+		incoming: make(chan replayMessage, len(messages)),
+		closed:   make(chan struct{}),
+		used:     make([]bool, len(messages)),
+	}
+
+	// Messages received before the first request
+	c.enqueue(-1)
+
+	return c
+}
+
+func (c *replayConn) LocalAddr() net.Addr {
+	return &net.IPAddr{}
+}
+
+func (c *replayConn) RemoteAddr() net.Addr {
+	return &net.IPAddr{}
+}
+
+func (c *replayConn) SetWriteDeadline(time.Time) error {
+	return nil
+}
+
+// enqueue delivers the received messages following the request at the given
+// index, up to the next request.
+func (c *replayConn) enqueue(index int) {
+	start := c.now()
+
+	var sent time.Duration
+
+	if index >= 0 {
+		sent = c.messages[index].Offset
+	}
+
+	for i := index + 1; i < len(c.messages) && c.messages[i].Direction == DirectionReceive; i++ {
+		msg := replayMessage{Message: c.messages[i]}
+
+		if c.opts.Timing {
+			msg.due = start.Add(msg.Offset - sent)
+		}
+
+		select {
+		case c.incoming <- msg:
+		default:
+			// Buffer is full when loose matching repeats exchanges; drop
+			// excess messages.
+		}
+	}
+}
+
+// find returns the index of the recorded request matching the given one or
+// -1.
+func (c *replayConn) find(req string) int {
+	if !c.opts.Loose {
+		for i := c.next; i < len(c.messages); i++ {
+			if c.messages[i].Direction != DirectionSend {
+				continue
+			}
+
+			if matchRequest(string(c.messages[i].Payload), req) {
+				return i
+			}
+
+			break
+		}
+
+		return -1
+	}
+
+	repeat := -1
+
+	for i, msg := range c.messages {
+		if msg.Direction != DirectionSend || !matchRequest(string(msg.Payload), req) {
+			continue
+		}
+
+		if !c.used[i] {
+			return i
+		}
+
+		repeat = i
+	}
+
+	return repeat
+}
+
+func (c *replayConn) WriteMessage(_ int, payload []byte) error {
+	select {
+	case <-c.closed:
+		return net.ErrClosed
+	default:
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	req := string(payload)
+	index := c.find(req)
+
+	if index < 0 {
+		return fmt.Errorf("%w: %q", ErrReplayMismatch, redactRequest(req))
+	}
+
+	c.used[index] = true
+	c.next = index + 1
+	c.enqueue(index)
+
+	return nil
+}
+
+func (c *replayConn) ReadMessage() (int, []byte, error) {
+	var msg replayMessage
+
+	select {
+	case <-c.closed:
+		return 0, nil, net.ErrClosed
+	case msg = <-c.incoming:
+	}
+
+	if !msg.due.IsZero() {
+		timer := time.NewTimer(msg.due.Sub(c.now()))
+		defer timer.Stop()
+
+		select {
+		case <-c.closed:
+			return 0, nil, net.ErrClosed
+		case <-timer.C:
+		}
+	}
+
+	return msg.Type, msg.Payload, nil
+}
+
+func (c *replayConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	select {
+	case <-c.closed:
+		return net.ErrClosed
+	default:
+	}
+
+	close(c.closed)
+
+	return nil
+}
+
+// Replay returns a transport serving the responses of a recording. Requests
+// are matched according to the given options; unmatched requests fail with
+// ErrReplayMismatch. Recordings can be created using WithRecorder.
+func Replay(messages []Message, replayOpts ReplayOptions, opts ...Option) *Transport {
+	return newTransport(newReplayConn(messages, replayOpts), opts)
+}
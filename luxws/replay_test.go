@@ -0,0 +1,150 @@
+package luxws
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func testRecording() []Message {
+	msg := func(offset time.Duration, dir Direction, payload string) Message {
+		return Message{offset, dir, websocket.TextMessage, []byte(payload)}
+	}
+
+	return []Message{
+		msg(0, DirectionSend, "LOGIN;"+RedactedPassword),
+		msg(10*time.Millisecond, DirectionReceive, "nav"),
+		msg(20*time.Millisecond, DirectionSend, "GET;1"),
+		msg(30*time.Millisecond, DirectionReceive, "ignored"),
+		msg(40*time.Millisecond, DirectionReceive, "page1"),
+		msg(50*time.Millisecond, DirectionSend, "GET;2"),
+		msg(60*time.Millisecond, DirectionReceive, "page2"),
+	}
+}
+
+// replayRoundTrip sends a request and returns the first response other than
+// "ignored".
+func replayRoundTrip(ctx context.Context, tr *Transport, req string) (string, error) {
+	var response string
+
+	err := tr.RoundTrip(ctx, req, func(payload []byte) error {
+		if string(payload) == "ignored" {
+			return ErrIgnore
+		}
+
+		response = string(payload)
+
+		return nil
+	})
+
+	return response, err
+}
+
+func TestReplay(t *testing.T) {
+	type exchange struct {
+		req, want string
+	}
+
+	for _, tc := range []struct {
+		name      string
+		opts      ReplayOptions
+		exchanges []exchange
+		mismatch  string
+	}{
+		{
+			name: "strict",
+			exchanges: []exchange{
+				{"LOGIN;1234", "nav"},
+				{"GET;1", "page1"},
+				{"GET;2", "page2"},
+			},
+			mismatch: "GET;1",
+		},
+		{
+			name: "strict out of order",
+			exchanges: []exchange{
+				{"LOGIN;", "nav"},
+			},
+			mismatch: "GET;2",
+		},
+		{
+			name: "loose",
+			opts: ReplayOptions{Loose: true},
+			exchanges: []exchange{
+				{"LOGIN;", "nav"},
+				{"GET;2", "page2"},
+				{"GET;1", "page1"},
+				{"GET;2", "page2"},
+				{"GET;1", "page1"},
+			},
+			mismatch: "GET;3",
+		},
+		{
+			name: "timing",
+			opts: ReplayOptions{Timing: true},
+			exchanges: []exchange{
+				{"LOGIN;", "nav"},
+				{"GET;1", "page1"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			t.Cleanup(cancel)
+
+			tr := Replay(testRecording(), tc.opts, WithLogFunc(t.Logf))
+			t.Cleanup(func() {
+				tr.Close()
+			})
+
+			start := time.Now()
+
+			for _, ex := range tc.exchanges {
+				if got, err := replayRoundTrip(ctx, tr, ex.req); err != nil {
+					t.Errorf("RoundTrip(%q) failed: %v", ex.req, err)
+				} else if got != ex.want {
+					t.Errorf("RoundTrip(%q) returned %q, want %q", ex.req, got, ex.want)
+				}
+			}
+
+			if tc.opts.Timing {
+				// 10ms for the login and 20ms for the page
+				if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+					t.Errorf("Replay took %v, want at least 30ms", elapsed)
+				}
+			}
+
+			if tc.mismatch != "" {
+				if _, err := replayRoundTrip(ctx, tr, tc.mismatch); !errors.Is(err, ErrReplayMismatch) {
+					t.Errorf("RoundTrip(%q) returned %v, want mismatch", tc.mismatch, err)
+				}
+			}
+		})
+	}
+}
+
+func TestReplayPassword(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+
+	recording := []Message{
+		{Direction: DirectionSend, Type: websocket.TextMessage, Payload: []byte("LOGIN;1234")},
+		{Direction: DirectionReceive, Type: websocket.TextMessage, Payload: []byte("nav")},
+	}
+
+	tr := Replay(recording, ReplayOptions{Loose: true})
+	t.Cleanup(func() {
+		tr.Close()
+	})
+
+	if _, err := replayRoundTrip(ctx, tr, "LOGIN;999"); !errors.Is(err, ErrReplayMismatch) {
+		t.Errorf("Login with wrong password returned %v", err)
+	}
+
+	if got, err := replayRoundTrip(ctx, tr, "LOGIN;1234"); err != nil || got != "nav" {
+		t.Errorf("Login returned %q, %v", got, err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"net"
 	"net/url"
 	"runtime"
+	"strings"
 	"sync"
 	"time"
 
@@ -39,6 +40,58 @@ func WithLogFunc(logf LogFunc) Option {
 	}
 }
 
+// Hooks receives notifications about transport activity, e.g. for collecting
+// metrics. All functions are optional and called synchronously; they must not
+// block.
+type Hooks struct {
+	// Dialed is called after a connection attempt.
+	Dialed func(d time.Duration, err error)
+
+	// RoundTripDone is called when a round trip finishes. The command is the
+	// request up to the first semicolon (e.g. "GET"); arguments such as
+	// passwords are never included.
+	RoundTripDone func(command string, d time.Duration, err error)
+
+	// MessageSent is called with the size of every message sent.
+	MessageSent func(size int)
+
+	// MessageReceived is called with the size of every message received.
+	MessageReceived func(size int)
+}
+
+// WithHooks installs functions notified about transport activity.
+func WithHooks(h Hooks) Option {
+	return func(t *transport) {
+		t.hooks = h
+	}
+}
+
+func (h *Hooks) dialed(d time.Duration, err error) {
+	if h.Dialed != nil {
+		h.Dialed(d, err)
+	}
+}
+
+func (h *Hooks) roundTripDone(req string, d time.Duration, err error) {
+	if h.RoundTripDone != nil {
+		command, _, _ := strings.Cut(req, ";")
+
+		h.RoundTripDone(command, d, err)
+	}
+}
+
+func (h *Hooks) messageSent(size int) {
+	if h.MessageSent != nil {
+		h.MessageSent(size)
+	}
+}
+
+func (h *Hooks) messageReceived(size int) {
+	if h.MessageReceived != nil {
+		h.MessageReceived(size)
+	}
+}
+
 type websocketConn interface {
 	LocalAddr() net.Addr
 	RemoteAddr() net.Addr
@@ -58,7 +111,9 @@ type Transport struct {
 }
 
 type transport struct {
-	logf LogFunc
+	logf     LogFunc
+	hooks    Hooks
+	recorder *Recorder
 
 	mu       sync.Mutex
 	ws       websocketConn
@@ -67,9 +122,8 @@ type transport struct {
 	handler  *responseHandler
 }
 
-func newTransport(ws websocketConn, opts []Option) *Transport {
+func newUnconnectedTransport(opts []Option) *transport {
 	t := &transport{
-		ws:       ws,
 		recvDone: make(chan struct{}),
 		logf:     func(string, ...any) {},
 	}
@@ -78,9 +132,24 @@ func newTransport(ws websocketConn, opts []Option) *Transport {
 		opt(t)
 	}
 
+	return t
+}
+
+func newTransport(ws websocketConn, opts []Option) *Transport {
+	return newUnconnectedTransport(opts).start(ws)
+}
+
+// start begins using the given connection.
+func (t *transport) start(ws websocketConn) *Transport {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
+	if t.recorder != nil {
+		ws = &recordingConn{ws, t.recorder}
+	}
+
+	t.ws = ws
+
 	wrapper := &Transport{t}
 
 	// Launch asynchronous receiver to keep processing incoming messages (e.g.
@@ -107,12 +176,17 @@ func Dial(ctx context.Context, address string, opts ...Option) (*Transport, erro
 	dialer.HandshakeTimeout = 30 * time.Second
 	dialer.Subprotocols = append(dialer.Subprotocols, "Lux_WS")
 
+	t := newUnconnectedTransport(opts)
+
+	start := time.Now()
 	ws, _, err := dialer.DialContext(ctx, url.String(), nil)
+	t.hooks.dialed(time.Since(start), err)
+
 	if err != nil {
 		return nil, err
 	}
 
-	return newTransport(ws, opts), nil
+	return t.start(ws), nil
 }
 
 // LocalAddr returns the local network address.
@@ -172,6 +246,7 @@ func (t *transport) receiverLoop() error {
 		}
 
 		t.logf("Received message of type %v: %q", messageType, payload)
+		t.hooks.messageReceived(len(payload))
 
 		if messageType == websocket.TextMessage && len(payload) > 0 {
 			t.mu.Lock()
@@ -202,6 +277,8 @@ func (t *transport) writeMessage(ctx context.Context, cmd string) error {
 		return err
 	}
 
+	t.hooks.messageSent(len(cmd))
+
 	return nil
 }
 
@@ -255,5 +332,46 @@ func (t *transport) roundTrip(ctx context.Context, req string, handler *response
 // acceptable, but not an error, ErrIgnore can be returned by the handler. In
 // all other cases an error must be returned.
 func (t *transport) RoundTrip(ctx context.Context, req string, fn ResponseHandlerFunc) error {
-	return t.roundTrip(ctx, req, newResponseHandler(fn))
+	start := time.Now()
+	err := t.roundTrip(ctx, req, newResponseHandler(fn))
+	t.hooks.roundTripDone(req, time.Since(start), err)
+
+	return err
+}
+
+// Send sends a request as a single message without waiting for a response.
+// It's meant for commands not answered by the server. ErrBusy is returned
+// while a round trip is in progress.
+func (t *transport) Send(ctx context.Context, req string) error {
+	var err error
+
+	// Messages received while sending are ignored
+	handler := newResponseHandler(func([]byte) error {
+		return ErrIgnore
+	})
+
+	t.mu.Lock()
+	select {
+	case <-t.recvDone:
+		err = t.recvErr
+	default:
+		if t.handler == nil {
+			t.handler = handler
+		} else {
+			err = ErrBusy
+		}
+	}
+	t.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	err = t.writeMessage(ctx, req)
+
+	t.mu.Lock()
+	t.handler = nil
+	t.mu.Unlock()
+
+	return err
 }
@@ -314,3 +314,122 @@ func TestRoundTrip(t *testing.T) {
 		t.Errorf("RoundTrip() failed: %v", err)
 	}
 }
+
+func TestHooks(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+
+	var mu sync.Mutex
+	var commands []string
+	var sent, received int
+
+	fc := newFakeConn(t)
+	tr := newTransport(fc, []Option{
+		WithHooks(Hooks{
+			RoundTripDone: func(command string, d time.Duration, err error) {
+				mu.Lock()
+				defer mu.Unlock()
+
+				if d < 0 {
+					t.Errorf("Negative duration %v", d)
+				}
+
+				commands = append(commands, command)
+			},
+			MessageSent: func(size int) {
+				mu.Lock()
+				defer mu.Unlock()
+
+				sent += size
+			},
+			MessageReceived: func(size int) {
+				mu.Lock()
+				defer mu.Unlock()
+
+				received += size
+			},
+		}),
+	})
+
+	t.Cleanup(func() {
+		tr.Close()
+	})
+
+	fc.handleWrite = func(payload []byte, out chan<- cannedMessage) error {
+		out <- cannedMessage{
+			messageType: websocket.TextMessage,
+			payload:     []byte("response"),
+		}
+
+		return nil
+	}
+
+	for _, req := range []string{"LOGIN;secret", "GET;0x1234"} {
+		if err := tr.RoundTrip(ctx, req, func([]byte) error { return nil }); err != nil {
+			t.Errorf("RoundTrip(%q) failed: %v", req, err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if want := []string{"LOGIN", "GET"}; strings.Join(commands, ",") != strings.Join(want, ",") {
+		t.Errorf("Round trip commands %q, want %q", commands, want)
+	}
+
+	if want := len("LOGIN;secret") + len("GET;0x1234"); sent != want {
+		t.Errorf("Sent %d bytes, want %d", sent, want)
+	}
+
+	if want := 2 * len("response"); received != want {
+		t.Errorf("Received %d bytes, want %d", received, want)
+	}
+}
+
+func TestSend(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+
+	fc, tr := newFakeTransport(t)
+
+	var sent []string
+
+	fc.handleWrite = func(payload []byte, out chan<- cannedMessage) error {
+		sent = append(sent, string(payload))
+
+		if string(payload) == "GET" {
+			out <- cannedMessage{
+				messageType: websocket.TextMessage,
+				payload:     []byte("content"),
+			}
+		}
+
+		return nil
+	}
+
+	if err := tr.Send(ctx, "SET;1"); err != nil {
+		t.Errorf("Send() failed: %v", err)
+	}
+
+	if err := tr.RoundTrip(ctx, "GET", func(payload []byte) error {
+		if resp := string(payload); resp != "content" {
+			t.Errorf("Unexpected response %q", resp)
+		}
+
+		return nil
+	}); err != nil {
+		t.Errorf("RoundTrip() failed: %v", err)
+	}
+
+	fc.mu.Lock()
+	if got := strings.Join(sent, ","); got != "SET;1,GET" {
+		t.Errorf("Sent messages %q, want %q", got, "SET;1,GET")
+	}
+	fc.mu.Unlock()
+
+	tr.Close()
+
+	if err := tr.Send(ctx, "SET;2"); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Send() after Close() returned %v", err)
+	}
+}
@@ -5,8 +5,10 @@ import (
 	"context"
 	"encoding/xml"
 	"errors"
+	"fmt"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/hansmi/wp2reg-luxws/luxws"
 	"golang.org/x/net/html/charset"
@@ -50,6 +52,7 @@ func String(s string) *string {
 
 type transport interface {
 	RoundTrip(context.Context, string, luxws.ResponseHandlerFunc) error
+	Send(context.Context, string) error
 	Close() error
 }
 
@@ -66,10 +69,60 @@ func WithLogFunc(logf LogFunc) Option {
 	}
 }
 
+// WithTransportOptions supplies additional options for the underlying
+// transport, e.g. luxws.WithHooks.
+func WithTransportOptions(opts ...luxws.Option) Option {
+	return func(c *Client) {
+		c.transportOpts = append(c.transportOpts, opts...)
+	}
+}
+
+// WithDecodeHook supplies a function called with the time spent decoding
+// each received XML document.
+func WithDecodeHook(fn func(time.Duration)) Option {
+	return func(c *Client) {
+		c.decodeHook = fn
+	}
+}
+
+// WithResponseHook supplies a function called with every received response
+// document and the name of the expected root element (e.g. "content"). The
+// hook is called before decoding, i.e. also for documents which fail to
+// decode.
+func WithResponseHook(fn func(kind string, payload []byte)) Option {
+	return func(c *Client) {
+		c.responseHook = fn
+	}
+}
+
 // Client is a wrapper around an underlying LuxWS connection.
 type Client struct {
-	logf LogFunc
-	t    transport
+	logf          LogFunc
+	transportOpts []luxws.Option
+	decodeHook    func(time.Duration)
+	responseHook  func(string, []byte)
+	t             transport
+}
+
+func newClient(opts []Option) *Client {
+	c := &Client{
+		logf: func(string, ...any) {},
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// New returns a client using an existing transport, e.g. one replaying
+// a recorded session (see luxws.Replay). Transport options are ignored.
+func New(t *luxws.Transport, opts ...Option) *Client {
+	c := newClient(opts)
+	c.t = t
+
+	return c
 }
 
 // Dial connects to a LuxWS server. The address must have the format
@@ -80,15 +133,13 @@ type Client struct {
 func Dial(ctx context.Context, address string, opts ...Option) (*Client, error) {
 	var err error
 
-	c := &Client{
-		logf: func(string, ...any) {},
-	}
+	c := newClient(opts)
 
-	for _, opt := range opts {
-		opt(c)
-	}
+	transportOpts := append([]luxws.Option{
+		luxws.WithLogFunc(luxws.LogFunc(c.logf)),
+	}, c.transportOpts...)
 
-	if c.t, err = luxws.Dial(ctx, address, luxws.WithLogFunc(luxws.LogFunc(c.logf))); err != nil {
+	if c.t, err = luxws.Dial(ctx, address, transportOpts...); err != nil {
 		return nil, err
 	}
 
@@ -100,12 +151,28 @@ func (c *Client) Close() error {
 	return c.t.Close()
 }
 
+// unmarshal decodes a response and reports to the hooks.
+func (c *Client) unmarshal(data []byte, v any, wantLocalName string) error {
+	if c.responseHook != nil {
+		c.responseHook(wantLocalName, data)
+	}
+
+	start := time.Now()
+	err := responseUnmarshal(data, v, wantLocalName)
+
+	if c.decodeHook != nil {
+		c.decodeHook(time.Since(start))
+	}
+
+	return err
+}
+
 // Login sends a "LOGIN" command. The navigation structure is returned.
 func (c *Client) Login(ctx context.Context, password string) (*NavRoot, error) {
 	var result NavRoot
 
 	return &result, c.t.RoundTrip(ctx, "LOGIN;"+password, func(payload []byte) error {
-		return responseUnmarshal(payload, &result, "navigation")
+		return c.unmarshal(payload, &result, "navigation")
 	})
 }
 
@@ -114,6 +181,22 @@ func (c *Client) Get(ctx context.Context, id string) (*ContentRoot, error) {
 	var result ContentRoot
 
 	return &result, c.t.RoundTrip(ctx, "GET;"+id, func(payload []byte) error {
-		return responseUnmarshal(payload, &result, "content")
+		return c.unmarshal(payload, &result, "content")
 	})
 }
+
+// Set sends a "SET" command changing the value of a content item followed by
+// a "SAVE" command to apply the change, the same as the web interface. The
+// server doesn't confirm either command; retrieve the page again to verify
+// the new value. The item ID must be from the most recently retrieved page.
+func (c *Client) Set(ctx context.Context, id, value string) error {
+	if strings.ContainsAny(id+value, ";\r\n") {
+		return fmt.Errorf("invalid characters in item ID %q or value %q", id, value)
+	}
+
+	if err := c.t.Send(ctx, "SET;set_"+id+";"+value); err != nil {
+		return err
+	}
+
+	return c.t.Send(ctx, "SAVE;1")
+}
@@ -24,7 +24,7 @@ func TestResponseUnmarshalIgnore(t *testing.T) {
 	}
 }
 
-func newTestClient(t *testing.T, handleRoundTrip func(string) (string, error)) *Client {
+func newTestClient(t *testing.T, handleRoundTrip func(string) (string, error), opts ...Option) *Client {
 	var upgrader websocket.Upgrader
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -66,7 +66,7 @@ func newTestClient(t *testing.T, handleRoundTrip func(string) (string, error)) *
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	t.Cleanup(cancel)
 
-	c, err := Dial(ctx, serverURL.Host, WithLogFunc(t.Logf))
+	c, err := Dial(ctx, serverURL.Host, append([]Option{WithLogFunc(t.Logf)}, opts...)...)
 	if err != nil {
 		t.Fatalf("Dial(%q) failed: %v", serverURL.Host, err)
 	}
@@ -284,3 +284,111 @@ func TestGet(t *testing.T) {
 		})
 	}
 }
+
+func TestHooks(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+
+	var dialed, decoded int
+	var commands, responses []string
+
+	c := newTestClient(t, func(string) (string, error) {
+		return `<Navigation id="0x1"></Navigation>`, nil
+	},
+		WithTransportOptions(luxws.WithHooks(luxws.Hooks{
+			Dialed: func(_ time.Duration, err error) {
+				if err != nil {
+					t.Errorf("Dialing failed: %v", err)
+				}
+
+				dialed++
+			},
+			RoundTripDone: func(command string, _ time.Duration, _ error) {
+				commands = append(commands, command)
+			},
+		})),
+		WithDecodeHook(func(time.Duration) {
+			decoded++
+		}),
+		WithResponseHook(func(kind string, payload []byte) {
+			responses = append(responses, kind+": "+string(payload))
+		}),
+	)
+
+	if _, err := c.Login(ctx, "1234"); err != nil {
+		t.Errorf("Login() failed: %v", err)
+	}
+
+	if dialed != 1 {
+		t.Errorf("Dial hook called %d times, want 1", dialed)
+	}
+
+	if decoded != 1 {
+		t.Errorf("Decode hook called %d times, want 1", decoded)
+	}
+
+	if diff := cmp.Diff([]string{"LOGIN"}, commands); diff != "" {
+		t.Errorf("Round trip commands difference (-want +got):\n%s", diff)
+	}
+
+	if diff := cmp.Diff([]string{`navigation: <Navigation id="0x1"></Navigation>`}, responses); diff != "" {
+		t.Errorf("Responses difference (-want +got):\n%s", diff)
+	}
+}
+
+func TestResponseHookDecodeFailure(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+
+	var responses []string
+
+	c := newTestClient(t, func(string) (string, error) {
+		return `<Content><item`, nil
+	},
+		WithResponseHook(func(kind string, payload []byte) {
+			responses = append(responses, kind+": "+string(payload))
+		}),
+	)
+
+	if _, err := c.Get(ctx, "0x1"); err == nil {
+		t.Errorf("Get() didn't fail")
+	}
+
+	if diff := cmp.Diff([]string{`content: <Content><item`}, responses); diff != "" {
+		t.Errorf("Responses difference (-want +got):\n%s", diff)
+	}
+}
+
+func TestSet(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+
+	var requests []string
+
+	c := newTestClient(t, func(req string) (string, error) {
+		requests = append(requests, req)
+
+		if req == "GET;0x2" {
+			return `<Content><item id="0x3"><name>Mode</name><value>Auto</value></item></Content>`, nil
+		}
+
+		return "", nil
+	})
+
+	if err := c.Set(ctx, "0x3", "1"); err != nil {
+		t.Errorf("Set() failed: %v", err)
+	}
+
+	// Wait for server to process all messages
+	if _, err := c.Get(ctx, "0x2"); err != nil {
+		t.Errorf("Get() failed: %v", err)
+	}
+
+	if diff := cmp.Diff([]string{"SET;set_0x3;1", "SAVE;1", "GET;0x2"}, requests); diff != "" {
+		t.Errorf("Requests difference (-want +got):\n%s", diff)
+	}
+
+	if err := c.Set(ctx, "0x3", "1;SAVE;1"); err == nil {
+		t.Errorf("Set() accepted value with separator")
+	}
+}
@@ -0,0 +1,82 @@
+package luxwsclient
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hansmi/wp2reg-luxws/luxws"
+)
+
+func FuzzResponseUnmarshal(f *testing.F) {
+	for _, s := range []string{
+		"<valid></valid>",
+		`<Navigation id="0x1"></Navigation>`,
+		`<Navigation id="0x41c123c8"><item id="0x41123678"><name>Test</name></item></Navigation>`,
+		`<Content><item id="0x3"><name>Mode</name><value>Auto</value></item></Content>`,
+		`<?xml version="1.0" encoding="ISO-8859-1"?><content><item id="0x1"><name>A</name><option value="0">x</option></item></content>`,
+	} {
+		f.Add([]byte(s))
+	}
+
+	paths, err := filepath.Glob(filepath.Join("testdata", "*.jsonl"))
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	for _, path := range paths {
+		fh, err := os.Open(path)
+		if err != nil {
+			f.Fatal(err)
+		}
+
+		recording, err := luxws.ReadRecording(fh)
+		fh.Close()
+
+		if err != nil {
+			f.Fatalf("Reading %s failed: %v", path, err)
+		}
+
+		for _, msg := range recording {
+			if msg.Direction == luxws.DirectionReceive {
+				f.Add(msg.Payload)
+			}
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var nav NavRoot
+
+		switch err := responseUnmarshal(data, &nav, "navigation"); {
+		case err == nil:
+			if got := strings.ToLower(nav.XMLName.Local); got != "navigation" {
+				t.Errorf("responseUnmarshal() accepted element %q", got)
+			}
+
+			nav.FindByName("")
+
+		case errors.Is(err, luxws.ErrIgnore):
+			if nav.XMLName.Local != "" || nav.Items != nil {
+				t.Errorf("responseUnmarshal() modified ignored value: %+v", nav)
+			}
+		}
+
+		var content ContentRoot
+
+		switch err := responseUnmarshal(data, &content, "content"); {
+		case err == nil:
+			if got := strings.ToLower(content.XMLName.Local); got != "content" {
+				t.Errorf("responseUnmarshal() accepted element %q", got)
+			}
+
+			content.FindByName("")
+
+		case errors.Is(err, luxws.ErrIgnore):
+			if content.XMLName.Local != "" || content.Items != nil {
+				t.Errorf("responseUnmarshal() modified ignored value: %+v", content)
+			}
+		}
+	})
+}
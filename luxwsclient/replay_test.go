@@ -0,0 +1,67 @@
+package luxwsclient
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hansmi/wp2reg-luxws/luxws"
+)
+
+func newReplayClient(t *testing.T, name string) *Client {
+	t.Helper()
+
+	fh, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer fh.Close()
+
+	recording, err := luxws.ReadRecording(fh)
+	if err != nil {
+		t.Fatalf("Reading recording failed: %v", err)
+	}
+
+	c := New(luxws.Replay(recording, luxws.ReplayOptions{}, luxws.WithLogFunc(t.Logf)))
+
+	t.Cleanup(func() {
+		c.Close()
+	})
+
+	return c
+}
+
+// Firmware sending content in ISO-8859-1, preceded by an unsolicited update
+// of values.
+func TestReplayLatin1(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+
+	c := newReplayClient(t, "latin1-session.jsonl")
+
+	nav, err := c.Login(ctx, "")
+	if err != nil {
+		t.Fatalf("Login() failed: %v", err)
+	}
+
+	page := nav.FindByName("Temperaturen")
+	if page == nil {
+		t.Fatalf("Page not found in %+v", nav)
+	}
+
+	got, err := c.Get(ctx, page.ID)
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+
+	if diff := cmp.Diff([]ContentItem{
+		{ID: "0x45e5a0", Name: "Vorlauf", Value: String("28.4°C")},
+		{ID: "0x45e5d8", Name: "Rücklauf", Value: String("25.1°C")},
+	}, got.Items); diff != "" {
+		t.Errorf("Content difference (-want +got):\n%s", diff)
+	}
+}
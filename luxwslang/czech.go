@@ -20,6 +20,11 @@ var Czech = &Terminology{
 	NavErrorMemory:  "Chybová paměť",
 	NavSwitchOffs:   "Odepnutí",
 
+	TemperatureFlow:    "Výstup",
+	TemperatureReturn:  "Zpátečka",
+	InputFlowRate:      "Průtok",
+	OutputDefrostValve: "Odtávací ventil",
+
 	NavOpHours:      "Provozní hodiny",
 	HoursImpulsesRe: regexp.MustCompile(`^Počet startů\s`),
 
@@ -28,6 +33,18 @@ var Czech = &Terminology{
 	StatusSoftwareVersion: "Softwarová verze",
 	StatusOperationMode:   "Provozní stav",
 	StatusPowerOutput:     "Výkon",
+	StatusPowerInput:      "Příkon",
+
+	OperationModes: operationModePatterns(map[OperationMode]string{
+		OperationModeHeating:         `Topení`,
+		OperationModeHotWater:        `Teplá voda|TUV`,
+		OperationModeSwimmingPool:    `Bazén(?: ?/ ?Fotovoltaika)?`,
+		OperationModeEVUBlock:        `EVU|Blokace EVU|Blokování EVU`,
+		OperationModeDefrost:         `Odtávání|Odmrazování`,
+		OperationModeOff:             `Bez požadavku|Vypnuto`,
+		OperationModeHeatingExternal: `Topení ext\.? ?(?:zdroj|energie)`,
+		OperationModeCooling:         `Chlazení`,
+	}),
 
 	BoolFalse: "Vypnuto",
 	BoolTrue:  "Zapnuto",
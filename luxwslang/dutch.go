@@ -18,6 +18,11 @@ var Dutch = &Terminology{
 	NavErrorMemory:  "Storingsbuffer",
 	NavSwitchOffs:   "Afschakelingen",
 
+	TemperatureFlow:    "Aanvoer",
+	TemperatureReturn:  "Retour",
+	InputFlowRate:      "Debiet",
+	OutputDefrostValve: "Ontdooiklep",
+
 	NavOpHours:      "Bedrijfsuren",
 	HoursImpulsesRe: regexp.MustCompile(`^impulse\s`),
 
@@ -26,6 +31,18 @@ var Dutch = &Terminology{
 	StatusSoftwareVersion: "Softwareversie",
 	StatusOperationMode:   "Bedrijfstoestand",
 	StatusPowerOutput:     "Vermogen",
+	StatusPowerInput:      "Opgenomen vermogen",
+
+	OperationModes: operationModePatterns(map[OperationMode]string{
+		OperationModeHeating:         `Verwarmen|Verwarming`,
+		OperationModeHotWater:        `Warm water|Warmwater|Tapwater`,
+		OperationModeSwimmingPool:    `Zwembad(?: ?/ ?(?:PV|Fotovoltaïek))?`,
+		OperationModeEVUBlock:        `EVU|EVU[- ]blokkering|Blokkering`,
+		OperationModeDefrost:         `Ontdooien`,
+		OperationModeOff:             `Geen vraag|Geen aanvraag|Uit`,
+		OperationModeHeatingExternal: `Verwarmen ext\.? ?(?:bron|energiebron)`,
+		OperationModeCooling:         `Koelen|Koeling`,
+	}),
 
 	BoolFalse: "Uit",
 	BoolTrue:  "Aan",
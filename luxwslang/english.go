@@ -18,6 +18,11 @@ var English = &Terminology{
 	NavErrorMemory:  "error memory",
 	NavSwitchOffs:   "switch offs",
 
+	TemperatureFlow:    "flow",
+	TemperatureReturn:  "return",
+	InputFlowRate:      "flow rate",
+	OutputDefrostValve: "defrost valve",
+
 	NavOpHours:      "operating hours",
 	HoursImpulsesRe: regexp.MustCompile(`^impulse\s`),
 
@@ -26,6 +31,18 @@ var English = &Terminology{
 	StatusSoftwareVersion: "software version",
 	StatusOperationMode:   "operation mode",
 	StatusPowerOutput:     "actual capacity",
+	StatusPowerInput:      "power consumption",
+
+	OperationModes: operationModePatterns(map[OperationMode]string{
+		OperationModeHeating:         `heating(?: mode)?`,
+		OperationModeHotWater:        `(?:domestic )?hot water|DHW`,
+		OperationModeSwimmingPool:    `swimming pool(?: ?/ ?(?:photovoltaic|solar))?|pool`,
+		OperationModeEVUBlock:        `EVU|(?:utility|provider) (?:lock|block)`,
+		OperationModeDefrost:         `defrost(?:ing)?`,
+		OperationModeOff:             `no request|no demand|off|ready`,
+		OperationModeHeatingExternal: `heating ext\.? ?(?:en\.?|energy source|source)`,
+		OperationModeCooling:         `cooling`,
+	}),
 
 	BoolFalse: "off",
 	BoolTrue:  "on",
@@ -18,6 +18,11 @@ var Finnish = &Terminology{
 	NavErrorMemory:  "Häiriöloki",
 	NavSwitchOffs:   "Pysähtymistieto",
 
+	TemperatureFlow:    "Meno",
+	TemperatureReturn:  "Paluu",
+	InputFlowRate:      "Virtaama",
+	OutputDefrostValve: "Sulatusventtiili",
+
 	NavOpHours:      "Käyttötunnit",
 	HoursImpulsesRe: regexp.MustCompile(`^impulse\s`),
 
@@ -26,6 +31,18 @@ var Finnish = &Terminology{
 	StatusSoftwareVersion: "Ohjelmaversio",
 	StatusOperationMode:   "Toimintatila",
 	StatusPowerOutput:     "Kapasiteetti",
+	StatusPowerInput:      "Ottoteho",
+
+	OperationModes: operationModePatterns(map[OperationMode]string{
+		OperationModeHeating:         `Lämmitys`,
+		OperationModeHotWater:        `Käyttövesi|Lämmin käyttövesi`,
+		OperationModeSwimmingPool:    `Uima-allas(?: ?/ ?Aurinkosähkö)?`,
+		OperationModeEVUBlock:        `EVU|EVU-esto|Sähkökatko`,
+		OperationModeDefrost:         `Sulatus`,
+		OperationModeOff:             `Ei tarvetta|Ei pyyntöä|Pois`,
+		OperationModeHeatingExternal: `Lämmitys ulk\.? ?(?:lähde|energialähde)`,
+		OperationModeCooling:         `Jäähdytys`,
+	}),
 
 	BoolFalse: "Pois",
 	BoolTrue:  "On",
@@ -0,0 +1,101 @@
+package luxwslang
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func FuzzParseTimestamp(f *testing.F) {
+	for _, s := range []string{
+		"03.02.18 12:34:56",
+		"31.12.99 23:59:59",
+		"29.02.20 00:00:00",
+		"",
+	} {
+		f.Add(s)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		for _, terms := range All() {
+			got, err := terms.ParseTimestamp(input, time.UTC)
+			if err != nil {
+				continue
+			}
+
+			// The standard library accepts fractional seconds even if the
+			// format doesn't contain them.
+			got = got.Truncate(time.Second)
+
+			formatted := got.Format(terms.timestampFormat)
+
+			if again, err := terms.ParseTimestamp(formatted, time.UTC); err != nil {
+				t.Errorf("ParseTimestamp(%q) failed after round trip of %q: %v", formatted, input, err)
+			} else if !again.Equal(got) {
+				t.Errorf("ParseTimestamp(%q) = %v, want %v", formatted, again, got)
+			}
+		}
+	})
+}
+
+// formatDuration formats a duration like the controller, e.g. "-1:2:3".
+func formatDuration(d time.Duration) string {
+	sign := ""
+
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+
+	return fmt.Sprintf("%s%d:%d:%d", sign, int64(d/time.Hour), int64(d/time.Minute%60), int64(d/time.Second%60))
+}
+
+func FuzzParseDuration(f *testing.F) {
+	for _, tc := range parseDurationTests {
+		f.Add(tc.input)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		for _, terms := range All() {
+			got, err := terms.ParseDuration(input)
+			if err != nil {
+				continue
+			}
+
+			// Only input with a leading minus sign is negative
+			if negative := strings.HasPrefix(strings.TrimSpace(input), "-"); got < 0 && !negative {
+				t.Errorf("ParseDuration(%q) = %v, want non-negative duration", input, got)
+			} else if got > 0 && negative {
+				t.Errorf("ParseDuration(%q) = %v, want non-positive duration", input, got)
+			}
+
+			formatted := formatDuration(got)
+
+			if again, err := terms.ParseDuration(formatted); err != nil {
+				t.Errorf("ParseDuration(%q) failed after round trip of %q: %v", formatted, input, err)
+			} else if again != got {
+				t.Errorf("ParseDuration(%q) = %v, want %v", formatted, again, got)
+			}
+		}
+	})
+}
+
+func FuzzParseMeasurement(f *testing.F) {
+	for _, tc := range parseMeasurementTests {
+		f.Add(tc.input)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		for _, terms := range All() {
+			value, _, err := terms.ParseMeasurement(input)
+			if err != nil {
+				continue
+			}
+
+			if !IsFinite(value) {
+				t.Errorf("ParseMeasurement(%q) = %v, want finite value", input, value)
+			}
+		}
+	})
+}
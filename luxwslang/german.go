@@ -20,6 +20,11 @@ var German = &Terminology{
 	NavErrorMemory:  "Fehlerspeicher",
 	NavSwitchOffs:   "Abschaltungen",
 
+	TemperatureFlow:    "Vorlauf",
+	TemperatureReturn:  "Rücklauf",
+	InputFlowRate:      "Durchfluss",
+	OutputDefrostValve: "Abtauventil",
+
 	NavOpHours:      "Betriebsstunden",
 	HoursImpulsesRe: regexp.MustCompile(`^Impulse\s`),
 
@@ -28,6 +33,18 @@ var German = &Terminology{
 	StatusSoftwareVersion: "Softwarestand",
 	StatusOperationMode:   "Betriebszustand",
 	StatusPowerOutput:     "Leistung Ist",
+	StatusPowerInput:      "Leistungsaufnahme",
+
+	OperationModes: operationModePatterns(map[OperationMode]string{
+		OperationModeHeating:         `Heizen|Heizbetrieb`,
+		OperationModeHotWater:        `Warmwasser|Brauchwasser|WW`,
+		OperationModeSwimmingPool:    `Schwimmbad(?: ?/ ?Photovoltaik)?|Photovoltaik`,
+		OperationModeEVUBlock:        `EVU(?:[- ]Sperre)?`,
+		OperationModeDefrost:         `Abtauen`,
+		OperationModeOff:             `Keine Anforderung|Aus|Bereit`,
+		OperationModeHeatingExternal: `Heizen ext\.? ?(?:En\.?|Energiequelle)`,
+		OperationModeCooling:         `Kühlen|Kühlbetrieb`,
+	}),
 
 	BoolFalse: "Aus",
 	BoolTrue:  "Ein",
@@ -0,0 +1,88 @@
+package luxwslang
+
+import (
+	"regexp"
+	"strings"
+)
+
+// OperationMode is a language-independent operation mode of a heat pump. The
+// numeric values are stable and match the codes used by the Luxtronik
+// controller firmware.
+type OperationMode int
+
+const (
+	// OperationModeUnknown is returned for unrecognized modes.
+	OperationModeUnknown OperationMode = -1
+
+	OperationModeHeating         OperationMode = 0
+	OperationModeHotWater        OperationMode = 1
+	OperationModeSwimmingPool    OperationMode = 2
+	OperationModeEVUBlock        OperationMode = 3
+	OperationModeDefrost         OperationMode = 4
+	OperationModeOff             OperationMode = 5
+	OperationModeHeatingExternal OperationMode = 6
+	OperationModeCooling         OperationMode = 7
+)
+
+var operationModeNames = map[OperationMode]string{
+	OperationModeUnknown:         "unknown",
+	OperationModeHeating:         "heating",
+	OperationModeHotWater:        "hot_water",
+	OperationModeSwimmingPool:    "swimming_pool",
+	OperationModeEVUBlock:        "evu_block",
+	OperationModeDefrost:         "defrost",
+	OperationModeOff:             "off",
+	OperationModeHeatingExternal: "heating_external",
+	OperationModeCooling:         "cooling",
+}
+
+// String returns a stable, language-independent name for the mode (e.g.
+// "hot_water").
+func (m OperationMode) String() string {
+	if name, ok := operationModeNames[m]; ok {
+		return name
+	}
+
+	return operationModeNames[OperationModeUnknown]
+}
+
+// AllOperationModes returns all known operation modes ordered by their code.
+// OperationModeUnknown is not included.
+func AllOperationModes() []OperationMode {
+	return []OperationMode{
+		OperationModeHeating,
+		OperationModeHotWater,
+		OperationModeSwimmingPool,
+		OperationModeEVUBlock,
+		OperationModeDefrost,
+		OperationModeOff,
+		OperationModeHeatingExternal,
+		OperationModeCooling,
+	}
+}
+
+// operationModePatterns builds case-insensitive, anchored expressions.
+func operationModePatterns(patterns map[OperationMode]string) map[OperationMode]*regexp.Regexp {
+	result := make(map[OperationMode]*regexp.Regexp, len(patterns))
+
+	for mode, expr := range patterns {
+		result[mode] = regexp.MustCompile(`(?i)^(?:` + expr + `)$`)
+	}
+
+	return result
+}
+
+// ParseOperationMode maps the operation mode text shown by the controller
+// (e.g. "Warmwasser") to a language-independent mode. OperationModeUnknown is
+// returned if the text isn't recognized.
+func (t *Terminology) ParseOperationMode(text string) OperationMode {
+	text = strings.Join(strings.Fields(text), " ")
+
+	for _, mode := range AllOperationModes() {
+		if re := t.OperationModes[mode]; re != nil && re.MatchString(text) {
+			return mode
+		}
+	}
+
+	return OperationModeUnknown
+}
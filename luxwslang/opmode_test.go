@@ -0,0 +1,63 @@
+package luxwslang
+
+import (
+	"testing"
+)
+
+func TestOperationModeString(t *testing.T) {
+	seen := map[string]OperationMode{}
+
+	for idx, mode := range AllOperationModes() {
+		if int(mode) != idx {
+			t.Errorf("Operation mode %v has code %d, want %d", mode, int(mode), idx)
+		}
+
+		name := mode.String()
+
+		if name == OperationModeUnknown.String() {
+			t.Errorf("Operation mode %d has no name", int(mode))
+		} else if other, ok := seen[name]; ok {
+			t.Errorf("Operation modes %d and %d share name %q", int(mode), int(other), name)
+		}
+
+		seen[name] = mode
+	}
+
+	if got := OperationMode(100).String(); got != "unknown" {
+		t.Errorf("String() of invalid mode = %q, want %q", got, "unknown")
+	}
+}
+
+func TestParseOperationMode(t *testing.T) {
+	for _, tc := range []struct {
+		terms *Terminology
+		input string
+		want  OperationMode
+	}{
+		{terms: German, input: "", want: OperationModeUnknown},
+		{terms: German, input: "----", want: OperationModeUnknown},
+		{terms: German, input: "Heizen", want: OperationModeHeating},
+		{terms: German, input: "  heizbetrieb ", want: OperationModeHeating},
+		{terms: German, input: "Warmwasser", want: OperationModeHotWater},
+		{terms: German, input: "Schwimmbad / Photovoltaik", want: OperationModeSwimmingPool},
+		{terms: German, input: "EVU-Sperre", want: OperationModeEVUBlock},
+		{terms: German, input: "Abtauen", want: OperationModeDefrost},
+		{terms: German, input: "Keine Anforderung", want: OperationModeOff},
+		{terms: German, input: "Heizen ext. Energiequelle", want: OperationModeHeatingExternal},
+		{terms: German, input: "Kühlbetrieb", want: OperationModeCooling},
+		{terms: English, input: "hot water", want: OperationModeHotWater},
+		{terms: English, input: "heating", want: OperationModeHeating},
+		{terms: English, input: "heating ext. energy source", want: OperationModeHeatingExternal},
+		{terms: English, input: "no request", want: OperationModeOff},
+		{terms: English, input: "Heizen", want: OperationModeUnknown},
+		{terms: Czech, input: "Teplá voda", want: OperationModeHotWater},
+		{terms: Finnish, input: "Sulatus", want: OperationModeDefrost},
+		{terms: Dutch, input: "Koelen", want: OperationModeCooling},
+	} {
+		t.Run(tc.terms.ID+" "+tc.input, func(t *testing.T) {
+			if got := tc.terms.ParseOperationMode(tc.input); got != tc.want {
+				t.Errorf("ParseOperationMode(%q) = %v, want %v", tc.input, got, tc.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package luxwslang
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"regexp"
@@ -9,6 +10,10 @@ import (
 	"time"
 )
 
+// ErrUnknownUnit is returned by ParseMeasurement for values with an
+// unrecognized physical unit.
+var ErrUnknownUnit = errors.New("unrecognized unit")
+
 // Terminology describes the names and expressions used by a LuxWS-compatible
 // heat pump controller. Member functions allow for parsing of timestamps,
 // durations and measurements such as temperatures and pressures.
@@ -32,6 +37,13 @@ type Terminology struct {
 	NavErrorMemory  string
 	NavSwitchOffs   string
 
+	// Item names used for derived metrics.
+	TemperatureFlow   string
+	TemperatureReturn string
+	InputFlowRate     string
+
+	OutputDefrostValve string
+
 	NavOpHours      string
 	HoursImpulsesRe *regexp.Regexp
 
@@ -40,6 +52,11 @@ type Terminology struct {
 	StatusSoftwareVersion string
 	StatusOperationMode   string
 	StatusPowerOutput     string
+	StatusPowerInput      string
+
+	// Expressions matching the operation mode texts, see
+	// ParseOperationMode.
+	OperationModes map[OperationMode]*regexp.Regexp
 
 	BoolFalse string
 	BoolTrue  string
@@ -57,6 +74,14 @@ func (*Terminology) ParseDuration(v string) (time.Duration, error) {
 
 	v = strings.TrimSpace(v)
 
+	// The sign applies to the whole duration, e.g. "-0:30" is negative.
+	sign := ""
+
+	if rest, ok := strings.CutPrefix(v, "-"); ok {
+		sign = "-"
+		v = rest
+	}
+
 	if n, err := fmt.Sscanf(v, "%d:%d:%d\n", &hours, &minutes, &seconds); err == nil && n == 3 {
 	} else if n, err := fmt.Sscanf(v, "%d:%d\n", &hours, &minutes); err == nil && n == 2 {
 	} else if n, err := fmt.Sscanf(v, "%dh\n", &hours); err == nil && n == 1 {
@@ -65,8 +90,17 @@ func (*Terminology) ParseDuration(v string) (time.Duration, error) {
 		return math.MinInt64, fmt.Errorf("unrecognized duration format %q: %w", v, err)
 	}
 
+	if hours < 0 || minutes < 0 || seconds < 0 {
+		return math.MinInt64, fmt.Errorf("unrecognized duration format %q", sign+v)
+	}
+
 	// Let standard library deal with validation
-	return time.ParseDuration(fmt.Sprintf("%dh%dm%ds", hours, minutes, seconds))
+	return time.ParseDuration(fmt.Sprintf("%s%dh%dm%ds", sign, hours, minutes, seconds))
+}
+
+// IsFinite reports whether a parsed value is neither NaN nor infinite.
+func IsFinite(value float64) bool {
+	return !(math.IsNaN(value) || math.IsInf(value, 0))
 }
 
 // ParseMeasurement parses a string with a value and an optional physical unit
@@ -113,7 +147,11 @@ func (*Terminology) ParseMeasurement(text string) (float64, string, error) {
 				unit = "s"
 				value *= 60
 			default:
-				return 0, "", fmt.Errorf("unrecognized unit %q", unit)
+				return 0, "", fmt.Errorf("%w %q", ErrUnknownUnit, unit)
+			}
+
+			if !IsFinite(value) {
+				return 0, "", fmt.Errorf("measurement %q is not finite", text)
 			}
 
 			return value, unit, nil
@@ -122,7 +160,7 @@ func (*Terminology) ParseMeasurement(text string) (float64, string, error) {
 
 	// Heat pumps of type LD7 report a "Smart Grid" measurement which is
 	// a dimensionless enumeration.
-	if value, err := strconv.ParseFloat(text, 64); err == nil {
+	if value, err := strconv.ParseFloat(text, 64); err == nil && IsFinite(value) {
 		return value, "", nil
 	}
 
@@ -36,6 +36,13 @@ func TestComplete(t *testing.T) {
 					if val == nil {
 						err = errors.New("nil regexp")
 					}
+				case map[OperationMode]*regexp.Regexp:
+					for _, mode := range AllOperationModes() {
+						if val[mode] == nil {
+							err = fmt.Errorf("missing operation mode %v", mode)
+							break
+						}
+					}
 				default:
 					err = fmt.Errorf("unknown type %v", field.Type())
 				}
@@ -77,24 +84,28 @@ func TestParseTimestamp(t *testing.T) {
 	}
 }
 
+var parseDurationTests = []struct {
+	terms   *Terminology
+	input   string
+	want    string
+	wantErr bool
+}{
+	{terms: German, input: "1:2:3", want: "1h2m3s"},
+	{terms: German, input: "23:59", want: "23h59m"},
+	{terms: German, input: "12h", want: "12h"},
+	{terms: German, input: "-100h", want: "-100h"},
+	{terms: German, input: "  -23:1:2\n", want: "-23h1m2s"},
+	{terms: German, input: "-1:0:0", want: "-1h"},
+	{terms: German, input: "-100", want: "-100h"},
+	{terms: German, input: "123", want: "123h"},
+	{terms: German, input: "0:-1:0", wantErr: true},
+	{terms: German, input: "0:0:-1", wantErr: true},
+	{terms: German, input: "-0:30", want: "-30m"},
+	{terms: German, input: "--1", wantErr: true},
+}
+
 func TestParseDuration(t *testing.T) {
-	for _, tc := range []struct {
-		terms   *Terminology
-		input   string
-		want    string
-		wantErr bool
-	}{
-		{terms: German, input: "1:2:3", want: "1h2m3s"},
-		{terms: German, input: "23:59", want: "23h59m"},
-		{terms: German, input: "12h", want: "12h"},
-		{terms: German, input: "-100h", want: "-100h"},
-		{terms: German, input: "  -23:1:2\n", want: "-23h1m2s"},
-		{terms: German, input: "-1:0:0", want: "-1h"},
-		{terms: German, input: "-100", want: "-100h"},
-		{terms: German, input: "123", want: "123h"},
-		{terms: German, input: "0:-1:0", wantErr: true},
-		{terms: German, input: "0:0:-1", wantErr: true},
-	} {
+	for _, tc := range parseDurationTests {
 		t.Run(tc.terms.ID+" "+tc.input, func(t *testing.T) {
 			got, err := tc.terms.ParseDuration(tc.input)
 
@@ -113,40 +124,45 @@ func TestParseDuration(t *testing.T) {
 	}
 }
 
+var parseMeasurementTests = []struct {
+	terms    *Terminology
+	input    string
+	want     float64
+	wantUnit string
+	wantErr  bool
+}{
+	{terms: German, input: "", wantErr: true},
+	{terms: German, input: "1.23", want: 1.23},
+	{terms: German, input: "100m", wantErr: true},
+	{terms: German, input: "1l", wantErr: true},
+	{terms: German, input: "1.11°C", want: 1.11, wantUnit: "degC"},
+	{terms: German, input: "2.22 °C", want: 2.22, wantUnit: "degC"},
+	{terms: German, input: "90 K", want: 90, wantUnit: "K"},
+	{terms: German, input: "0.0 bar", want: 0, wantUnit: "bar"},
+	{terms: German, input: "-100bar", want: -100, wantUnit: "bar"},
+	{terms: German, input: "1,2\tbar", want: 1.2, wantUnit: "bar"},
+	{terms: German, input: "100 l/h", want: 100, wantUnit: "l/h"},
+	{terms: German, input: "400 RPM", want: 400, wantUnit: "rpm"},
+	{terms: German, input: "-12.2 V", want: -12.2, wantUnit: "V"},
+	{terms: German, input: "50%", want: 50, wantUnit: "pct"},
+	{terms: German, input: "100000 kWh", want: 100000, wantUnit: "kWh"},
+	{terms: German, input: "1 kW", want: 1, wantUnit: "kW"},
+	{terms: German, input: "16.66 Hz", want: 16.66, wantUnit: "Hz"},
+	{terms: German, input: "2", want: 2},
+	{terms: English, input: "200 mA", want: 200, wantUnit: "mA"},
+	{terms: English, input: "3600s", want: 3600, wantUnit: "s"},
+	{terms: English, input: "36 m³/h", want: 36, wantUnit: "m³/h"},
+	{terms: English, input: "18 min", want: 18 * 60, wantUnit: "s"},
+	{terms: English, input: "3.14", want: 3.14},
+	{terms: Dutch, input: "--- l/h", want: 0, wantUnit: "l/h"},
+	{terms: English, input: "---rpm", want: 0, wantUnit: "rpm"},
+	{terms: German, input: "NaN", wantErr: true},
+	{terms: German, input: "Inf °C", wantErr: true},
+	{terms: English, input: "1e308 min", wantErr: true},
+}
+
 func TestParseMeasurement(t *testing.T) {
-	for _, tc := range []struct {
-		terms    *Terminology
-		input    string
-		want     float64
-		wantUnit string
-		wantErr  bool
-	}{
-		{terms: German, input: "", wantErr: true},
-		{terms: German, input: "1.23", want: 1.23},
-		{terms: German, input: "100m", wantErr: true},
-		{terms: German, input: "1l", wantErr: true},
-		{terms: German, input: "1.11°C", want: 1.11, wantUnit: "degC"},
-		{terms: German, input: "2.22 °C", want: 2.22, wantUnit: "degC"},
-		{terms: German, input: "90 K", want: 90, wantUnit: "K"},
-		{terms: German, input: "0.0 bar", want: 0, wantUnit: "bar"},
-		{terms: German, input: "-100bar", want: -100, wantUnit: "bar"},
-		{terms: German, input: "1,2\tbar", want: 1.2, wantUnit: "bar"},
-		{terms: German, input: "100 l/h", want: 100, wantUnit: "l/h"},
-		{terms: German, input: "400 RPM", want: 400, wantUnit: "rpm"},
-		{terms: German, input: "-12.2 V", want: -12.2, wantUnit: "V"},
-		{terms: German, input: "50%", want: 50, wantUnit: "pct"},
-		{terms: German, input: "100000 kWh", want: 100000, wantUnit: "kWh"},
-		{terms: German, input: "1 kW", want: 1, wantUnit: "kW"},
-		{terms: German, input: "16.66 Hz", want: 16.66, wantUnit: "Hz"},
-		{terms: German, input: "2", want: 2},
-		{terms: English, input: "200 mA", want: 200, wantUnit: "mA"},
-		{terms: English, input: "3600s", want: 3600, wantUnit: "s"},
-		{terms: English, input: "36 m³/h", want: 36, wantUnit: "m³/h"},
-		{terms: English, input: "18 min", want: 18 * 60, wantUnit: "s"},
-		{terms: English, input: "3.14", want: 3.14},
-		{terms: Dutch, input: "--- l/h", want: 0, wantUnit: "l/h"},
-		{terms: English, input: "---rpm", want: 0, wantUnit: "rpm"},
-	} {
+	for _, tc := range parseMeasurementTests {
 		t.Run(tc.terms.ID+" "+tc.input, func(t *testing.T) {
 			got, gotUnit, err := tc.terms.ParseMeasurement(tc.input)
 
@@ -164,3 +180,15 @@ func TestParseMeasurement(t *testing.T) {
 		})
 	}
 }
+
+func TestParseMeasurementUnknownUnit(t *testing.T) {
+	for _, input := range []string{"1 lux", "---lux"} {
+		if _, _, err := German.ParseMeasurement(input); !errors.Is(err, ErrUnknownUnit) {
+			t.Errorf("ParseMeasurement(%q) returned %v, want %v", input, err, ErrUnknownUnit)
+		}
+	}
+
+	if _, _, err := German.ParseMeasurement("garbage"); errors.Is(err, ErrUnknownUnit) {
+		t.Errorf("ParseMeasurement() returned %v for invalid format", err)
+	}
+}
@@ -0,0 +1,376 @@
+package luxwssim
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hansmi/wp2reg-luxws/luxwslang"
+)
+
+// labels contains names used by the default model which are not part of the
+// terminology.
+type labels struct {
+	modes map[luxwslang.OperationMode]string
+
+	outside        string
+	flowTarget     string
+	hotWater       string
+	hotWaterTarget string
+
+	compressorHours  string
+	compressorStarts string
+	heatingHours     string
+	hotWaterHours    string
+	compressorState  string
+
+	utilityRelease string
+	smartGrid      string
+	compressor     string
+	pump           string
+
+	heatHeating  string
+	heatHotWater string
+	heatTotal    string
+
+	errorReason     string
+	switchOffReason string
+
+	settings    string
+	heatingMode string
+	modeAuto    string
+	modeOff     string
+}
+
+var englishLabels = labels{
+	modes: map[luxwslang.OperationMode]string{
+		luxwslang.OperationModeHeating:  "heating",
+		luxwslang.OperationModeHotWater: "hot water",
+		luxwslang.OperationModeDefrost:  "defrost",
+		luxwslang.OperationModeOff:      "no request",
+	},
+
+	outside:        "outdoor temp.",
+	flowTarget:     "flow set point",
+	hotWater:       "hot water actual",
+	hotWaterTarget: "hot water set point",
+
+	compressorHours:  "operating hours VD1",
+	compressorStarts: "impulse VD1",
+	heatingHours:     "operating hours heating",
+	hotWaterHours:    "operating hours DHW",
+	compressorState:  "compressor state",
+
+	utilityRelease: "EVU",
+	smartGrid:      "Smart Grid",
+	compressor:     "VD1",
+	pump:           "HUP",
+
+	heatHeating:  "heating",
+	heatHotWater: "hot water",
+	heatTotal:    "total",
+
+	errorReason:     "715 high pressure",
+	switchOffReason: "heat pump error",
+
+	settings:    "settings",
+	heatingMode: "heating",
+	modeAuto:    "automatic",
+	modeOff:     "off",
+}
+
+var germanLabels = labels{
+	modes: map[luxwslang.OperationMode]string{
+		luxwslang.OperationModeHeating:  "Heizbetrieb",
+		luxwslang.OperationModeHotWater: "Warmwasser",
+		luxwslang.OperationModeDefrost:  "Abtauen",
+		luxwslang.OperationModeOff:      "Keine Anforderung",
+	},
+
+	outside:        "Außentemperatur",
+	flowTarget:     "Vorlauf-Soll",
+	hotWater:       "Warmwasser-Ist",
+	hotWaterTarget: "Warmwasser-Soll",
+
+	compressorHours:  "Betriebstund. VD1",
+	compressorStarts: "Impulse VD1",
+	heatingHours:     "Betriebstunden Heiz.",
+	hotWaterHours:    "Betriebstunden WW",
+	compressorState:  "VD-Stand",
+
+	utilityRelease: "EVU",
+	smartGrid:      "Smart Grid",
+	compressor:     "VD1",
+	pump:           "HUP",
+
+	heatHeating:  "Heizung",
+	heatHotWater: "Warmwasser",
+	heatTotal:    "Gesamt",
+
+	errorReason:     "715 Hochdruck-Abschalt.",
+	switchOffReason: "WP Störung",
+
+	settings:    "Einstellungen",
+	heatingMode: "Heizung",
+	modeAuto:    "Automatik",
+	modeOff:     "Aus",
+}
+
+// labelsFor returns the labels for a language. Names are in English unless
+// the terminology requires otherwise.
+func labelsFor(terms *luxwslang.Terminology) labels {
+	if terms.ID == "de" {
+		return germanLabels
+	}
+
+	l := englishLabels
+
+	var modes map[luxwslang.OperationMode]string
+
+	switch terms.ID {
+	case "cz":
+		modes = map[luxwslang.OperationMode]string{
+			luxwslang.OperationModeHeating:  "Topení",
+			luxwslang.OperationModeHotWater: "Teplá voda",
+			luxwslang.OperationModeDefrost:  "Odtávání",
+			luxwslang.OperationModeOff:      "Bez požadavku",
+		}
+		l.compressorStarts = "Počet startů VD1"
+
+	case "fi":
+		modes = map[luxwslang.OperationMode]string{
+			luxwslang.OperationModeHeating:  "Lämmitys",
+			luxwslang.OperationModeHotWater: "Käyttövesi",
+			luxwslang.OperationModeDefrost:  "Sulatus",
+			luxwslang.OperationModeOff:      "Ei tarvetta",
+		}
+
+	case "nl":
+		modes = map[luxwslang.OperationMode]string{
+			luxwslang.OperationModeHeating:  "Verwarmen",
+			luxwslang.OperationModeHotWater: "Warm water",
+			luxwslang.OperationModeDefrost:  "Ontdooien",
+			luxwslang.OperationModeOff:      "Geen vraag",
+		}
+	}
+
+	if modes != nil {
+		l.modes = modes
+	}
+
+	return l
+}
+
+// Variant describes a heat pump model and controller firmware.
+type Variant struct {
+	Name        string
+	Description string
+
+	// Heat pump type and software version shown in the system status.
+	Type            string
+	SoftwareVersion string
+
+	// Whether the information page contains heat quantities. Not all heat
+	// pumps (e.g. L2A) report them.
+	HeatQuantity bool
+
+	// Whether inputs include the dimensionless "Smart Grid" state as
+	// reported by LD7 heat pumps.
+	SmartGrid bool
+
+	// Whether responses are encoded as ISO-8859-1 as done by older firmware
+	// versions.
+	Latin1 bool
+}
+
+var variants = []Variant{
+	{
+		Name:            "default",
+		Description:     "Air-to-water heat pump with current firmware",
+		Type:            "LWD 50A",
+		SoftwareVersion: "V3.85.6",
+		HeatQuantity:    true,
+	},
+	{
+		Name:            "l2a",
+		Description:     "L2A heat pump without heat quantities",
+		Type:            "L2A",
+		SoftwareVersion: "V3.89.4",
+	},
+	{
+		Name:            "ld7",
+		Description:     "LD7 heat pump reporting a Smart Grid state",
+		Type:            "LD7",
+		SoftwareVersion: "V3.90.1",
+		HeatQuantity:    true,
+		SmartGrid:       true,
+	},
+	{
+		Name:            "legacy",
+		Description:     "Older firmware sending ISO-8859-1 encoded responses",
+		Type:            "LWD 70",
+		SoftwareVersion: "V1.86.2",
+		HeatQuantity:    true,
+		Latin1:          true,
+	},
+}
+
+// Variants returns all known variants sorted by name.
+func Variants() []Variant {
+	result := append([]Variant(nil), variants...)
+
+	sort.Slice(result, func(a, b int) bool {
+		return result[a].Name < result[b].Name
+	})
+
+	return result
+}
+
+// LookupVariant finds a variant by name.
+func LookupVariant(name string) (Variant, error) {
+	for _, v := range variants {
+		if v.Name == name {
+			return v, nil
+		}
+	}
+
+	return Variant{}, fmt.Errorf("variant %q not found", name)
+}
+
+func sourceItem(name, source string) Item {
+	return Item{Name: name, Source: source}
+}
+
+func valueItem(name, value string) Item {
+	return Item{Name: name, Value: &value}
+}
+
+// DefaultModel returns a model with an information page containing all
+// sections evaluated by the exporter, named according to the terminology,
+// and a settings page.
+func DefaultModel(terms *luxwslang.Terminology, v Variant) *Model {
+	l := labelsFor(terms)
+
+	str := func(s string) *string {
+		return &s
+	}
+
+	groups := []Item{
+		{
+			Name: terms.NavTemperatures,
+			Items: []Item{
+				sourceItem(terms.TemperatureFlow, "flow"),
+				sourceItem(terms.TemperatureReturn, "return"),
+				sourceItem(l.flowTarget, "flow_target"),
+				sourceItem(l.outside, "outside"),
+				sourceItem(l.hotWater, "hot_water"),
+				sourceItem(l.hotWaterTarget, "hot_water_target"),
+			},
+		},
+	}
+
+	inputs := Item{
+		Name: terms.NavInputs,
+		Items: []Item{
+			valueItem(l.utilityRelease, terms.BoolTrue),
+			sourceItem(terms.InputFlowRate, "flow_rate"),
+		},
+	}
+
+	if v.SmartGrid {
+		inputs.Items = append(inputs.Items, valueItem(l.smartGrid, "2"))
+	}
+
+	groups = append(groups, inputs, Item{
+		Name: terms.NavOutputs,
+		Items: []Item{
+			sourceItem(terms.OutputDefrostValve, "defrost_valve"),
+			sourceItem(l.pump, "circulation_pump"),
+			sourceItem(l.compressor, "compressor"),
+		},
+	}, Item{
+		Name: terms.NavElapsedTimes,
+		Items: []Item{
+			sourceItem(l.compressorState, "compressor_state"),
+		},
+	}, Item{
+		Name: terms.NavOpHours,
+		Items: []Item{
+			sourceItem(l.compressorHours, "compressor_hours"),
+			sourceItem(l.compressorStarts, "compressor_starts"),
+			sourceItem(l.heatingHours, "heating_hours"),
+			sourceItem(l.hotWaterHours, "hot_water_hours"),
+		},
+	}, Item{
+		Name: terms.NavErrorMemory,
+		Items: []Item{
+			valueItem("14.11.23 06:12:45", l.errorReason),
+			valueItem("02.02.24 17:40:03", l.errorReason),
+		},
+	}, Item{
+		Name: terms.NavSwitchOffs,
+		Items: []Item{
+			valueItem("14.11.23 06:12:45", l.switchOffReason),
+			valueItem("02.02.24 17:40:03", l.switchOffReason),
+		},
+	}, Item{
+		Name: terms.NavSystemStatus,
+		Items: []Item{
+			valueItem(terms.StatusType, v.Type),
+			valueItem(terms.StatusSoftwareVersion, v.SoftwareVersion),
+			sourceItem(terms.StatusOperationMode, "operation_mode"),
+			sourceItem(terms.StatusPowerOutput, "power_output"),
+			sourceItem(terms.StatusPowerInput, "power_input"),
+		},
+	})
+
+	if v.HeatQuantity {
+		groups = append(groups, Item{
+			Name: terms.NavHeatQuantity,
+			Items: []Item{
+				sourceItem(l.heatHeating, "heat_heating"),
+				sourceItem(l.heatHotWater, "heat_hot_water"),
+				sourceItem(l.heatTotal, "heat_total"),
+			},
+		})
+	}
+
+	info := Page{
+		Name:  terms.NavInformation,
+		Items: groups,
+	}
+
+	// The controller offers each section as a separate page too.
+	for _, group := range groups {
+		info.Pages = append(info.Pages, Page{
+			Name:  group.Name,
+			Items: group.Items,
+		})
+	}
+
+	settings := Page{
+		Name: l.settings,
+		Items: []Item{
+			{
+				Name:   l.heatingMode,
+				Source: "heating_mode",
+				Options: []Option{
+					{Value: fmt.Sprint(heatingModeAuto), Name: l.modeAuto},
+					{Value: fmt.Sprint(heatingModeOff), Name: l.modeOff},
+				},
+			},
+			{
+				Name:   l.hotWaterTarget,
+				Source: "hot_water_target",
+				Min:    str("30"),
+				Max:    str("65"),
+				Step:   str("0.5"),
+				Unit:   str("°C"),
+				Div:    str("10"),
+			},
+		},
+	}
+
+	return &Model{
+		Pages: []Page{info, settings},
+	}
+}
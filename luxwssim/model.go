@@ -0,0 +1,174 @@
+package luxwssim
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.yaml.in/yaml/v2"
+)
+
+// Model describes the navigation structure and pages served by the
+// simulator. Models can be written in YAML or XML using the same field names.
+type Model struct {
+	XMLName xml.Name `xml:"model" yaml:"-"`
+	Pages   []Page   `xml:"page" yaml:"pages"`
+}
+
+// Page is a navigation item. Pages without items only serve to group other
+// pages.
+type Page struct {
+	Name  string `xml:"name,attr" yaml:"name"`
+	Items []Item `xml:"item" yaml:"items"`
+	Pages []Page `xml:"page" yaml:"pages"`
+}
+
+// Item is an entry on a page. Groups contain other items and have no value.
+type Item struct {
+	Name string `xml:"name" yaml:"name"`
+
+	// Text reported for the item unless a source is given.
+	Value *string `xml:"value" yaml:"value"`
+
+	// Quantity of the thermal model reported instead of a fixed value (e.g.
+	// "flow"). Items with a source representing a setting of the thermal
+	// model (e.g. "hot_water_target") change the model when set.
+	Source string `xml:"source" yaml:"source"`
+
+	// Permitted range of settable numeric items.
+	Min  *string `xml:"min" yaml:"min"`
+	Max  *string `xml:"max" yaml:"max"`
+	Step *string `xml:"step" yaml:"step"`
+	Unit *string `xml:"unit" yaml:"unit"`
+	Div  *string `xml:"div" yaml:"div"`
+	Raw  *string `xml:"raw" yaml:"raw"`
+
+	// Options of settable enumerations.
+	Options []Option `xml:"option" yaml:"options"`
+
+	Items []Item `xml:"item" yaml:"items"`
+}
+
+// Option is one of the values of a settable enumeration.
+type Option struct {
+	Value string `xml:"value,attr" yaml:"value"`
+	Name  string `xml:",chardata" yaml:"name"`
+}
+
+// settable reports whether the item can be changed via SET.
+func (i *Item) settable() bool {
+	return len(i.Options) > 0 || (i.Min != nil && i.Max != nil)
+}
+
+func validateItems(path string, items []Item) error {
+	for _, item := range items {
+		itemPath := path + "/" + item.Name
+
+		if strings.TrimSpace(item.Name) == "" {
+			return fmt.Errorf("%s: item without name", path)
+		}
+
+		if item.Source != "" && !knownSource(item.Source) {
+			return fmt.Errorf("%s: unknown source %q", itemPath, item.Source)
+		}
+
+		if err := validateItems(itemPath, item.Items); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validatePages(path string, pages []Page) error {
+	for _, page := range pages {
+		pagePath := path + "/" + page.Name
+
+		if strings.TrimSpace(page.Name) == "" {
+			return fmt.Errorf("%s: page without name", path)
+		}
+
+		if err := validateItems(pagePath, page.Items); err != nil {
+			return err
+		}
+
+		if err := validatePages(pagePath, page.Pages); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Validate checks the model for missing names and unknown sources.
+func (m *Model) Validate() error {
+	if len(m.Pages) == 0 {
+		return errors.New("model without pages")
+	}
+
+	return validatePages("", m.Pages)
+}
+
+// ParseModel reads a model in the given format ("yaml" or "xml").
+func ParseModel(r io.Reader, format string) (*Model, error) {
+	var m Model
+	var err error
+
+	switch format {
+	case "yaml":
+		var data []byte
+
+		if data, err = io.ReadAll(r); err == nil {
+			err = yaml.UnmarshalStrict(data, &m)
+		}
+
+	case "xml":
+		err = xml.NewDecoder(r).Decode(&m)
+
+	default:
+		return nil, fmt.Errorf("unknown model format %q", format)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("parsing model: %w", err)
+	}
+
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// LoadModel reads a model from a file. The format is derived from the file
+// extension (".yaml", ".yml" or ".xml").
+func LoadModel(path string) (*Model, error) {
+	var format string
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		format = "yaml"
+	case ".xml":
+		format = "xml"
+	default:
+		return nil, fmt.Errorf("%s: unknown model format", path)
+	}
+
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer fh.Close()
+
+	m, err := ParseModel(fh, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return m, nil
+}
@@ -0,0 +1,132 @@
+package luxwssim
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseModel(t *testing.T) {
+	value := "12"
+
+	want := &Model{
+		Pages: []Page{
+			{
+				Name: "Info",
+				Items: []Item{
+					{Name: "Flow", Source: "flow"},
+					{Name: "Level", Value: &value},
+				},
+				Pages: []Page{
+					{
+						Name: "Settings",
+						Items: []Item{
+							{
+								Name: "Mode",
+								Options: []Option{
+									{Value: "0", Name: "Auto"},
+									{Value: "4", Name: "Off"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tc := range []struct {
+		format, input string
+	}{
+		{
+			format: "yaml",
+			input: `
+pages:
+- name: Info
+  items:
+  - name: Flow
+    source: flow
+  - name: Level
+    value: "12"
+  pages:
+  - name: Settings
+    items:
+    - name: Mode
+      options:
+      - {value: "0", name: Auto}
+      - {value: "4", name: "Off"}
+`,
+		},
+		{
+			format: "xml",
+			input: `
+<model>
+  <page name="Info">
+    <item><name>Flow</name><source>flow</source></item>
+    <item><name>Level</name><value>12</value></item>
+    <page name="Settings">
+      <item>
+        <name>Mode</name>
+        <option value="0">Auto</option>
+        <option value="4">Off</option>
+      </item>
+    </page>
+  </page>
+</model>
+`,
+		},
+	} {
+		t.Run(tc.format, func(t *testing.T) {
+			got, err := ParseModel(strings.NewReader(tc.input), tc.format)
+			if err != nil {
+				t.Fatalf("ParseModel() failed: %v", err)
+			}
+
+			if diff := cmp.Diff(want, got, cmp.FilterPath(func(p cmp.Path) bool {
+				return p.Last().String() == ".XMLName"
+			}, cmp.Ignore())); diff != "" {
+				t.Errorf("Model difference (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseModelInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		format, input string
+	}{
+		{"yaml", ""},
+		{"yaml", "pages: [{name: Info, unknown: 1}]"},
+		{"yaml", "pages: [{name: Info, items: [{name: X, source: nothing}]}]"},
+		{"yaml", "pages: [{items: [{name: X}]}]"},
+		{"xml", "<model><page name=\"Info\"><item/></page></model>"},
+		{"json", "{}"},
+	} {
+		if _, err := ParseModel(strings.NewReader(tc.input), tc.format); err == nil {
+			t.Errorf("ParseModel(%q, %q) succeeded", tc.input, tc.format)
+		}
+	}
+}
+
+func TestLoadModel(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "model.yml")
+
+	if err := os.WriteFile(path, []byte("pages: [{name: Info}]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if m, err := LoadModel(path); err != nil {
+		t.Errorf("LoadModel() failed: %v", err)
+	} else if len(m.Pages) != 1 {
+		t.Errorf("LoadModel() returned %d pages", len(m.Pages))
+	}
+
+	if _, err := LoadModel(filepath.Join(dir, "model.txt")); err == nil {
+		t.Errorf("LoadModel() with unknown extension succeeded")
+	}
+}
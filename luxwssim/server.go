@@ -0,0 +1,547 @@
+package luxwssim
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+	"github.com/hansmi/wp2reg-luxws/luxwslang"
+	"go.uber.org/multierr"
+)
+
+// Subprotocol is the Websocket subprotocol spoken by the simulator.
+const Subprotocol = "Lux_WS"
+
+// LogFunc is the signature of a log function.
+type LogFunc func(format string, v ...any)
+
+// Options configures a simulator.
+type Options struct {
+	// Terminology used for boolean values and operation modes. Defaults to
+	// German, the language of most controllers.
+	Terms *luxwslang.Terminology
+
+	// Heat pump variant used for the default model and the response
+	// encoding.
+	Variant Variant
+
+	// Served model. Defaults to DefaultModel.
+	Model *Model
+
+	// Password required for changing settings. Logins with a different
+	// password are accepted, but changes are ignored like on a real
+	// controller. Any password is accepted if empty.
+	Password string
+
+	// Simulated time at the start. Defaults to the current time.
+	Start time.Time
+
+	// Ratio of simulated time to real time, e.g. 60 for one simulated
+	// minute per second. Defaults to 1.
+	Speed float64
+
+	// Source of the real time; time.Now if nil.
+	Now func() time.Time
+
+	Logf LogFunc
+}
+
+// Server simulates a heat pump controller. It implements http.Handler and
+// speaks the Lux_WS protocol on all paths.
+type Server struct {
+	opts     Options
+	labels   labels
+	upgrader websocket.Upgrader
+	started  time.Time
+
+	mu      sync.Mutex
+	thermal *thermal
+
+	// Values of settable items without a source changed via SET.
+	values map[*Item]string
+}
+
+// NewServer returns a new simulator.
+func NewServer(opts Options) (*Server, error) {
+	if opts.Terms == nil {
+		opts.Terms = luxwslang.German
+	}
+
+	if opts.Variant.Name == "" {
+		opts.Variant = variants[0]
+	}
+
+	if opts.Model == nil {
+		opts.Model = DefaultModel(opts.Terms, opts.Variant)
+	} else if err := opts.Model.Validate(); err != nil {
+		return nil, err
+	}
+
+	if opts.Speed <= 0 {
+		opts.Speed = 1
+	}
+
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	if opts.Start.IsZero() {
+		opts.Start = opts.Now()
+	}
+
+	if opts.Logf == nil {
+		opts.Logf = func(string, ...any) {}
+	}
+
+	return &Server{
+		opts:   opts,
+		labels: labelsFor(opts.Terms),
+		upgrader: websocket.Upgrader{
+			Subprotocols: []string{Subprotocol},
+			CheckOrigin: func(*http.Request) bool {
+				return true
+			},
+		},
+		started: opts.Now(),
+		thermal: newThermal(opts.Start),
+		values:  map[*Item]string{},
+	}, nil
+}
+
+// Now returns the current simulated time.
+func (s *Server) Now() time.Time {
+	elapsed := s.opts.Now().Sub(s.started)
+
+	return s.opts.Start.Add(time.Duration(float64(elapsed) * s.opts.Speed))
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ws, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		s.opts.Logf("Upgrade from %s failed: %v", r.RemoteAddr, err)
+		return
+	}
+
+	defer ws.Close()
+
+	c := newConn(s)
+
+	s.opts.Logf("%s: Connected", r.RemoteAddr)
+
+	for {
+		_, payload, err := ws.ReadMessage()
+		if err != nil {
+			s.opts.Logf("%s: Disconnected: %v", r.RemoteAddr, err)
+			return
+		}
+
+		s.opts.Logf("%s: Received %q", r.RemoteAddr, payload)
+
+		response, err := c.handle(string(payload))
+		if err != nil {
+			s.opts.Logf("%s: %v", r.RemoteAddr, err)
+			continue
+		}
+
+		if response == nil {
+			continue
+		}
+
+		if err := ws.WriteMessage(websocket.TextMessage, response); err != nil {
+			s.opts.Logf("%s: Sending response failed: %v", r.RemoteAddr, err)
+			return
+		}
+	}
+}
+
+func parseNumber(text string) (float64, error) {
+	return strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(text), ",", "."), 64)
+}
+
+// formatSource formats the current value of a thermal model quantity.
+// s.mu must be held.
+func (s *Server) formatSource(source string) string {
+	v := s.thermal.number(source)
+
+	switch sources[source] {
+	case kindTemperature:
+		return fmt.Sprintf("%.1f°C", v)
+
+	case kindFlowRate:
+		return fmt.Sprintf("%.0f l/h", v)
+
+	case kindPower:
+		return fmt.Sprintf("%.2f kW", v)
+
+	case kindBool:
+		if v != 0 {
+			return s.opts.Terms.BoolTrue
+		}
+
+		return s.opts.Terms.BoolFalse
+
+	case kindDuration:
+		secs := int64(v)
+
+		return fmt.Sprintf("%d:%02d:%02d", secs/3600, secs/60%60, secs%60)
+
+	case kindEnergy:
+		return fmt.Sprintf("%.1f kWh", v)
+
+	case kindMode:
+		return s.labels.modes[luxwslang.OperationMode(v)]
+	}
+
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// scaleRaw returns the raw representation of a setting.
+func scaleRaw(item *Item, value float64) string {
+	if item.Div != nil {
+		if div, err := parseNumber(*item.Div); err == nil && div != 0 {
+			value *= div
+		}
+	}
+
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func optionName(item *Item, value string) string {
+	for _, opt := range item.Options {
+		if opt.Value == value {
+			return opt.Name
+		}
+	}
+
+	return value
+}
+
+// itemValue returns the displayed and raw values of an item. s.mu must be
+// held.
+func (s *Server) itemValue(item *Item) (value, raw *string) {
+	text := func(v string) *string {
+		return &v
+	}
+
+	var current string
+	var ok bool
+
+	if item.Source != "" {
+		if !item.settable() {
+			return text(s.formatSource(item.Source)), nil
+		}
+
+		current, ok = strconv.FormatFloat(s.thermal.number(item.Source), 'f', -1, 64), true
+	} else {
+		current, ok = s.values[item]
+	}
+
+	if !ok {
+		return item.Value, item.Raw
+	}
+
+	if len(item.Options) > 0 {
+		return text(optionName(item, current)), text(current)
+	}
+
+	num, err := parseNumber(current)
+	if err != nil {
+		return text(current), nil
+	}
+
+	if item.Source != "" {
+		value = text(s.formatSource(item.Source))
+	} else if item.Unit != nil {
+		value = text(current + *item.Unit)
+	} else {
+		value = text(current)
+	}
+
+	return value, text(scaleRaw(item, num))
+}
+
+// set changes the value of a settable item.
+func (s *Server) set(item *Item, value string) error {
+	if !item.settable() {
+		return fmt.Errorf("item %q can't be changed", item.Name)
+	}
+
+	if len(item.Options) > 0 {
+		found := false
+
+		for _, opt := range item.Options {
+			if opt.Value == value {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("%q is not an option of item %q", value, item.Name)
+		}
+	} else {
+		num, err := parseNumber(value)
+		if err != nil {
+			return fmt.Errorf("invalid value %q for item %q: %w", value, item.Name, err)
+		}
+
+		if min, err := parseNumber(*item.Min); err == nil && num < min {
+			return fmt.Errorf("value %q for item %q is below minimum %s", value, item.Name, *item.Min)
+		}
+
+		if max, err := parseNumber(*item.Max); err == nil && num > max {
+			return fmt.Errorf("value %q for item %q is above maximum %s", value, item.Name, *item.Max)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if item.Source != "" {
+		num, err := parseNumber(value)
+		if err != nil {
+			return err
+		}
+
+		return s.thermal.set(item.Source, num)
+	}
+
+	s.values[item] = value
+
+	return nil
+}
+
+// encode returns the XML representation of a response.
+func (s *Server) encode(v any) ([]byte, error) {
+	payload, err := xml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if !s.opts.Variant.Latin1 {
+		return payload, nil
+	}
+
+	result := []byte(`<?xml version="1.0" encoding="ISO-8859-1"?>`)
+
+	for len(payload) > 0 {
+		r, size := utf8.DecodeRune(payload)
+		payload = payload[size:]
+
+		if r > 0xff {
+			r = '?'
+		}
+
+		result = append(result, byte(r))
+	}
+
+	return result, nil
+}
+
+type xmlNavItem struct {
+	ID    string       `xml:"id,attr"`
+	Name  string       `xml:"name"`
+	Items []xmlNavItem `xml:"item"`
+}
+
+type xmlNavigation struct {
+	XMLName xml.Name     `xml:"Navigation"`
+	ID      string       `xml:"id,attr"`
+	Items   []xmlNavItem `xml:"item"`
+}
+
+type xmlItem struct {
+	ID      string    `xml:"id,attr"`
+	Name    string    `xml:"name"`
+	Value   *string   `xml:"value,omitempty"`
+	Min     *string   `xml:"min,omitempty"`
+	Max     *string   `xml:"max,omitempty"`
+	Step    *string   `xml:"step,omitempty"`
+	Unit    *string   `xml:"unit,omitempty"`
+	Div     *string   `xml:"div,omitempty"`
+	Raw     *string   `xml:"raw,omitempty"`
+	Options []Option  `xml:"option"`
+	Items   []xmlItem `xml:"item"`
+}
+
+type xmlContent struct {
+	XMLName xml.Name  `xml:"Content"`
+	Items   []xmlItem `xml:"item"`
+}
+
+// conn is the state of a single client connection. Like on a real
+// controller, the IDs of pages and items differ between connections.
+type conn struct {
+	s *Server
+
+	ids     map[string]any
+	nodeIDs map[any]string
+	next    uint32
+
+	authorized bool
+	current    *Page
+	pending    map[*Item]string
+}
+
+func newConn(s *Server) *conn {
+	return &conn{
+		s:       s,
+		ids:     map[string]any{},
+		nodeIDs: map[any]string{},
+		next:    0x1000000 + rand.Uint32N(0x1000000)*8,
+		pending: map[*Item]string{},
+	}
+}
+
+// id returns the ID of a page or an item.
+func (c *conn) id(node any) string {
+	if id, ok := c.nodeIDs[node]; ok {
+		return id
+	}
+
+	c.next += 8 + rand.Uint32N(4)*8
+
+	id := fmt.Sprintf("0x%x", c.next)
+
+	c.ids[id] = node
+	c.nodeIDs[node] = id
+
+	return id
+}
+
+func (c *conn) navItems(pages []Page) []xmlNavItem {
+	var result []xmlNavItem
+
+	for i := range pages {
+		page := &pages[i]
+
+		result = append(result, xmlNavItem{
+			ID:    c.id(page),
+			Name:  page.Name,
+			Items: c.navItems(page.Pages),
+		})
+	}
+
+	return result
+}
+
+func (c *conn) contentItems(items []Item) []xmlItem {
+	var result []xmlItem
+
+	for i := range items {
+		item := &items[i]
+
+		x := xmlItem{
+			ID:      c.id(item),
+			Name:    item.Name,
+			Min:     item.Min,
+			Max:     item.Max,
+			Step:    item.Step,
+			Unit:    item.Unit,
+			Div:     item.Div,
+			Options: item.Options,
+			Items:   c.contentItems(item.Items),
+		}
+
+		if len(item.Items) == 0 {
+			x.Value, x.Raw = c.s.itemValue(item)
+		}
+
+		result = append(result, x)
+	}
+
+	return result
+}
+
+func (c *conn) content(page *Page) ([]byte, error) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+
+	c.s.thermal.advance(c.s.Now())
+
+	return c.s.encode(xmlContent{
+		Items: c.contentItems(page.Items),
+	})
+}
+
+// handle processes a single request and returns the response, if any.
+func (c *conn) handle(req string) ([]byte, error) {
+	parts := strings.Split(req, ";")
+
+	switch parts[0] {
+	case "LOGIN":
+		password := strings.Join(parts[1:], ";")
+
+		c.authorized = c.s.opts.Password == "" || password == c.s.opts.Password
+
+		return c.s.encode(xmlNavigation{
+			ID:    c.id(c.s.opts.Model),
+			Items: c.navItems(c.s.opts.Model.Pages),
+		})
+
+	case "GET":
+		if len(parts) != 2 {
+			break
+		}
+
+		page, ok := c.ids[parts[1]].(*Page)
+		if !ok {
+			return nil, fmt.Errorf("unknown page ID %q", parts[1])
+		}
+
+		c.current = page
+
+		return c.content(page)
+
+	case "REFRESH":
+		if c.current == nil {
+			return nil, errors.New("refresh without page")
+		}
+
+		return c.content(c.current)
+
+	case "SET":
+		if len(parts) != 3 {
+			break
+		}
+
+		item, ok := c.ids[strings.TrimPrefix(parts[1], "set_")].(*Item)
+		if !ok {
+			return nil, fmt.Errorf("unknown item ID %q", parts[1])
+		}
+
+		c.pending[item] = parts[2]
+
+		return nil, nil
+
+	case "SAVE":
+		pending := c.pending
+		c.pending = map[*Item]string{}
+
+		if len(parts) != 2 || parts[1] != "1" {
+			return nil, nil
+		}
+
+		if !c.authorized {
+			return nil, errors.New("not logged in with valid password, ignoring changes")
+		}
+
+		var err error
+
+		for item, value := range pending {
+			multierr.AppendInto(&err, c.s.set(item, value))
+		}
+
+		return nil, err
+	}
+
+	return nil, fmt.Errorf("unsupported request %q", req)
+}
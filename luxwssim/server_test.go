@@ -0,0 +1,257 @@
+package luxwssim
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/hansmi/wp2reg-luxws/luxwsclient"
+	"github.com/hansmi/wp2reg-luxws/luxwslang"
+)
+
+func newTestServer(t *testing.T, opts Options) string {
+	t.Helper()
+
+	s, err := NewServer(opts)
+	if err != nil {
+		t.Fatalf("NewServer() failed: %v", err)
+	}
+
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return u.Host
+}
+
+func dialTestServer(ctx context.Context, t *testing.T, address, password string) (*luxwsclient.Client, *luxwsclient.NavRoot) {
+	t.Helper()
+
+	cl, err := luxwsclient.Dial(ctx, address, luxwsclient.WithLogFunc(t.Logf))
+	if err != nil {
+		t.Fatalf("Dial() failed: %v", err)
+	}
+
+	t.Cleanup(func() {
+		cl.Close()
+	})
+
+	nav, err := cl.Login(ctx, password)
+	if err != nil {
+		t.Fatalf("Login() failed: %v", err)
+	}
+
+	return cl, nav
+}
+
+func getPage(ctx context.Context, t *testing.T, cl *luxwsclient.Client, nav *luxwsclient.NavRoot, name string) *luxwsclient.ContentRoot {
+	t.Helper()
+
+	page := nav.FindByName(name)
+	if page == nil {
+		t.Fatalf("Page %q not found", name)
+	}
+
+	content, err := cl.Get(ctx, page.ID)
+	if err != nil {
+		t.Fatalf("Get(%q) failed: %v", page.ID, err)
+	}
+
+	return content
+}
+
+func findValue(t *testing.T, content *luxwsclient.ContentRoot, name string) string {
+	t.Helper()
+
+	item := content.FindByName(name)
+	if item == nil || item.Value == nil {
+		t.Fatalf("Item %q without value", name)
+	}
+
+	return *item.Value
+}
+
+func TestServerInformation(t *testing.T) {
+	for _, terms := range luxwslang.All() {
+		for _, v := range Variants() {
+			if v.Latin1 && terms.ID == "cz" {
+				// Czech characters can't be encoded
+				continue
+			}
+
+			t.Run(terms.ID+"/"+v.Name, func(t *testing.T) {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+				t.Cleanup(cancel)
+
+				address := newTestServer(t, Options{
+					Terms:   terms,
+					Variant: v,
+				})
+
+				cl, nav := dialTestServer(ctx, t, address, "")
+
+				content := getPage(ctx, t, cl, nav, terms.NavInformation)
+
+				if got := findValue(t, content, terms.StatusType); got != v.Type {
+					t.Errorf("Type %q, want %q", got, v.Type)
+				}
+
+				if mode := terms.ParseOperationMode(findValue(t, content, terms.StatusOperationMode)); mode == luxwslang.OperationModeUnknown {
+					t.Errorf("Unrecognized operation mode")
+				}
+
+				for _, name := range []string{terms.TemperatureFlow, terms.TemperatureReturn} {
+					if _, unit, err := terms.ParseMeasurement(findValue(t, content, name)); err != nil || unit != "degC" {
+						t.Errorf("Temperature %q has unit %q: %v", name, unit, err)
+					}
+				}
+
+				if got := content.FindByName(terms.NavHeatQuantity) != nil; got != v.HeatQuantity {
+					t.Errorf("Heat quantity present: %v, want %v", got, v.HeatQuantity)
+				}
+
+				hours := content.FindByName(terms.NavOpHours)
+				if hours == nil {
+					t.Fatalf("Operating hours not found")
+				}
+
+				var impulses int
+
+				for _, item := range hours.Items {
+					if terms.HoursImpulsesRe.MatchString(item.Name) {
+						impulses++
+					} else if _, err := terms.ParseDuration(*item.Value); err != nil {
+						t.Errorf("Parsing duration of %q failed: %v", item.Name, err)
+					}
+				}
+
+				if impulses != 1 {
+					t.Errorf("Found %d impulse items, want 1", impulses)
+				}
+
+				for _, name := range []string{terms.NavErrorMemory, terms.NavSwitchOffs} {
+					for _, item := range content.FindByName(name).Items {
+						if _, err := terms.ParseTimestamp(item.Name, time.UTC); err != nil {
+							t.Errorf("Parsing timestamp failed: %v", err)
+						}
+					}
+				}
+
+				// Sections are available as separate pages
+				temperatures := getPage(ctx, t, cl, nav, terms.NavTemperatures)
+
+				if temperatures.FindByName(terms.TemperatureFlow) == nil {
+					t.Errorf("Flow temperature not found on separate page")
+				}
+			})
+		}
+	}
+}
+
+func TestServerConnectionIDs(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	address := newTestServer(t, Options{})
+
+	cl1, nav1 := dialTestServer(ctx, t, address, "")
+	_, nav2 := dialTestServer(ctx, t, address, "")
+
+	info1 := nav1.FindByName(luxwslang.German.NavInformation)
+	info2 := nav2.FindByName(luxwslang.German.NavInformation)
+
+	if info1.ID == info2.ID {
+		t.Errorf("Connections use the same ID %q", info1.ID)
+	}
+
+	if _, err := cl1.Get(ctx, info1.ID); err != nil {
+		t.Errorf("Get() failed: %v", err)
+	}
+}
+
+func TestServerSet(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	address := newTestServer(t, Options{
+		Terms:    luxwslang.English,
+		Password: "1234",
+	})
+
+	set := func(password, name, value string) *luxwsclient.ContentItem {
+		t.Helper()
+
+		cl, nav := dialTestServer(ctx, t, address, password)
+
+		item := getPage(ctx, t, cl, nav, "settings").FindByName(name)
+
+		if err := cl.Set(ctx, item.ID, value); err != nil {
+			t.Fatalf("Set() failed: %v", err)
+		}
+
+		return getPage(ctx, t, cl, nav, "settings").FindByName(name)
+	}
+
+	for _, tc := range []struct {
+		password, name, value string
+		want                  string
+	}{
+		{"wrong", "hot water set point", "55", "50.0°C"},
+		{"1234", "hot water set point", "55", "55.0°C"},
+		{"1234", "hot water set point", "99", "55.0°C"},
+		{"1234", "heating", "4", "off"},
+		{"1234", "heating", "7", "off"},
+		{"1234", "heating", "0", "automatic"},
+	} {
+		if got := set(tc.password, tc.name, tc.value); got.Value == nil || *got.Value != tc.want {
+			t.Errorf("Setting %q to %q with password %q resulted in %v, want %q",
+				tc.name, tc.value, tc.password, got.Value, tc.want)
+		}
+	}
+}
+
+func TestServerCustomModel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	min, max := "1", "10"
+	value := "5"
+
+	address := newTestServer(t, Options{
+		Model: &Model{
+			Pages: []Page{
+				{
+					Name: "Main",
+					Items: []Item{
+						{Name: "Level", Value: &value, Min: &min, Max: &max},
+						{Name: "Outside", Source: "outside"},
+					},
+				},
+			},
+		},
+	})
+
+	cl, nav := dialTestServer(ctx, t, address, "")
+
+	content := getPage(ctx, t, cl, nav, "Main")
+
+	if err := cl.Set(ctx, content.FindByName("Level").ID, "7"); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+
+	content = getPage(ctx, t, cl, nav, "Main")
+
+	if got := findValue(t, content, "Level"); got != "7" {
+		t.Errorf("Level is %q, want 7", got)
+	}
+
+	if _, unit, err := luxwslang.German.ParseMeasurement(findValue(t, content, "Outside")); err != nil || unit != "degC" {
+		t.Errorf("Outside temperature has unit %q: %v", unit, err)
+	}
+}
@@ -0,0 +1,354 @@
+package luxwssim
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/hansmi/wp2reg-luxws/luxwslang"
+)
+
+// sourceKind determines how a quantity of the thermal model is formatted.
+type sourceKind int
+
+const (
+	kindTemperature sourceKind = iota
+	kindFlowRate
+	kindPower
+	kindBool
+	kindDuration
+	kindCount
+	kindEnergy
+	kindMode
+)
+
+// Quantities of the thermal model which can be used as item sources.
+var sources = map[string]sourceKind{
+	"outside":           kindTemperature,
+	"flow":              kindTemperature,
+	"return":            kindTemperature,
+	"flow_target":       kindTemperature,
+	"hot_water":         kindTemperature,
+	"hot_water_target":  kindTemperature,
+	"flow_rate":         kindFlowRate,
+	"power_output":      kindPower,
+	"power_input":       kindPower,
+	"compressor":        kindBool,
+	"circulation_pump":  kindBool,
+	"defrost_valve":     kindBool,
+	"compressor_hours":  kindDuration,
+	"heating_hours":     kindDuration,
+	"hot_water_hours":   kindDuration,
+	"compressor_state":  kindDuration,
+	"compressor_starts": kindCount,
+	"heating_mode":      kindCount,
+	"heat_heating":      kindEnergy,
+	"heat_hot_water":    kindEnergy,
+	"heat_total":        kindEnergy,
+	"operation_mode":    kindMode,
+}
+
+func knownSource(name string) bool {
+	_, ok := sources[name]
+	return ok
+}
+
+const (
+	// Step size of the simulation.
+	thermalStep = 10 * time.Second
+
+	// Values of the heating mode setting.
+	heatingModeAuto = 0
+	heatingModeOff  = 4
+
+	// Heat capacity of water in kJ/(l*K).
+	waterHeatCapacity = 4.18
+
+	hotWaterTankLiters = 200.0
+	nominalFlowRate    = 1200.0
+	defrostInterval    = 2 * time.Hour
+	defrostDuration    = 5 * time.Minute
+)
+
+// thermal is a simple, deterministic model of a heat pump heating a building
+// and a hot water tank. Outside temperatures follow a daily cycle. The
+// compressor runs with a hysteresis around the flow temperature target given
+// by a heating curve and periodically defrosts when it's cold outside.
+type thermal struct {
+	now time.Time
+
+	outside        float64
+	flow           float64
+	ret            float64
+	hotWater       float64
+	hotWaterTarget float64
+	heatingMode    int
+
+	mode       luxwslang.OperationMode
+	compressor bool
+	flowRate   float64
+	output     float64
+	input      float64
+
+	// Time until the next defrost cycle and remaining time of the current
+	// cycle.
+	untilDefrost  time.Duration
+	defrostRemain time.Duration
+
+	compressorHours  time.Duration
+	heatingHours     time.Duration
+	hotWaterHours    time.Duration
+	compressorState  time.Duration
+	compressorStarts int
+
+	heatHeating  float64
+	heatHotWater float64
+}
+
+func newThermal(start time.Time) *thermal {
+	t := &thermal{
+		now:            start,
+		flow:           30,
+		ret:            27,
+		hotWater:       48,
+		hotWaterTarget: 50,
+		heatingMode:    heatingModeAuto,
+		mode:           luxwslang.OperationModeOff,
+		untilDefrost:   defrostInterval,
+
+		// Counters of a heat pump in use for a few years
+		compressorHours:  12345 * time.Hour,
+		heatingHours:     10987 * time.Hour,
+		hotWaterHours:    1358 * time.Hour,
+		compressorStarts: 4321,
+		heatHeating:      45678.9,
+		heatHotWater:     6789.1,
+	}
+
+	t.outside = outsideTemperature(start)
+
+	return t
+}
+
+// outsideTemperature returns a temperature between 0 and 12°C following
+// a daily cycle with the minimum at 3am.
+func outsideTemperature(ts time.Time) float64 {
+	hour := float64(ts.Hour()) + float64(ts.Minute())/60 + float64(ts.Second())/3600
+
+	return 6 - 6*math.Cos(2*math.Pi*(hour-3)/24)
+}
+
+// flowTarget returns the flow temperature target of the heating curve.
+func (t *thermal) flowTarget() float64 {
+	return math.Min(55, math.Max(25, 20+(20-t.outside)*0.8))
+}
+
+// cop estimates the coefficient of performance from the temperature lift.
+func (t *thermal) cop() float64 {
+	return math.Min(6, math.Max(1.5, 4.5-(t.flow-35)*0.08+(t.outside-7)*0.05))
+}
+
+func (t *thermal) setCompressor(on bool) {
+	if t.compressor == on {
+		return
+	}
+
+	t.compressor = on
+	t.compressorState = 0
+
+	if on {
+		t.compressorStarts++
+	}
+}
+
+// step advances the model by the given duration.
+func (t *thermal) step(dt time.Duration) {
+	t.now = t.now.Add(dt)
+	t.outside = outsideTemperature(t.now)
+
+	secs := dt.Seconds()
+	target := t.flowTarget()
+
+	// Hot water cools down slowly due to usage and losses.
+	t.hotWater -= (t.hotWater - 20) * secs / (48 * 3600)
+
+	switch {
+	case t.defrostRemain > 0:
+		t.defrostRemain -= dt
+
+		if t.defrostRemain <= 0 {
+			t.mode = luxwslang.OperationModeOff
+		}
+
+	case t.mode == luxwslang.OperationModeHotWater:
+		if t.hotWater >= t.hotWaterTarget {
+			t.mode = luxwslang.OperationModeOff
+		}
+
+	case t.hotWater < t.hotWaterTarget-5:
+		t.mode = luxwslang.OperationModeHotWater
+
+	case t.heatingMode != heatingModeOff && t.outside < 18:
+		if t.mode != luxwslang.OperationModeHeating && t.flow < target-2 {
+			t.mode = luxwslang.OperationModeHeating
+		} else if t.mode == luxwslang.OperationModeHeating && t.flow > target+2 {
+			t.mode = luxwslang.OperationModeOff
+		}
+
+	default:
+		t.mode = luxwslang.OperationModeOff
+	}
+
+	if t.compressor && t.defrostRemain <= 0 && t.outside < 7 {
+		t.untilDefrost -= dt
+
+		if t.untilDefrost <= 0 {
+			t.mode = luxwslang.OperationModeDefrost
+			t.untilDefrost = defrostInterval
+			t.defrostRemain = defrostDuration
+		}
+	}
+
+	t.setCompressor(t.mode != luxwslang.OperationModeOff)
+	t.compressorState += dt
+
+	spread := 1.0
+	sink := 22.0
+	t.flowRate = 0
+	t.output = 0
+	t.input = 0
+
+	switch t.mode {
+	case luxwslang.OperationModeHeating:
+		sink = target + 4
+		spread = 5
+
+	case luxwslang.OperationModeHotWater:
+		sink = math.Min(60, t.hotWater+8)
+		spread = 6
+
+	case luxwslang.OperationModeDefrost:
+		sink = 18
+		spread = -2
+	}
+
+	// Flow temperature approaches the sink temperature with a time constant
+	// of ten minutes.
+	t.flow += (sink - t.flow) * math.Min(1, secs/600)
+	t.ret = t.flow - spread
+
+	if !t.compressor {
+		return
+	}
+
+	t.flowRate = nominalFlowRate
+	t.compressorHours += dt
+
+	if t.mode == luxwslang.OperationModeDefrost {
+		t.input = 1.2
+		return
+	}
+
+	t.output = t.flowRate / 3600 * spread * waterHeatCapacity
+	t.input = t.output / t.cop()
+
+	energy := t.output * secs / 3600
+
+	switch t.mode {
+	case luxwslang.OperationModeHeating:
+		t.heatingHours += dt
+		t.heatHeating += energy
+
+	case luxwslang.OperationModeHotWater:
+		t.hotWaterHours += dt
+		t.heatHotWater += energy
+		t.hotWater += t.output * secs / (hotWaterTankLiters * waterHeatCapacity)
+	}
+}
+
+// advance runs the model up to the given time.
+func (t *thermal) advance(now time.Time) {
+	for !t.now.Add(thermalStep).After(now) {
+		t.step(thermalStep)
+	}
+}
+
+// number returns the numeric value of a quantity. Bools are 0 or 1.
+func (t *thermal) number(source string) float64 {
+	boolValue := func(v bool) float64 {
+		if v {
+			return 1
+		}
+
+		return 0
+	}
+
+	switch source {
+	case "outside":
+		return t.outside
+	case "flow":
+		return t.flow
+	case "return":
+		return t.ret
+	case "flow_target":
+		return t.flowTarget()
+	case "hot_water":
+		return t.hotWater
+	case "hot_water_target":
+		return t.hotWaterTarget
+	case "flow_rate":
+		return t.flowRate
+	case "power_output":
+		return t.output
+	case "power_input":
+		return t.input
+	case "compressor":
+		return boolValue(t.compressor)
+	case "circulation_pump":
+		return boolValue(t.flowRate > 0)
+	case "defrost_valve":
+		return boolValue(t.mode == luxwslang.OperationModeDefrost)
+	case "compressor_hours":
+		return t.compressorHours.Seconds()
+	case "heating_hours":
+		return t.heatingHours.Seconds()
+	case "hot_water_hours":
+		return t.hotWaterHours.Seconds()
+	case "compressor_state":
+		return t.compressorState.Seconds()
+	case "compressor_starts":
+		return float64(t.compressorStarts)
+	case "heating_mode":
+		return float64(t.heatingMode)
+	case "heat_heating":
+		return t.heatHeating
+	case "heat_hot_water":
+		return t.heatHotWater
+	case "heat_total":
+		return t.heatHeating + t.heatHotWater
+	case "operation_mode":
+		return float64(t.mode)
+	}
+
+	return math.NaN()
+}
+
+// set changes a setting of the model.
+func (t *thermal) set(source string, value float64) error {
+	switch source {
+	case "hot_water_target":
+		t.hotWaterTarget = value
+
+	case "heating_mode":
+		if value != heatingModeAuto && value != heatingModeOff {
+			return fmt.Errorf("unsupported heating mode %v", value)
+		}
+
+		t.heatingMode = int(value)
+
+	default:
+		return fmt.Errorf("%q is not a setting", source)
+	}
+
+	return nil
+}
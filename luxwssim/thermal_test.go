@@ -0,0 +1,83 @@
+package luxwssim
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/hansmi/wp2reg-luxws/luxwslang"
+)
+
+func TestThermal(t *testing.T) {
+	start := time.Date(2024, time.January, 10, 0, 0, 0, 0, time.UTC)
+
+	th := newThermal(start)
+
+	initial := *th
+	modes := map[luxwslang.OperationMode]bool{}
+
+	for now := start; now.Before(start.Add(48 * time.Hour)); now = now.Add(time.Minute) {
+		prevHeat := th.heatHeating + th.heatHotWater
+
+		th.advance(now)
+
+		modes[th.mode] = true
+
+		if total := th.heatHeating + th.heatHotWater; total < prevHeat {
+			t.Fatalf("Heat quantity decreased from %f to %f at %v", prevHeat, total, now)
+		}
+
+		if th.outside < 0 || th.outside > 12 {
+			t.Errorf("Outside temperature %f out of range at %v", th.outside, now)
+		}
+
+		if th.hotWater < 40 || th.hotWater > 60 {
+			t.Errorf("Hot water temperature %f out of range at %v", th.hotWater, now)
+		}
+
+		if math.IsNaN(th.input) || th.input < 0 {
+			t.Errorf("Invalid power input %f at %v", th.input, now)
+		}
+	}
+
+	for _, mode := range []luxwslang.OperationMode{
+		luxwslang.OperationModeHeating,
+		luxwslang.OperationModeHotWater,
+		luxwslang.OperationModeDefrost,
+		luxwslang.OperationModeOff,
+	} {
+		if !modes[mode] {
+			t.Errorf("Mode %v never active", mode)
+		}
+	}
+
+	if th.compressorStarts <= initial.compressorStarts {
+		t.Errorf("Compressor never started")
+	}
+
+	if th.compressorHours <= initial.compressorHours || th.heatingHours <= initial.heatingHours || th.hotWaterHours <= initial.hotWaterHours {
+		t.Errorf("Operating hours didn't increase")
+	}
+}
+
+func TestThermalHeatingOff(t *testing.T) {
+	start := time.Date(2024, time.January, 10, 0, 0, 0, 0, time.UTC)
+
+	th := newThermal(start)
+
+	if err := th.set("heating_mode", heatingModeOff); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := th.set("heating_mode", 1); err == nil {
+		t.Errorf("Setting unsupported heating mode succeeded")
+	}
+
+	before := th.heatingHours
+
+	th.advance(start.Add(24 * time.Hour))
+
+	if th.heatingHours != before {
+		t.Errorf("Heating while switched off")
+	}
+}
@@ -0,0 +1,192 @@
+package luxwstest
+
+import (
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+)
+
+const controlTimeout = 10 * time.Second
+
+// Conn is the server side of a client connection.
+type Conn struct {
+	ws       *websocket.Conn
+	previous []byte
+	closed   bool
+}
+
+// WriteText sends a text message. Nothing is sent if the payload is nil.
+func (c *Conn) WriteText(payload []byte) error {
+	if payload == nil {
+		return nil
+	}
+
+	return c.ws.WriteMessage(websocket.TextMessage, payload)
+}
+
+// WritePing sends a ping control message.
+func (c *Conn) WritePing(data []byte) error {
+	return c.ws.WriteControl(websocket.PingMessage, data, time.Now().Add(controlTimeout))
+}
+
+// WriteClose sends a close control message and closes the connection without
+// waiting for the client to acknowledge it.
+func (c *Conn) WriteClose(code int, text string) error {
+	err := c.ws.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(code, text), time.Now().Add(controlTimeout))
+
+	c.close()
+
+	return err
+}
+
+// CloseAbruptly closes the network connection without a close message.
+func (c *Conn) CloseAbruptly() error {
+	c.closed = true
+
+	return c.ws.NetConn().Close()
+}
+
+// Previous returns the most recent response of the handler before the
+// current request.
+func (c *Conn) Previous() []byte {
+	return c.previous
+}
+
+func (c *Conn) close() {
+	c.closed = true
+	c.ws.Close()
+}
+
+// Fault answers a request in a faulty manner. The response is nil if the
+// handler didn't answer the request.
+type Fault func(c *Conn, response []byte) error
+
+// respond answers without a fault.
+func respond(c *Conn, response []byte) error {
+	return c.WriteText(response)
+}
+
+// Delay sends the response after the given duration. Other requests are not
+// processed in the meantime.
+func Delay(d time.Duration) Fault {
+	return func(c *Conn, response []byte) error {
+		time.Sleep(d)
+
+		return c.WriteText(response)
+	}
+}
+
+// Drop doesn't send the response.
+func Drop() Fault {
+	return func(*Conn, []byte) error {
+		return nil
+	}
+}
+
+// Truncate sends the first half of the response.
+func Truncate() Fault {
+	return func(c *Conn, response []byte) error {
+		if response == nil {
+			return nil
+		}
+
+		return c.WriteText(response[:len(response)/2])
+	}
+}
+
+// InvalidXML sends a malformed document instead of the response.
+func InvalidXML() Fault {
+	return func(c *Conn, _ []byte) error {
+		return c.WriteText([]byte(`<Content><item id="0x1"><name>broken</Content>`))
+	}
+}
+
+// WrongCharset sends the response encoded as ISO-8859-1 without declaring
+// the encoding. Responses consisting only of ASCII characters are not
+// affected.
+func WrongCharset() Fault {
+	return func(c *Conn, response []byte) error {
+		if response == nil {
+			return nil
+		}
+
+		var result []byte
+
+		for len(response) > 0 {
+			r, size := utf8.DecodeRune(response)
+			response = response[size:]
+
+			if r > 0xff {
+				r = '?'
+			}
+
+			result = append(result, byte(r))
+		}
+
+		return c.WriteText(result)
+	}
+}
+
+// UnknownCharset declares an unsupported encoding for the response.
+func UnknownCharset() Fault {
+	return func(c *Conn, response []byte) error {
+		if response == nil {
+			return nil
+		}
+
+		return c.WriteText(append([]byte(`<?xml version="1.0" encoding="x-unknown"?>`), response...))
+	}
+}
+
+// Push sends an unsolicited message before the response.
+func Push(payload string) Fault {
+	return func(c *Conn, response []byte) error {
+		if err := c.WriteText([]byte(payload)); err != nil {
+			return err
+		}
+
+		return c.WriteText(response)
+	}
+}
+
+// OutOfOrder sends the previous response again before the current one, as
+// if it had been delayed.
+func OutOfOrder() Fault {
+	return func(c *Conn, response []byte) error {
+		if err := c.WriteText(c.Previous()); err != nil {
+			return err
+		}
+
+		return c.WriteText(response)
+	}
+}
+
+// CloseFrame sends a close message with the given code instead of the
+// response and closes the connection.
+func CloseFrame(code int) Fault {
+	return func(c *Conn, _ []byte) error {
+		return c.WriteClose(code, "")
+	}
+}
+
+// AbruptClose closes the network connection instead of sending the response.
+func AbruptClose() Fault {
+	return func(c *Conn, _ []byte) error {
+		return c.CloseAbruptly()
+	}
+}
+
+// PingFlood sends the given number of ping messages before the response.
+func PingFlood(count int) Fault {
+	return func(c *Conn, response []byte) error {
+		for i := 0; i < count; i++ {
+			if err := c.WritePing(nil); err != nil {
+				return err
+			}
+		}
+
+		return c.WriteText(response)
+	}
+}
@@ -0,0 +1,323 @@
+package luxwstest
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/hansmi/wp2reg-luxws/luxws"
+	"github.com/hansmi/wp2reg-luxws/luxwsclient"
+)
+
+const (
+	testNavigation = `<Navigation id="0x1"><item id="0x2"><name>Informationen</name></item></Navigation>`
+	testContent    = `<Content><item id="0x3"><name>Wärmemenge</name><value>12.3 kWh</value></item></Content>`
+)
+
+func newTestServer(t *testing.T) *Server {
+	return NewServer(t, StaticHandler(map[string]string{
+		"LOGIN;":  testNavigation,
+		"GET;0x2": testContent,
+	}))
+}
+
+func dialClient(t *testing.T, s *Server) *luxwsclient.Client {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cl, err := luxwsclient.Dial(ctx, s.Address())
+	if err != nil {
+		t.Fatalf("Dial() failed: %v", err)
+	}
+
+	if _, err := cl.Login(ctx, ""); err != nil {
+		t.Fatalf("Login() failed: %v", err)
+	}
+
+	return cl
+}
+
+func get(cl *luxwsclient.Client, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	content, err := cl.Get(ctx, "0x2")
+	if err == nil && content.FindByName("Wärmemenge") == nil {
+		err = errors.New("item not found in response")
+	}
+
+	return err
+}
+
+type faultTestCase struct {
+	name  string
+	fault Fault
+
+	// Timeout for the faulty round trip.
+	timeout time.Duration
+
+	// Whether the faulty round trip succeeds.
+	wantSuccess bool
+
+	// Expected error of the faulty round trip if not nil.
+	wantErr error
+
+	// Whether the connection remains usable after the fault.
+	usable bool
+}
+
+func faultTestCases() []faultTestCase {
+	return []faultTestCase{
+		{
+			name:        "short delay",
+			fault:       Delay(10 * time.Millisecond),
+			wantSuccess: true,
+			usable:      true,
+		},
+		{
+			name:    "long delay",
+			fault:   Delay(500 * time.Millisecond),
+			timeout: 100 * time.Millisecond,
+			wantErr: context.DeadlineExceeded,
+			usable:  true,
+		},
+		{
+			name:    "drop",
+			fault:   Drop(),
+			timeout: 100 * time.Millisecond,
+			wantErr: context.DeadlineExceeded,
+			usable:  true,
+		},
+		{
+			name:   "truncate",
+			fault:  Truncate(),
+			usable: true,
+		},
+		{
+			name:   "invalid XML",
+			fault:  InvalidXML(),
+			usable: true,
+		},
+		{
+			name:   "wrong charset",
+			fault:  WrongCharset(),
+			usable: true,
+		},
+		{
+			name:   "unknown charset",
+			fault:  UnknownCharset(),
+			usable: true,
+		},
+		{
+			name:        "push",
+			fault:       Push(`<values><item id="0x3"><value>1</value></item></values>`),
+			wantSuccess: true,
+			usable:      true,
+		},
+		{
+			name:        "out of order",
+			fault:       OutOfOrder(),
+			wantSuccess: true,
+			usable:      true,
+		},
+		{
+			name:        "ping flood",
+			fault:       PingFlood(1000),
+			wantSuccess: true,
+			usable:      true,
+		},
+		{
+			name:  "close frame",
+			fault: CloseFrame(websocket.CloseInternalServerErr),
+		},
+		{
+			name:  "abrupt close",
+			fault: AbruptClose(),
+		},
+	}
+}
+
+func TestFaults(t *testing.T) {
+	for _, tc := range faultTestCases() {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := newTestServer(t)
+			cl := dialClient(t, s)
+
+			timeout := tc.timeout
+			if timeout == 0 {
+				timeout = 10 * time.Second
+			}
+
+			s.Inject(tc.fault)
+
+			err := get(cl, timeout)
+
+			switch {
+			case tc.wantSuccess:
+				if err != nil {
+					t.Errorf("Get() failed: %v", err)
+				}
+
+			case err == nil:
+				t.Errorf("Get() succeeded despite fault")
+
+			case tc.wantErr != nil && !errors.Is(err, tc.wantErr):
+				t.Errorf("Get() returned %v, want %v", err, tc.wantErr)
+
+			case errors.Is(err, luxws.ErrBusy):
+				t.Errorf("Get() returned %v", err)
+			}
+
+			// Subsequent round trips must neither block nor report a busy
+			// connection.
+			err = get(cl, 10*time.Second)
+
+			if tc.usable {
+				if err != nil {
+					t.Errorf("Get() after fault failed: %v", err)
+				}
+			} else if err == nil || errors.Is(err, luxws.ErrBusy) || errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("Get() after fault returned %v, want connection error", err)
+			}
+
+			closed := make(chan error, 1)
+
+			go func() {
+				closed <- cl.Close()
+			}()
+
+			select {
+			case <-closed:
+			case <-time.After(10 * time.Second):
+				t.Fatalf("Close() blocked")
+			}
+
+			if err := get(cl, 10*time.Second); err == nil {
+				t.Errorf("Get() after Close() succeeded")
+			}
+
+			if !s.WaitConnections(0, 10*time.Second) {
+				t.Errorf("Connection not closed")
+			}
+		})
+	}
+}
+
+// faultyClient performs a round trip with the given fault and then drops the
+// client without closing it.
+func faultyClient(t *testing.T, s *Server, fault Fault) {
+	cl := dialClient(t, s)
+
+	s.Inject(fault)
+
+	get(cl, 100*time.Millisecond)
+}
+
+func TestFaultsFinalizer(t *testing.T) {
+	for _, tc := range faultTestCases() {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := newTestServer(t)
+
+			faultyClient(t, s, tc.fault)
+
+			done := false
+
+			for i := 0; i < 20 && !done; i++ {
+				runtime.GC()
+
+				done = s.WaitConnections(0, 500*time.Millisecond)
+			}
+
+			if !done {
+				t.Errorf("Finalizer didn't close connection")
+			}
+		})
+	}
+}
+
+func TestCloseDuringRoundTrip(t *testing.T) {
+	for _, fault := range []Fault{Drop(), Delay(time.Second)} {
+		s := newTestServer(t)
+		cl := dialClient(t, s)
+
+		s.Inject(fault)
+
+		result := make(chan error, 1)
+
+		go func() {
+			result <- get(cl, time.Minute)
+		}()
+
+		// Wait for the request to arrive
+		for len(s.Requests()) < 2 {
+			time.Sleep(time.Millisecond)
+		}
+
+		if err := cl.Close(); err != nil {
+			t.Errorf("Close() failed: %v", err)
+		}
+
+		select {
+		case err := <-result:
+			if err == nil {
+				t.Errorf("Get() succeeded despite Close()")
+			}
+
+		case <-time.After(10 * time.Second):
+			t.Fatalf("Get() not unblocked by Close()")
+		}
+	}
+}
+
+func TestConcurrentRoundTrips(t *testing.T) {
+	s := newTestServer(t)
+	cl := dialClient(t, s)
+
+	for i := 0; i < 10; i++ {
+		s.Inject(Delay(10*time.Millisecond), PingFlood(100), OutOfOrder())
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var succeeded int
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 5; j++ {
+				switch err := get(cl, 10*time.Second); {
+				case err == nil:
+					mu.Lock()
+					succeeded++
+					mu.Unlock()
+
+				case !errors.Is(err, luxws.ErrBusy):
+					t.Errorf("Get() failed: %v", err)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if succeeded == 0 {
+		t.Errorf("No round trip succeeded")
+	}
+
+	if err := cl.Close(); err != nil {
+		t.Errorf("Close() failed: %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+// Package luxwstest provides a fake LuxWS server injecting faults on demand
+// for testing clients of the protocol. It's meant to be used with the race
+// detector enabled (go test -race).
+package luxwstest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Handler returns the response to a request. No response is sent if ok is
+// false.
+type Handler func(req string) (response string, ok bool)
+
+// StaticHandler returns a handler answering requests from a map. Unknown
+// requests are not answered.
+func StaticHandler(responses map[string]string) Handler {
+	return func(req string) (string, bool) {
+		response, ok := responses[req]
+		return response, ok
+	}
+}
+
+// Server is a LuxWS server listening on a loopback address. Faults are
+// applied to the answers of requests in the order they were injected.
+type Server struct {
+	t       testing.TB
+	handler Handler
+	server  *httptest.Server
+
+	upgrader websocket.Upgrader
+
+	mu       sync.Mutex
+	faults   []Fault
+	requests []string
+	open     int
+	changed  chan struct{}
+}
+
+// NewServer starts a new server. It's stopped when the test finishes.
+func NewServer(t testing.TB, handler Handler) *Server {
+	t.Helper()
+
+	s := &Server{
+		t:       t,
+		handler: handler,
+		upgrader: websocket.Upgrader{
+			Subprotocols: []string{"Lux_WS"},
+		},
+		changed: make(chan struct{}),
+	}
+
+	s.server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.server.Close)
+
+	return s
+}
+
+// Address returns the "host:port" address of the server.
+func (s *Server) Address() string {
+	u, err := url.Parse(s.server.URL)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+
+	return u.Host
+}
+
+// Inject queues faults. Each fault applies to the answer of a single request;
+// requests without a queued fault are answered normally.
+func (s *Server) Inject(faults ...Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = append(s.faults, faults...)
+}
+
+// Requests returns all requests received so far.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.requests...)
+}
+
+// OpenConnections returns the number of client connections not yet closed.
+func (s *Server) OpenConnections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.open
+}
+
+// WaitConnections waits until the number of open connections matches the
+// given value. False is returned if that doesn't happen within the timeout.
+func (s *Server) WaitConnections(want int, timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		s.mu.Lock()
+		open, changed := s.open, s.changed
+		s.mu.Unlock()
+
+		if open == want {
+			return true
+		}
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			return false
+		}
+	}
+}
+
+func (s *Server) connectionsChanged(delta int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.open += delta
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// nextFault returns the fault for the next request and records the request.
+func (s *Server) nextFault(req string) Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, req)
+
+	if len(s.faults) == 0 {
+		return nil
+	}
+
+	f := s.faults[0]
+	s.faults = s.faults[1:]
+
+	return f
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	ws, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	s.connectionsChanged(1)
+	defer s.connectionsChanged(-1)
+
+	c := &Conn{ws: ws}
+
+	defer c.close()
+
+	for {
+		_, payload, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		req := string(payload)
+
+		var response []byte
+
+		if text, ok := s.handler(req); ok {
+			response = []byte(text)
+		}
+
+		fault := s.nextFault(req)
+		if fault == nil {
+			fault = respond
+		}
+
+		err = fault(c, response)
+
+		if response != nil {
+			c.previous = response
+		}
+
+		if err != nil || c.closed {
+			return
+		}
+	}
+}